@@ -0,0 +1,19 @@
+package controller
+
+import (
+	"fixify_backend/model/users"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// accountUsableSQL matches users rows that are neither banned nor currently suspended. A lapsed
+// suspension counts as active even before the job resets it.
+const accountUsableSQL = "(users.account_status IS NULL OR users.account_status = '" + users.AccountStatusActive + "'" +
+	" OR (users.account_status = '" + users.AccountStatusSuspended + "' AND users.suspended_until <= ?))"
+
+// ScopeUsableAccounts leaves banned and suspended users out of a query on the users table. Use
+// with db.Scopes.
+func ScopeUsableAccounts(db *gorm.DB) *gorm.DB {
+	return db.Where(accountUsableSQL, time.Now())
+}
@@ -0,0 +1,236 @@
+package adminfeatures
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/controller/signuplogin"
+	"fixify_backend/mailer"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxStatusReasonLength = 500
+
+type accountStatusChange struct {
+	Status         string `json:"status"`          // users.AccountStatusActive, Suspended or Banned
+	Reason         string `json:"reason"`          // Required for suspensions and bans; shown to the user
+	SuspendedUntil string `json:"suspended_until"` // End of a suspension, RFC 3339 or YYYY-MM-DD (start of that day)
+	Days           int    `json:"days"`            // Alternative to suspended_until
+}
+
+// SetAccountStatus suspends, bans or reinstates a client or repairman. Restricting an account
+// signs it out everywhere, closes its chat connection and tells the user why.
+func SetAccountStatus(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
+
+	userId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userId <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid user ID",
+			Data: errors.ErrorModel{
+				Message:   "User ID must be a valid number",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var body accountStatusChange
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data: errors.ErrorModel{
+				Message:   "Failed to parse JSON body",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	body.Status = strings.ToLower(strings.TrimSpace(body.Status))
+	body.Reason = strings.TrimSpace(body.Reason)
+
+	var until *time.Time
+	switch body.Status {
+	case users.AccountStatusActive:
+		body.Reason = ""
+	case users.AccountStatusSuspended:
+		end, err := suspensionEnd(body)
+		if err != nil {
+			return invalidStatusChange(c, err.Error())
+		}
+		until = &end
+	case users.AccountStatusBanned:
+	default:
+		return invalidStatusChange(c, "Status must be active, suspended or banned")
+	}
+	if body.Status != users.AccountStatusActive && body.Reason == "" {
+		return invalidStatusChange(c, "Tell the user why their account is restricted")
+	}
+	if len(body.Reason) > maxStatusReasonLength {
+		return invalidStatusChange(c, "Reason must be at most 500 characters")
+	}
+
+	var user users.User
+	if err := db.Select("user_id, email, first_name, account_status, suspended_until, status_reason").
+		First(&user, "user_id = ?", userId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "User not found",
+			Data: errors.ErrorModel{
+				Message:   "No user with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	before := map[string]interface{}{
+		"account_status":  user.AccountStatus,
+		"suspended_until": user.SuspendedUntil,
+		"status_reason":   user.StatusReason,
+	}
+
+	restricting := body.Status != users.AccountStatusActive
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&users.User{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+			"account_status":  body.Status,
+			"suspended_until": until,
+			"status_reason":   body.Reason,
+		}).Error; err != nil {
+			return err
+		}
+		if restricting {
+			return signuplogin.RevokeSessions(tx, users.RoleUser, uint(userId))
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to update account status",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	user.AccountStatus = body.Status
+	user.SuspendedUntil = until
+	user.StatusReason = body.Reason
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAccountStatusChange,
+		TargetType: users.RoleUser,
+		TargetId:   userId,
+		Before:     before,
+		After: map[string]interface{}{
+			"account_status":  user.AccountStatus,
+			"suspended_until": user.SuspendedUntil,
+			"status_reason":   user.StatusReason,
+		},
+	})
+
+	if restricting {
+		websocketclient.HubInstance.Disconnect(uint(userId))
+	}
+	notifyAccountStatus(db, user)
+
+	log.Printf("Admin %d set account status of user %d to %s", admin.UserId, userId, body.Status)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Account status updated",
+		Data: fiber.Map{
+			"user_id":         user.UserId,
+			"account_status":  user.AccountStatus,
+			"suspended_until": user.SuspendedUntil,
+			"status_reason":   user.StatusReason,
+		},
+	})
+}
+
+func suspensionEnd(body accountStatusChange) (time.Time, error) {
+	var end time.Time
+	switch {
+	case body.SuspendedUntil != "":
+		parsed, err := time.Parse(time.RFC3339, body.SuspendedUntil)
+		if err != nil {
+			parsed, err = time.ParseInLocation("2006-01-02", body.SuspendedUntil, time.Local)
+		}
+		if err != nil {
+			return end, fmt.Errorf("suspended_until must be a date (YYYY-MM-DD) or an RFC 3339 time")
+		}
+		end = parsed
+	case body.Days > 0:
+		end = time.Now().AddDate(0, 0, body.Days)
+	default:
+		return end, fmt.Errorf("A suspension needs suspended_until or days")
+	}
+	if !end.After(time.Now()) {
+		return end, fmt.Errorf("suspended_until must be in the future")
+	}
+	return end, nil
+}
+
+// notifyAccountStatus tells the user about a suspension, ban or reinstatement. Restricted users
+// can't open the app, so they are emailed as well.
+func notifyAccountStatus(db *gorm.DB, user users.User) {
+	var title, description string
+	switch user.AccountStatus {
+	case users.AccountStatusSuspended:
+		title = "Your account has been suspended"
+		description = "Your Fixify account is suspended until " + user.SuspendedUntil.Format("January 2, 2006 3:04 PM") + ": " + user.StatusReason
+	case users.AccountStatusBanned:
+		title = "Your account has been banned"
+		description = "Your Fixify account has been banned: " + user.StatusReason
+	default:
+		title = "Your account has been reinstated"
+		description = "Your Fixify account is active again. Welcome back!"
+	}
+
+	if err := websocketclient.Notify(db, websocketclient.Notification{
+		Category: users.NotificationCategoryAccount,
+		Type:     "Account Status",
+		ToUser:   user.UserId,
+		Title:    title,
+		Body:     description,
+		Data: map[string]string{
+			"account_status": user.AccountStatus,
+		},
+	}); err != nil {
+		log.Printf("Failed to notify user %d about account status: %v", user.UserId, err)
+	}
+
+	if user.AccountStatus != users.AccountStatusActive && user.Email != "" {
+		if err := mailer.SendTemplate(db, user.Email, mailer.TemplateNotification, mailer.NotificationData{
+			Title: title,
+			Body:  description + " If you think this is a mistake, please contact support.",
+		}); err != nil {
+			log.Printf("Failed to queue account status email for user %d: %v", user.UserId, err)
+		}
+	}
+}
+
+func invalidStatusChange(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+		RetCode: "400",
+		Message: "Invalid status change",
+		Data: errors.ErrorModel{
+			Message:   message,
+			IsSuccess: false,
+		},
+	})
+}
@@ -0,0 +1,273 @@
+package adminfeatures
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/controller/signuplogin"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fmt"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CreateAdminInvite emails an invite to join the admin team with the given role. Body:
+// {"email": "...", "admin_role": "verifier"}.
+func CreateAdminInvite(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var body struct {
+		Email     string `json:"email"`
+		AdminRole string `json:"admin_role"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return invalidTeamChange(c, "Failed to parse JSON body")
+	}
+	addr, err := mail.ParseAddress(strings.TrimSpace(body.Email))
+	if err != nil {
+		return invalidTeamChange(c, "Enter a valid email address")
+	}
+	// Keep only the address, never a display name such as "Ana <ana@example.com>"
+	body.Email = strings.ToLower(addr.Address)
+	if _, ok := users.AdminRolePermissions[body.AdminRole]; !ok {
+		return invalidTeamChange(c, "admin_role must be super_admin, verifier, support or finance")
+	}
+
+	var existing int64
+	if err := db.Model(&users.Admin{}).Where("LOWER(email) = ?", body.Email).Count(&existing).Error; err != nil {
+		return teamChangeFailed(c, err)
+	}
+	if existing > 0 {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "Already an admin",
+			Data: errors.ErrorModel{
+				Message:   "An admin account with this email already exists",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var inviter users.Admin
+	if err := db.Select("admin_id, username").First(&inviter, "admin_id = ?", claims.UserId).Error; err != nil {
+		return teamChangeFailed(c, err)
+	}
+
+	invite, err := signuplogin.IssueAdminInvite(db, body.Email, body.AdminRole, inviter)
+	if err != nil && invite.InviteId == 0 {
+		return teamChangeFailed(c, err)
+	}
+	if err != nil {
+		// The invite exists; it can be sent again by inviting the same address
+		log.Printf("Failed to email admin invite %d: %v", invite.InviteId, err)
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAdminInviteCreate,
+		TargetType: "admin_invite",
+		TargetId:   invite.InviteId,
+		After:      map[string]interface{}{"email": invite.Email, "admin_role": invite.AdminRole},
+	})
+
+	message := "Invite sent to " + invite.Email
+	if err != nil {
+		message = "Invite created, but the email could not be sent. Try inviting again."
+	}
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: message,
+		Data:    invite,
+	})
+}
+
+// RevokeAdminInvite cancels an invite that hasn't been used yet
+func RevokeAdminInvite(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	inviteId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || inviteId <= 0 {
+		return invalidTeamChange(c, "Invite ID must be a valid number")
+	}
+
+	result := db.Model(&users.AdminInvite{}).
+		Where("invite_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", inviteId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return teamChangeFailed(c, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Invite not found",
+			Data: errors.ErrorModel{
+				Message:   "No pending invite with the given ID",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAdminInviteRevoke,
+		TargetType: "admin_invite",
+		TargetId:   inviteId,
+	})
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Invite revoked",
+	})
+}
+
+// UpdateAdminMember changes another admin's role and/or disables or re-enables them. Body:
+// {"admin_role": "support"} and/or {"disabled": true}. The last enabled super admin can't be
+// demoted or disabled, and admins can't change their own account here.
+func UpdateAdminMember(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	adminId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || adminId <= 0 {
+		return invalidTeamChange(c, "Admin ID must be a valid number")
+	}
+	if uint(adminId) == claims.UserId {
+		return invalidTeamChange(c, "Ask another super admin to change your own account")
+	}
+
+	var body struct {
+		AdminRole string `json:"admin_role"`
+		Disabled  *bool  `json:"disabled"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return invalidTeamChange(c, "Failed to parse JSON body")
+	}
+	if body.AdminRole == "" && body.Disabled == nil {
+		return invalidTeamChange(c, "Provide admin_role, disabled or both")
+	}
+	if _, ok := users.AdminRolePermissions[body.AdminRole]; body.AdminRole != "" && !ok {
+		return invalidTeamChange(c, "admin_role must be super_admin, verifier, support or finance")
+	}
+
+	var admin users.Admin
+	if err := db.Select("admin_id, username, email, admin_role, disabled_at").First(&admin, "admin_id = ?", adminId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Admin not found",
+			Data: errors.ErrorModel{
+				Message:   "No admin with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	before := map[string]interface{}{"admin_role": admin.AdminRole, "disabled_at": admin.DisabledAt}
+
+	updates := map[string]interface{}{}
+	if body.AdminRole != "" && body.AdminRole != admin.AdminRole {
+		updates["admin_role"] = body.AdminRole
+	}
+	disabling := body.Disabled != nil && *body.Disabled && admin.DisabledAt == nil
+	if disabling {
+		updates["disabled_at"] = time.Now()
+	}
+	if body.Disabled != nil && !*body.Disabled && admin.DisabledAt != nil {
+		updates["disabled_at"] = nil
+	}
+	if len(updates) == 0 {
+		return c.JSON(response.ResponseModel{
+			RetCode: "200",
+			Message: "Nothing to change",
+			Data:    admin,
+		})
+	}
+
+	losingSuper := admin.AdminRole == users.AdminRoleSuper && admin.DisabledAt == nil &&
+		(disabling || updates["admin_role"] != nil)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if losingSuper {
+			var others int64
+			if err := tx.Model(&users.Admin{}).
+				Where("admin_role = ? AND disabled_at IS NULL AND admin_id <> ?", users.AdminRoleSuper, adminId).
+				Count(&others).Error; err != nil {
+				return err
+			}
+			if others == 0 {
+				return errLastSuperAdmin
+			}
+		}
+		if err := tx.Model(&users.Admin{}).Where("admin_id = ?", adminId).Updates(updates).Error; err != nil {
+			return err
+		}
+		if disabling {
+			return signuplogin.RevokeSessions(tx, users.RoleAdmin, uint(adminId))
+		}
+		return nil
+	})
+	if err == errLastSuperAdmin {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "At least one super admin is required",
+			Data: errors.ErrorModel{
+				Message:   "Make another admin a super admin first",
+				IsSuccess: false,
+			},
+		})
+	}
+	if err != nil {
+		return teamChangeFailed(c, err)
+	}
+
+	db.Select("admin_id, username, email, admin_role, disabled_at").First(&admin, "admin_id = ?", adminId)
+
+	action := users.AuditAdminRoleChange
+	if updates["admin_role"] == nil {
+		action = users.AuditAdminStatusChange
+	}
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     action,
+		TargetType: users.RoleAdmin,
+		TargetId:   adminId,
+		Before:     before,
+		After:      map[string]interface{}{"admin_role": admin.AdminRole, "disabled_at": admin.DisabledAt},
+	})
+
+	log.Printf("Admin %d updated admin %d: %v", claims.UserId, adminId, updates)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Admin updated",
+		Data:    admin,
+	})
+}
+
+var errLastSuperAdmin = fmt.Errorf("last super admin")
+
+func invalidTeamChange(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+		RetCode: "400",
+		Message: "Invalid Request!",
+		Data: errors.ErrorModel{
+			Message:   message,
+			IsSuccess: false,
+		},
+	})
+}
+
+func teamChangeFailed(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Failed to update the admin team",
+		Data: errors.ErrorModel{
+			Message:   "Database error",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
@@ -0,0 +1,120 @@
+package adminfeatures
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fixify_backend/vault"
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const documentRotationBatchSize = 50
+
+// RotateDocumentKeys re-wraps every identity document onto the active DOCUMENT_ACTIVE_KEY and
+// encrypts any documents still stored in plaintext. Only the per-document data keys change, so
+// this is cheap; once it reports nothing left, retired keys can be removed from DOCUMENT_KEYS.
+func RotateDocumentKeys(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
+
+	ring, err := vault.Default()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Document keys are not configured",
+			Data: errors.ErrorModel{
+				Message:   "Check DOCUMENT_KEYS and DOCUMENT_ACTIVE_KEY",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	active := ring.ActiveKeyID()
+
+	rotated, failed := 0, 0
+	lastId := uint(0)
+	for {
+		var batch []users.UserVerification
+		if err := db.Select("verification_id, valid_id, selfie, back_id, key_id").
+			Where("verification_id > ? AND key_id IS DISTINCT FROM ?", lastId, active).
+			Order("verification_id ASC").
+			Limit(documentRotationBatchSize).
+			Find(&batch).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to rotate document keys",
+				Data: errors.ErrorModel{
+					Message:   "Database error",
+					IsSuccess: false,
+					Error:     err.Error(),
+				},
+			})
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, verification := range batch {
+			lastId = verification.VerificationId
+
+			updates := map[string]interface{}{"key_id": active}
+			columns := map[string][]byte{
+				"valid_id": verification.ValidId,
+				"selfie":   verification.Selfie,
+				"back_id":  verification.BackId,
+			}
+			ok := true
+			for column, data := range columns {
+				rewrapped, changed, err := ring.Rewrap(data)
+				if err != nil {
+					log.Printf("Failed to rotate %s of verification %d: %v", column, verification.VerificationId, err)
+					ok = false
+					break
+				}
+				if changed {
+					updates[column] = rewrapped
+				}
+			}
+			if !ok {
+				failed++
+				continue
+			}
+
+			if err := db.Model(&users.UserVerification{}).
+				Where("verification_id = ?", verification.VerificationId).
+				Updates(updates).Error; err != nil {
+				log.Printf("Failed to save rotated verification %d: %v", verification.VerificationId, err)
+				failed++
+				continue
+			}
+			rotated++
+		}
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditDocumentKeysRotate,
+		TargetType: "document_keys",
+		TargetId:   active,
+		After: map[string]interface{}{
+			"active_key": active,
+			"rotated":    rotated,
+			"failed":     failed,
+		},
+	})
+
+	log.Printf("Admin %d rotated %d identity documents to key %s (%d failed)", admin.UserId, rotated, active, failed)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Document keys rotated",
+		Data: fiber.Map{
+			"active_key": active,
+			"rotated":    rotated,
+			"failed":     failed,
+		},
+	})
+}
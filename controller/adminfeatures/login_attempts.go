@@ -0,0 +1,99 @@
+package adminfeatures
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UnlockLogin clears a login lockout (and its failure count) for an account or IP
+func UnlockLogin(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
+
+	attemptId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || attemptId <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid lock ID",
+			Data: errors.ErrorModel{
+				Message:   "Lock ID must be a valid number",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var attempt users.LoginAttempt
+	if err := db.First(&attempt, "attempt_id = ?", attemptId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Lock not found",
+			Data: errors.ErrorModel{
+				Message:   "No login lock with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	// Admin account locks and IP throttles protect the admin team itself, so only admins who
+	// manage the team may clear them
+	if attempt.Scope != users.LoginScopeAccount || strings.HasPrefix(attempt.Key, users.RoleAdmin+":") {
+		var role string
+		if err := db.Model(&users.Admin{}).Where("admin_id = ?", admin.UserId).Pluck("admin_role", &role).Error; err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to unlock",
+				Data: errors.ErrorModel{
+					Message:   "Database error",
+					IsSuccess: false,
+					Error:     err.Error(),
+				},
+			})
+		}
+		if !users.AdminRoleHas(role, users.PermissionAdmins) {
+			return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+				RetCode: "403",
+				Message: "Your admin role does not allow this",
+				Data: errors.ErrorModel{
+					Message:   "Only admins who manage the admin team can clear admin and IP locks",
+					IsSuccess: false,
+				},
+			})
+		}
+	}
+
+	if err := db.Delete(&attempt).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to unlock",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditLoginUnlock,
+		TargetType: "login_attempt",
+		TargetId:   attempt.AttemptId,
+		Before:     attempt,
+	})
+
+	log.Printf("Admin %d cleared login lock %s %s", admin.UserId, attempt.Scope, attempt.Key)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Login unlocked",
+		Data:    attempt,
+	})
+}
@@ -0,0 +1,84 @@
+package adminfeatures
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ReviewMessageReport closes a report from the moderation queue as dismissed or actioned
+func ReviewMessageReport(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
+
+	reportId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || reportId <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid report ID",
+			Data: errors.ErrorModel{
+				Message:   "Report ID must be a valid number",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var body struct {
+		Status    string `json:"status"` // dismissed or actioned
+		AdminNote string `json:"admin_note"`
+	}
+	if err := c.BodyParser(&body); err != nil ||
+		(body.Status != users.ReportStatusDismissed && body.Status != users.ReportStatusActioned) {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data: errors.ErrorModel{
+				Message:   "Status must be 'dismissed' or 'actioned'",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var report users.MessageReport
+	if err := db.First(&report, "report_id = ?", reportId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Report not found",
+			Data: errors.ErrorModel{
+				Message:   "No report with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	reviewerId := int(admin.UserId)
+	now := time.Now()
+	report.Status = body.Status
+	report.AdminNote = body.AdminNote
+	report.ReviewedBy = &reviewerId
+	report.ReviewedAt = &now
+
+	if err := db.Save(&report).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to update report",
+			Data: errors.ErrorModel{
+				Message:   "Database update error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Report reviewed successfully",
+		Data:    report,
+	})
+}
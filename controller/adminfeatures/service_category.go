@@ -1,6 +1,7 @@
 package adminfeatures
 
 import (
+	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
@@ -42,6 +43,13 @@ func AddServiceCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditServiceCategoryCreate,
+		TargetType: "service_category",
+		TargetId:   service.CategoryId,
+		After:      service,
+	})
+
 	// Return final response
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
@@ -94,6 +102,8 @@ func UpdateService(c *fiber.Ctx) error {
 		})
 	}
 
+	before := existingService
+
 	// Update only specified fields
 	if err := db.Model(&existingService).Updates(service).Error; err != nil {
 		return c.JSON(response.ResponseModel{
@@ -120,6 +130,14 @@ func UpdateService(c *fiber.Ctx) error {
 		})
 	}
 
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditServiceCategoryUpdate,
+		TargetType: "service_category",
+		TargetId:   existingService.CategoryId,
+		Before:     before,
+		After:      existingService,
+	})
+
 	// Return updated service
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
@@ -0,0 +1,295 @@
+package adminfeatures
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/mailer"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// AnswerSupportTicket adds a support team message to a ticket. Body: message, internal (a note
+// only admins see) and status (pending by default, or resolved) for replies. An unassigned
+// ticket is assigned to the admin who replies.
+func AnswerSupportTicket(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
+
+	ticket, found, err := findSupportTicket(c, db)
+	if !found {
+		return err
+	}
+	if ticket.Status == users.TicketStatusClosed {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "Ticket closed",
+			Data: errors.ErrorModel{
+				Message:   "Reopen the ticket before replying",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var body struct {
+		Message  string `json:"message"`
+		Internal bool   `json:"internal"`
+		Status   string `json:"status"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return invalidTicketChange(c, "Failed to parse JSON body")
+	}
+	body.Message = strings.TrimSpace(body.Message)
+	if body.Message == "" || len(body.Message) > controller.MaxTicketMessageLength {
+		return invalidTicketChange(c, "Message must be between 1 and 5000 characters")
+	}
+	if body.Status == "" {
+		body.Status = users.TicketStatusPending
+	}
+	if !body.Internal && body.Status != users.TicketStatusPending && body.Status != users.TicketStatusResolved {
+		return invalidTicketChange(c, "status must be pending or resolved")
+	}
+
+	var message users.SupportTicketMessage
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if message, err = controller.AddTicketMessage(tx, &ticket, admin.UserId, users.RoleAdmin, body.Message, body.Internal); err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		if ticket.AssignedTo == nil {
+			adminId := int(admin.UserId)
+			ticket.AssignedTo = &adminId
+			updates["assigned_to"] = adminId
+		}
+		if !body.Internal {
+			ticket.Status = body.Status
+			updates["status"] = body.Status
+			if body.Status == users.TicketStatusResolved {
+				now := time.Now()
+				ticket.ResolvedAt = &now
+				updates["resolved_at"] = now
+			} else if ticket.ResolvedAt != nil {
+				ticket.ResolvedAt = nil
+				updates["resolved_at"] = nil
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(&users.SupportTicket{}).Where("ticket_id = ?", ticket.TicketId).Updates(updates).Error
+	})
+	if err != nil {
+		return ticketChangeFailed(c, err)
+	}
+
+	if !body.Internal {
+		title := "Support replied to your ticket"
+		if ticket.Status == users.TicketStatusResolved {
+			title = "Your support ticket has been resolved"
+		}
+		notifyTicketUser(db, ticket, title, body.Message)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Message sent",
+		Data:    message,
+	})
+}
+
+// UpdateSupportTicket changes a ticket's status, priority or assignee. Body: status (open,
+// pending, resolved or closed), priority (low, normal, high or urgent; the SLA due times are
+// recalculated from when the ticket was opened) and assigned_to (an admin ID, 0 to unassign).
+func UpdateSupportTicket(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
+
+	ticket, found, err := findSupportTicket(c, db)
+	if !found {
+		return err
+	}
+
+	var body struct {
+		Status     string `json:"status"`
+		Priority   string `json:"priority"`
+		AssignedTo *int   `json:"assigned_to"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return invalidTicketChange(c, "Failed to parse JSON body")
+	}
+
+	before := map[string]interface{}{"status": ticket.Status, "priority": ticket.Priority, "assigned_to": ticket.AssignedTo}
+	updates := map[string]interface{}{}
+	now := time.Now()
+
+	if body.Status != "" && body.Status != ticket.Status {
+		switch body.Status {
+		case users.TicketStatusOpen, users.TicketStatusPending:
+			ticket.ResolvedAt, ticket.ClosedAt = nil, nil
+			updates["resolved_at"], updates["closed_at"] = nil, nil
+		case users.TicketStatusResolved:
+			ticket.ResolvedAt = &now
+			updates["resolved_at"] = now
+		case users.TicketStatusClosed:
+			ticket.ClosedAt = &now
+			updates["closed_at"] = now
+		default:
+			return invalidTicketChange(c, "status must be open, pending, resolved or closed")
+		}
+		ticket.Status = body.Status
+		updates["status"] = body.Status
+	}
+
+	if body.Priority != "" && body.Priority != ticket.Priority {
+		if _, ok := users.TicketSLAs[body.Priority]; !ok {
+			return invalidTicketChange(c, "priority must be low, normal, high or urgent")
+		}
+		ticket.Priority = body.Priority
+		ticket.SetDueTimes()
+		updates["priority"] = ticket.Priority
+		updates["first_response_due_at"] = ticket.FirstResponseDueAt
+		updates["resolution_due_at"] = ticket.ResolutionDueAt
+	}
+	if updates["priority"] != nil || updates["status"] != nil {
+		// Due times or the resolution moment changed, so a breach may no longer apply (or now does)
+		ticket.FirstResponseBreached, ticket.ResolutionBreached = ticket.SLABreaches(now)
+		updates["first_response_breached"] = ticket.FirstResponseBreached
+		updates["resolution_breached"] = ticket.ResolutionBreached
+	}
+
+	var assignee *users.Admin
+	if body.AssignedTo != nil && !sameAssignee(ticket.AssignedTo, *body.AssignedTo) {
+		if *body.AssignedTo == 0 {
+			ticket.AssignedTo = nil
+			updates["assigned_to"] = nil
+		} else {
+			var candidate users.Admin
+			if err := db.Select("admin_id, username, email, admin_role, disabled_at").
+				First(&candidate, "admin_id = ?", *body.AssignedTo).Error; err != nil ||
+				candidate.DisabledAt != nil || !users.AdminRoleHas(candidate.AdminRole, users.PermissionSupport) {
+				return invalidTicketChange(c, "Tickets can only be assigned to active admins with support access")
+			}
+			assignee = &candidate
+			ticket.AssignedTo = body.AssignedTo
+			updates["assigned_to"] = *body.AssignedTo
+		}
+	}
+
+	if len(updates) == 0 {
+		return c.JSON(response.ResponseModel{
+			RetCode: "200",
+			Message: "Nothing to change",
+			Data:    ticket,
+		})
+	}
+	if err := db.Model(&users.SupportTicket{}).Where("ticket_id = ?", ticket.TicketId).Updates(updates).Error; err != nil {
+		return ticketChangeFailed(c, err)
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditSupportTicketUpdate,
+		TargetType: "support_ticket",
+		TargetId:   ticket.TicketId,
+		Before:     before,
+		After:      map[string]interface{}{"status": ticket.Status, "priority": ticket.Priority, "assigned_to": ticket.AssignedTo},
+	})
+
+	if assignee != nil && int(admin.UserId) != assignee.AdminId && assignee.Email != "" {
+		due := "needs a first reply by " + ticket.FirstResponseDueAt.Format("January 2, 2006 3:04 PM")
+		if ticket.FirstRespondedAt != nil {
+			due = "should be resolved by " + ticket.ResolutionDueAt.Format("January 2, 2006 3:04 PM")
+		}
+		if err := mailer.SendTemplate(db, assignee.Email, mailer.TemplateNotification, mailer.NotificationData{
+			Title: fmt.Sprintf("Support ticket #%d was assigned to you", ticket.TicketId),
+			Body:  fmt.Sprintf("%q (%s priority) %s.", ticket.Subject, ticket.Priority, due),
+		}); err != nil {
+			log.Printf("Failed to email admin %d about ticket %d: %v", assignee.AdminId, ticket.TicketId, err)
+		}
+	}
+	if updates["status"] != nil && (ticket.Status == users.TicketStatusResolved || ticket.Status == users.TicketStatusClosed) {
+		notifyTicketUser(db, ticket, "Your support ticket has been "+ticket.Status, ticket.Subject)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Ticket updated",
+		Data:    ticket,
+	})
+}
+
+func findSupportTicket(c *fiber.Ctx, db *gorm.DB) (users.SupportTicket, bool, error) {
+	var ticket users.SupportTicket
+	ticketId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || ticketId <= 0 {
+		return ticket, false, invalidTicketChange(c, "Ticket ID must be a valid number")
+	}
+	if err := db.First(&ticket, "ticket_id = ?", ticketId).Error; err != nil {
+		return ticket, false, c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Ticket not found",
+			Data: errors.ErrorModel{
+				Message:   "No ticket with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	return ticket, true, nil
+}
+
+func sameAssignee(current *int, requested int) bool {
+	if current == nil {
+		return requested == 0
+	}
+	return *current == requested
+}
+
+func notifyTicketUser(db *gorm.DB, ticket users.SupportTicket, title string, description string) {
+	if err := websocketclient.Notify(db, websocketclient.Notification{
+		Category: users.NotificationCategorySupport,
+		Type:     "Support Ticket",
+		ToUser:   ticket.UserId,
+		Title:    title,
+		Body:     description,
+		Data: map[string]string{
+			"ticket_id": strconv.FormatUint(uint64(ticket.TicketId), 10),
+			"status":    ticket.Status,
+		},
+	}); err != nil {
+		log.Printf("Failed to notify user %d about ticket %d: %v", ticket.UserId, ticket.TicketId, err)
+	}
+}
+
+func invalidTicketChange(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+		RetCode: "400",
+		Message: "Invalid Request!",
+		Data: errors.ErrorModel{
+			Message:   message,
+			IsSuccess: false,
+		},
+	})
+}
+
+func ticketChangeFailed(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Failed to update ticket",
+		Data: errors.ErrorModel{
+			Message:   "Database error",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
@@ -0,0 +1,388 @@
+package adminfeatures
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/controller/signuplogin"
+	"fixify_backend/mailer"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fixify_backend/sms"
+	"fixify_backend/websocketclient"
+	"log"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Columns an admin sees when comparing a profile before and after a change
+const userProfileColumns = "user_id, type, first_name, last_name, email, phone, gender, address, availability, category_id, account_status"
+
+type userProfileUpdate struct {
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	Email        string `json:"email"`
+	Phone        string `json:"phone"`
+	Gender       string `json:"gender"`
+	Address      string `json:"address"`
+	Availability string `json:"availability"`
+}
+
+// AdminUpdateUser edits a client's or repairman's profile. Only the fields present in the body
+// change. A new phone number has to be verified again by the user. Only super admins can change
+// the email.
+func AdminUpdateUser(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
+
+	userId, ok := managedUserId(c)
+	if !ok {
+		return invalidManagedUser(c)
+	}
+
+	var body userProfileUpdate
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data: errors.ErrorModel{
+				Message:   "Failed to parse JSON body",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	var before users.Repairman
+	if err := db.Select(userProfileColumns).First(&before, "user_id = ?", userId).Error; err != nil {
+		return managedUserNotFound(c, err)
+	}
+	if before.AccountStatus == users.AccountStatusDeleted {
+		return deletedAccountConflict(c)
+	}
+
+	updates := map[string]interface{}{}
+	fields := map[string]string{
+		"first_name":   body.FirstName,
+		"last_name":    body.LastName,
+		"gender":       body.Gender,
+		"address":      body.Address,
+		"availability": body.Availability,
+	}
+	for column, value := range fields {
+		if value = strings.TrimSpace(value); value != "" {
+			updates[column] = value
+		}
+	}
+
+	if email := strings.TrimSpace(body.Email); email != "" && !strings.EqualFold(email, before.Email) {
+		// The email is the login, so only super admins may change it. Password resets keep going
+		// to the user's verified address, and the old address is told about the change.
+		var role string
+		if err := db.Model(&users.Admin{}).Where("admin_id = ?", admin.UserId).Pluck("admin_role", &role).Error; err != nil {
+			return manageUserFailed(c, "Failed to update user", err)
+		}
+		if role != users.AdminRoleSuper {
+			return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+				RetCode: "403",
+				Message: "Only super admins can change a user's email",
+				Data: errors.ErrorModel{
+					Message:   "Your admin role does not allow changing email addresses",
+					IsSuccess: false,
+				},
+			})
+		}
+
+		addr, err := mail.ParseAddress(email)
+		if err != nil {
+			return invalidProfileUpdate(c, "Enter a valid email address")
+		}
+		email = strings.ToLower(addr.Address)
+		if email == strings.ToLower(before.Email) {
+			return invalidProfileUpdate(c, "The account already uses this email address")
+		}
+		// Another account's verified address counts too, or resets for it could reach this user
+		taken, err := signuplogin.EmailTaken(db, email, uint(userId))
+		if err != nil {
+			return manageUserFailed(c, "Failed to update user", err)
+		}
+		if taken {
+			return profileConflict(c, "Email already in use by another account")
+		}
+		updates["email"] = email
+	}
+
+	if body.Phone != "" {
+		phone, err := sms.NormalizePhone(body.Phone)
+		if err != nil {
+			return invalidProfileUpdate(c, "Enter a valid mobile number")
+		}
+		taken, err := signuplogin.PhoneTaken(db, phone, uint(userId))
+		if err != nil {
+			return manageUserFailed(c, "Failed to update user", err)
+		}
+		if taken {
+			return profileConflict(c, "Phone number already in use by another account")
+		}
+		if phone != before.Phone {
+			updates["phone"] = phone
+			updates["phone_verified_at"] = nil
+		}
+	}
+
+	if len(updates) == 0 {
+		return invalidProfileUpdate(c, "At least one field must be provided for update")
+	}
+
+	if err := db.Model(&users.User{}).Where("user_id = ?", userId).Updates(updates).Error; err != nil {
+		return manageUserFailed(c, "Failed to update user", err)
+	}
+
+	var after users.Repairman
+	db.Select(userProfileColumns).First(&after, "user_id = ?", userId)
+
+	if newEmail, ok := updates["email"].(string); ok {
+		if err := mailer.SendTemplate(db, before.Email, mailer.TemplateNotification, mailer.NotificationData{
+			Title: "Your Fixify email address was changed",
+			Body: "Our support team changed the email address of your Fixify account to " + newEmail +
+				". If you did not ask for this, reply to this email or contact support right away.",
+		}); err != nil {
+			log.Printf("Failed to tell user %d about their email change: %v", userId, err)
+		}
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAccountUpdate,
+		TargetType: users.RoleUser,
+		TargetId:   userId,
+		Before:     before,
+		After:      after,
+	})
+
+	log.Printf("Admin %d updated the profile of user %d", admin.UserId, userId)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "User updated",
+		Data:    after,
+	})
+}
+
+// ForceUserPasswordReset makes a user choose a new password: the current one stops working, every
+// session is signed out and a reset code is emailed to their verified address
+func ForceUserPasswordReset(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
+
+	userId, ok := managedUserId(c)
+	if !ok {
+		return invalidManagedUser(c)
+	}
+
+	var user users.User
+	if err := db.Select("user_id, email, verified_email, account_status").First(&user, "user_id = ?", userId).Error; err != nil {
+		return managedUserNotFound(c, err)
+	}
+	if user.AccountStatus == users.AccountStatusDeleted {
+		return deletedAccountConflict(c)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// An empty hash never matches a bcrypt comparison, so only the reset code can sign them in
+		if err := tx.Model(&users.User{}).Where("user_id = ?", userId).Update("password", "").Error; err != nil {
+			return err
+		}
+		return signuplogin.RevokeSessions(tx, users.RoleUser, uint(userId))
+	})
+	if err != nil {
+		return manageUserFailed(c, "Failed to reset password", err)
+	}
+	websocketclient.HubInstance.Disconnect(uint(userId))
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAccountForceReset,
+		TargetType: users.RoleUser,
+		TargetId:   userId,
+	})
+
+	if err := signuplogin.StartPasswordReset(db, users.RoleUser, uint(userId)); err != nil {
+		log.Printf("Failed to send forced password reset to user %d: %v", userId, err)
+		return manageUserFailed(c, "Password cleared, but the reset email could not be sent. Try again.", err)
+	}
+
+	log.Printf("Admin %d forced a password reset for user %d", admin.UserId, userId)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Password reset sent to " + user.ResetEmail(),
+	})
+}
+
+// ChangeUserType converts a client to a repairman or back. Repairmen need a service category
+// (category_id); a repairman with pending or in-progress jobs can't become a client until
+// they are finished.
+func ChangeUserType(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
+
+	userId, ok := managedUserId(c)
+	if !ok {
+		return invalidManagedUser(c)
+	}
+
+	var body struct {
+		Type       string `json:"type"`
+		CategoryId int    `json:"category_id"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return invalidProfileUpdate(c, "Failed to parse JSON body")
+	}
+
+	var user users.Repairman
+	if err := db.Select(userProfileColumns).First(&user, "user_id = ?", userId).Error; err != nil {
+		return managedUserNotFound(c, err)
+	}
+	if user.AccountStatus == users.AccountStatusDeleted {
+		return deletedAccountConflict(c)
+	}
+
+	updates := map[string]interface{}{}
+	switch body.Type {
+	case "Repairman":
+		var category users.ServiceCategory
+		if body.CategoryId <= 0 || db.First(&category, "category_id = ? AND is_active = ?", body.CategoryId, true).Error != nil {
+			return invalidProfileUpdate(c, "Choose an active service category for the repairman")
+		}
+		updates["category_id"] = body.CategoryId
+	case "Client":
+		var active int64
+		if err := db.Model(&users.ServiceRequest{}).
+			Where("fixer_id = ? AND status IN ?", userId, []string{"pending", "in progress"}).
+			Count(&active).Error; err != nil {
+			return manageUserFailed(c, "Failed to change account type", err)
+		}
+		if active > 0 {
+			return profileConflict(c, "This repairman still has pending or in-progress jobs")
+		}
+		updates["category_id"] = 0
+	default:
+		return invalidProfileUpdate(c, "Type must be Client or Repairman")
+	}
+	if body.Type == user.Type && (body.Type == "Client" || body.CategoryId == user.CategoryId) {
+		return invalidProfileUpdate(c, "The account is already a "+body.Type)
+	}
+	updates["type"] = body.Type
+
+	if err := db.Model(&users.User{}).Where("user_id = ?", userId).Updates(updates).Error; err != nil {
+		return manageUserFailed(c, "Failed to change account type", err)
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAccountTypeChange,
+		TargetType: users.RoleUser,
+		TargetId:   userId,
+		Before:     map[string]interface{}{"type": user.Type, "category_id": user.CategoryId},
+		After:      updates,
+	})
+
+	if body.Type != user.Type {
+		description := "Your account is now a client account."
+		if body.Type == "Repairman" {
+			description = "Your account is now a repairman account. Clients can book you once your ID is verified."
+		}
+		if err := websocketclient.Notify(db, websocketclient.Notification{
+			Category: users.NotificationCategoryAccount,
+			Type:     "Account Type",
+			ToUser:   uint(userId),
+			Title:    "Your account type has changed",
+			Body:     description,
+			Data: map[string]string{
+				"type": body.Type,
+			},
+		}); err != nil {
+			log.Printf("Failed to notify user %d about account type change: %v", userId, err)
+		}
+	}
+
+	log.Printf("Admin %d changed user %d from %s to %s", admin.UserId, userId, user.Type, body.Type)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Account type changed",
+		Data: fiber.Map{
+			"user_id":     userId,
+			"type":        body.Type,
+			"category_id": updates["category_id"],
+		},
+	})
+}
+
+func managedUserId(c *fiber.Ctx) (int, bool) {
+	userId, err := strconv.Atoi(c.Params("id"))
+	return userId, err == nil && userId > 0
+}
+
+func invalidManagedUser(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+		RetCode: "400",
+		Message: "Invalid user ID",
+		Data: errors.ErrorModel{
+			Message:   "User ID must be a valid number",
+			IsSuccess: false,
+		},
+	})
+}
+
+func managedUserNotFound(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+		RetCode: "404",
+		Message: "User not found",
+		Data: errors.ErrorModel{
+			Message:   "No user with the given ID",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
+
+func deletedAccountConflict(c *fiber.Ctx) error {
+	return profileConflict(c, "This account has been deleted")
+}
+
+func invalidProfileUpdate(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+		RetCode: "400",
+		Message: "Invalid Request!",
+		Data: errors.ErrorModel{
+			Message:   message,
+			IsSuccess: false,
+		},
+	})
+}
+
+func profileConflict(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+		RetCode: "409",
+		Message: message,
+		Data: errors.ErrorModel{
+			Message:   message,
+			IsSuccess: false,
+		},
+	})
+}
+
+func manageUserFailed(c *fiber.Ctx, message string, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: message,
+		Data: errors.ErrorModel{
+			Message:   "An error occurred while processing your request",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
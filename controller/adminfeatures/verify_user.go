@@ -1,39 +1,72 @@
 package adminfeatures
 
 import (
+	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
+	"log"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-func VerifyUser(c *fiber.Ctx) error {
-	db := middleware.DBConn
+const maxRejectionReasonLength = 500
 
-	// Parse user_id from URL params
-	userIDParam := c.Params("id")
-	userID, err := strconv.Atoi(userIDParam)
-	if err != nil {
+type verificationDecision struct {
+	Status string `json:"status"` // users.VerificationStatusApproved or users.VerificationStatusRejected
+	Reason string `json:"reason"` // Required when rejecting; shown to the user
+}
+
+// ReviewVerification approves or rejects a pending ID submission from the review queue
+func ReviewVerification(c *fiber.Ctx) error {
+	verificationId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || verificationId <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid verification ID",
+			Data: errors.ErrorModel{
+				Message:   "Verification ID must be a valid number",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	return reviewVerification(c, func(db *gorm.DB) *gorm.DB {
+		return db.Where("verification_id = ?", verificationId)
+	})
+}
+
+// VerifyUser is the older endpoint keyed by user ID. It reviews the user's pending submission.
+func VerifyUser(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID <= 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
 			RetCode: "400",
 			Message: "Invalid user ID",
 			Data: errors.ErrorModel{
 				Message:   "User ID must be a valid number",
 				IsSuccess: false,
-				Error:     err.Error(),
 			},
 		})
 	}
 
-	// Define request body
-	type RequestBody struct {
-		Status string `json:"status"` // e.g., "pending", "approved", "rejected"
-	}
+	return reviewVerification(c, func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND status = ?", userID, users.VerificationStatusPending).
+			Order("verification_id DESC")
+	})
+}
+
+func reviewVerification(c *fiber.Ctx, find func(db *gorm.DB) *gorm.DB) error {
+	db := middleware.DBConn
+	admin := c.Locals("user").(*users.Claims)
 
-	var body RequestBody
+	var body verificationDecision
 	if err := c.BodyParser(&body); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
 			RetCode: "400",
@@ -46,25 +79,97 @@ func VerifyUser(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if user verification record exists
-	var userVer users.UserVerification
-	if err := db.Where("user_id = ?", userID).First(&userVer).Error; err != nil {
+	body.Status = strings.ToLower(strings.TrimSpace(body.Status))
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Status != users.VerificationStatusApproved && body.Status != users.VerificationStatusRejected {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid status",
+			Data: errors.ErrorModel{
+				Message:   "Status must be approved or rejected",
+				IsSuccess: false,
+			},
+		})
+	}
+	if body.Status == users.VerificationStatusRejected && body.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Rejection reason required",
+			Data: errors.ErrorModel{
+				Message:   "Tell the user why their documents were rejected",
+				IsSuccess: false,
+			},
+		})
+	}
+	if len(body.Reason) > maxRejectionReasonLength {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Rejection reason too long",
+			Data: errors.ErrorModel{
+				Message:   "Rejection reason must be at most 500 characters",
+				IsSuccess: false,
+			},
+		})
+	}
+	if body.Status == users.VerificationStatusApproved {
+		body.Reason = ""
+	}
+
+	var verification users.UserVerification
+	if err := find(db).Omit("valid_id", "selfie", "back_id").First(&verification).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
 			RetCode: "404",
 			Message: "User verification record not found",
 			Data: errors.ErrorModel{
-				Message:   "No user verification entry found for the given user ID",
+				Message:   "No pending verification found",
 				IsSuccess: false,
 				Error:     err.Error(),
 			},
 		})
 	}
 
-	// Update the verification status
-	err = db.Model(&users.UserVerification{}).
-		Where("user_id = ?", userID).
-		Update("status", body.Status).Error
+	now := time.Now()
+	reviewerId := int(admin.UserId)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Only a pending submission can be decided, and only once
+		result := tx.Model(&users.UserVerification{}).
+			Where("verification_id = ? AND status = ?", verification.VerificationId, users.VerificationStatusPending).
+			Updates(map[string]interface{}{
+				"status":           body.Status,
+				"rejection_reason": body.Reason,
+				"reviewed_at":      now,
+				"reviewed_by":      reviewerId,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
 
+		// An approved resubmission (e.g. a renewed document) replaces the earlier approval
+		if body.Status == users.VerificationStatusApproved {
+			if err := tx.Model(&users.UserVerification{}).
+				Where("user_id = ? AND status = ? AND verification_id <> ?", verification.UserId, users.VerificationStatusApproved, verification.VerificationId).
+				Update("status", users.VerificationStatusSuperseded).Error; err != nil {
+				return err
+			}
+		}
+
+		// Keep the status shown on repairman profiles in step with the latest decision
+		return tx.Model(&users.Repairman{}).Where("user_id = ?", verification.UserId).
+			Update("verification_status", body.Status).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "Already reviewed",
+			Data: errors.ErrorModel{
+				Message:   "This submission is no longer pending",
+				IsSuccess: false,
+			},
+		})
+	}
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
 			RetCode: "500",
@@ -77,12 +182,51 @@ func VerifyUser(c *fiber.Ctx) error {
 		})
 	}
 
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditVerificationReview,
+		TargetType: "user_verification",
+		TargetId:   verification.VerificationId,
+		Before: map[string]interface{}{
+			"user_id": verification.UserId,
+			"status":  verification.Status,
+		},
+		After: map[string]interface{}{
+			"user_id":          verification.UserId,
+			"status":           body.Status,
+			"rejection_reason": body.Reason,
+		},
+	})
+
+	verification.Status = body.Status
+	verification.RejectionReason = body.Reason
+	verification.ReviewedAt = &now
+	verification.ReviewedBy = &reviewerId
+
+	title := "Your ID has been verified"
+	description := "Your identity documents were approved. Your account is now verified."
+	if body.Status == users.VerificationStatusRejected {
+		title = "Your ID verification was rejected"
+		description = "Your identity documents were rejected: " + body.Reason + ". Please upload new documents to try again."
+	}
+	if err := websocketclient.Notify(db, websocketclient.Notification{
+		Category: users.NotificationCategoryAccount,
+		Type:     "Account Verification",
+		ToUser:   verification.UserId,
+		Title:    title,
+		Body:     description,
+		Data: map[string]string{
+			"verification_id": strconv.Itoa(int(verification.VerificationId)),
+			"status":          body.Status,
+		},
+	}); err != nil {
+		log.Printf("Failed to notify user %d about verification %d: %v", verification.UserId, verification.VerificationId, err)
+	}
+
+	log.Printf("Admin %d %s verification %d of user %d", reviewerId, body.Status, verification.VerificationId, verification.UserId)
+
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "User verification status updated successfully",
-		Data: fiber.Map{
-			"user_id": userID,
-			"status":  body.Status,
-		},
+		Data:    verification,
 	})
 }
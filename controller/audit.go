@@ -0,0 +1,115 @@
+package controller
+
+import (
+	"encoding/json"
+	"fixify_backend/model/users"
+	"fmt"
+	"log"
+	"reflect"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Audit describes one change for RecordAudit
+type Audit struct {
+	Action     string      // One of the users.Audit* actions
+	TargetType string      // e.g. "service_category", "user"
+	TargetId   interface{} // ID of the changed record
+	Before     interface{} // State before the change (struct or map); nil when something was created
+	After      interface{} // State after the change; nil when something was deleted
+
+	// The actor defaults to the logged-in caller. Set these for actions taken without a token,
+	// such as a password reset.
+	ActorId   uint
+	ActorRole string
+}
+
+// auditRedacted fields are recorded as changed without their values
+var auditRedacted = map[string]bool{
+	"password":        true,
+	"totp_secret":     true,
+	"fcm_token":       true,
+	"valid_id":        true,
+	"selfie_id":       true,
+	"back_id":         true,
+	"profile_picture": true,
+}
+
+const auditRedactedValue = "[redacted]"
+
+// RecordAudit appends an entry to the audit log with the fields that differ between Before and
+// After. A failure to write the entry is logged; the change itself has already been made.
+func RecordAudit(c *fiber.Ctx, db *gorm.DB, audit Audit) {
+	entry := users.AuditLog{
+		ActorId:    audit.ActorId,
+		ActorRole:  audit.ActorRole,
+		Action:     audit.Action,
+		TargetType: audit.TargetType,
+		TargetId:   fmt.Sprint(audit.TargetId),
+		Changes:    auditDiff(audit.Before, audit.After),
+		IP:         c.IP(),
+		UserAgent:  c.Get(fiber.HeaderUserAgent),
+	}
+	if claims, ok := c.Locals("user").(*users.Claims); ok && entry.ActorRole == "" {
+		entry.ActorId = claims.UserId
+		entry.ActorRole = claims.Role
+		if entry.ActorRole == "" {
+			entry.ActorRole = users.RoleUser
+		}
+	}
+	if len(entry.UserAgent) > 255 {
+		entry.UserAgent = entry.UserAgent[:255]
+	}
+
+	if err := db.Create(&entry).Error; err != nil {
+		log.Printf("Failed to write audit log %s on %s %s: %v", entry.Action, entry.TargetType, entry.TargetId, err)
+	}
+}
+
+func auditDiff(before interface{}, after interface{}) users.AuditChanges {
+	from, to := auditFields(before), auditFields(after)
+	changes := users.AuditChanges{}
+
+	for field, value := range to {
+		old, existed := from[field]
+		if existed && reflect.DeepEqual(old, value) {
+			continue
+		}
+		changes[field] = users.AuditChange{From: old, To: value}
+	}
+	// A deletion records everything that was removed
+	if after == nil {
+		for field, old := range from {
+			changes[field] = users.AuditChange{From: old}
+		}
+	}
+
+	for field, change := range changes {
+		if auditRedacted[field] {
+			changes[field] = users.AuditChange{From: redactAuditValue(change.From), To: redactAuditValue(change.To)}
+		}
+	}
+	return changes
+}
+
+// auditFields flattens a struct or map to its JSON fields so both can be compared
+func auditFields(state interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if state == nil {
+		return fields
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+func redactAuditValue(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return auditRedactedValue
+}
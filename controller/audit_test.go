@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"testing"
+
+	"fixify_backend/model/users"
+)
+
+func TestAuditDiffRecordsOnlyChangedFields(t *testing.T) {
+	before := map[string]interface{}{"first_name": "Ana", "address": "Cebu", "phone": "09171234567"}
+	after := map[string]interface{}{"first_name": "Ana", "address": "Manila", "phone": "09171234567"}
+
+	changes := auditDiff(before, after)
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1: %v", len(changes), changes)
+	}
+	if got := changes["address"]; got.From != "Cebu" || got.To != "Manila" {
+		t.Errorf("address change = %+v", got)
+	}
+}
+
+func TestAuditDiffComparesStructsAndMaps(t *testing.T) {
+	before := users.ServiceCategory{CategoryId: 3, CategoryName: "Plumbing", Is_active: true}
+	after := map[string]interface{}{"category_name": "Plumbing", "is_active": false}
+
+	changes := auditDiff(before, after)
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1: %v", len(changes), changes)
+	}
+	if got := changes["is_active"]; got.From != true || got.To != false {
+		t.Errorf("is_active change = %+v", got)
+	}
+}
+
+func TestAuditDiffCreationAndDeletion(t *testing.T) {
+	created := auditDiff(nil, map[string]interface{}{"category_name": "Roofing"})
+	if got := created["category_name"]; got.From != nil || got.To != "Roofing" {
+		t.Errorf("creation change = %+v", got)
+	}
+
+	deleted := auditDiff(map[string]interface{}{"category_name": "Roofing", "description": "Leaks"}, nil)
+	if len(deleted) != 2 {
+		t.Fatalf("deletion recorded %d fields, want 2", len(deleted))
+	}
+	if got := deleted["description"]; got.From != "Leaks" || got.To != nil {
+		t.Errorf("deletion change = %+v", got)
+	}
+}
+
+func TestAuditDiffRedactsSecrets(t *testing.T) {
+	changes := auditDiff(
+		map[string]interface{}{"password": "$2a$10$old", "totp_secret": ""},
+		map[string]interface{}{"password": "$2a$10$new", "totp_secret": "JBSWY3DPEHPK3PXP"},
+	)
+	for _, field := range []string{"password", "totp_secret"} {
+		change, ok := changes[field]
+		if !ok {
+			t.Errorf("%s change was not recorded", field)
+			continue
+		}
+		if change.To != auditRedactedValue {
+			t.Errorf("%s recorded as %+v, want the value redacted", field, change)
+		}
+	}
+	if changes["password"].From != auditRedactedValue {
+		t.Errorf("old password recorded as %v", changes["password"].From)
+	}
+}
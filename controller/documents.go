@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"fixify_backend/model/users"
+	"fixify_backend/vault"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SealVerificationDocuments encrypts the ID images of a new submission in place
+func SealVerificationDocuments(verification *users.UserVerification) error {
+	ring, err := vault.Default()
+	if err != nil {
+		return err
+	}
+
+	for _, image := range []*[]byte{&verification.ValidId, &verification.Selfie, &verification.BackId} {
+		sealed, err := ring.Seal(*image)
+		if err != nil {
+			return err
+		}
+		*image = sealed
+	}
+	verification.KeyId = ring.ActiveKeyID()
+	return nil
+}
+
+// OpenVerificationDocuments decrypts the ID images of a loaded submission in place and records
+// the view in the document access log. The log is written first so a view is never unrecorded.
+func OpenVerificationDocuments(c *fiber.Ctx, db *gorm.DB, verification *users.UserVerification, claims *users.Claims) error {
+	if err := RecordDocumentAccess(c, db, *verification, claims); err != nil {
+		return err
+	}
+	return DecryptVerificationDocuments(verification)
+}
+
+// RecordDocumentAccess writes the document access log entry for viewing a submission's images
+func RecordDocumentAccess(c *fiber.Ctx, db *gorm.DB, verification users.UserVerification, claims *users.Claims) error {
+	role := claims.Role
+	if role == "" {
+		role = users.RoleUser
+	}
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+
+	return db.Create(&users.DocumentAccessLog{
+		VerificationId: verification.VerificationId,
+		OwnerId:        verification.UserId,
+		ViewerId:       claims.UserId,
+		ViewerRole:     role,
+		IP:             c.IP(),
+		UserAgent:      userAgent,
+	}).Error
+}
+
+// DecryptVerificationDocuments decrypts the ID images of a loaded submission in place. Callers
+// must have recorded the access with RecordDocumentAccess.
+func DecryptVerificationDocuments(verification *users.UserVerification) error {
+	for _, image := range []*[]byte{&verification.ValidId, &verification.Selfie, &verification.BackId} {
+		opened, err := vault.Open(*image)
+		if err != nil {
+			return err
+		}
+		*image = opened
+	}
+	return nil
+}
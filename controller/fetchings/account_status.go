@@ -0,0 +1,44 @@
+package fetchings
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FetchRestrictedUsers lists banned users and users whose suspension has not ended yet. Use
+// ?status=suspended or ?status=banned for one kind only.
+func FetchRestrictedUsers(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	query := db.Omit("profile_picture").
+		Where("account_status = ? OR (account_status = ? AND suspended_until > ?)",
+			users.AccountStatusBanned, users.AccountStatusSuspended, time.Now()).
+		Order("updatedat DESC")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("account_status = ?", status)
+	}
+
+	var restricted []users.Repairman
+	if err := query.Find(&restricted).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    restricted,
+	})
+}
@@ -0,0 +1,68 @@
+package fetchings
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// FetchAdminTeam lists the admin accounts with their roles, plus what each role may do
+func FetchAdminTeam(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	var admins []users.Admin
+	if err := db.Select("admin_id, username, email, admin_role, disabled_at, totp_enabled, createdat, updatedat").
+		Order("admin_id").Find(&admins).Error; err != nil {
+		return adminTeamError(c, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"admins": admins,
+			"roles":  users.AdminRolePermissions,
+		},
+	})
+}
+
+// FetchAdminInvites lists pending admin invites, newest first. ?status=all includes used,
+// revoked and expired ones.
+func FetchAdminInvites(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	query := db.Preload("Inviter", func(db *gorm.DB) *gorm.DB {
+		return db.Select("admin_id, username, email")
+	}).Order("created_at DESC")
+	if c.Query("status") != "all" {
+		query = query.Where("accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", time.Now())
+	}
+
+	var invites []users.AdminInvite
+	if err := query.Find(&invites).Error; err != nil {
+		return adminTeamError(c, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    invites,
+	})
+}
+
+func adminTeamError(c *fiber.Ctx, err error) error {
+	return c.JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Request failed",
+		Data: errors.ErrorModel{
+			Message:   "Failed to fetch data from database",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
@@ -27,6 +27,11 @@ func FetchAllAdmin(c *fiber.Ctx) error {
 		})
 	}
 
+	// Never send password hashes
+	for i := range admins {
+		admins[i].Password = ""
+	}
+
 	// Return the fetched data
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
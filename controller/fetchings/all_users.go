@@ -1,6 +1,7 @@
 package fetchings
 
 import (
+	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
@@ -54,6 +55,13 @@ func FetchUser(c *fiber.Ctx) error {
 		})
 	}
 
+	if user.Type == "Repairman" {
+		repairmen := []users.Repairman{user}
+		if err := controller.MarkVerifiedRepairmen(db, repairmen); err == nil {
+			user = repairmen[0]
+		}
+	}
+
 	// Return the fetched user
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
@@ -0,0 +1,167 @@
+package fetchings
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+const maxAuditExportRows = 10000
+
+// FetchAuditLog lists audit log entries, newest first. Filter with ?actor_id=, ?actor_role=,
+// ?action=, ?target_type=, ?target_id=, and ?from= / ?to= (YYYY-MM-DD, inclusive). Paged with
+// ?limit= (100 by default, at most 500) and ?offset=.
+func FetchAuditLog(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	query, err := auditLogQuery(c, db)
+	if err != nil {
+		return invalidAuditFilter(c, err)
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return auditLogError(c, err)
+	}
+
+	var entries []users.AuditLog
+	if err := query.Order("created_at DESC, audit_id DESC").Limit(limit).Offset(offset).Find(&entries).Error; err != nil {
+		return auditLogError(c, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"entries": entries,
+			"total":   total,
+			"limit":   limit,
+			"offset":  offset,
+		},
+	})
+}
+
+// ExportAuditLog downloads the entries matching the FetchAuditLog filters as CSV, newest first
+func ExportAuditLog(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	query, err := auditLogQuery(c, db)
+	if err != nil {
+		return invalidAuditFilter(c, err)
+	}
+
+	var entries []users.AuditLog
+	if err := query.Order("created_at DESC, audit_id DESC").Limit(maxAuditExportRows).Find(&entries).Error; err != nil {
+		return auditLogError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="audit-log-`+time.Now().Format("20060102-150405")+`.csv"`)
+
+	writer := csv.NewWriter(c.Response().BodyWriter())
+	writer.Write([]string{"audit_id", "created_at", "actor_id", "actor_role", "action", "target_type", "target_id", "changes", "ip", "user_agent"})
+	for _, entry := range entries {
+		changes, _ := json.Marshal(entry.Changes)
+		writer.Write([]string{
+			strconv.FormatUint(uint64(entry.AuditId), 10),
+			entry.CreatedAt.Format(time.RFC3339),
+			strconv.FormatUint(uint64(entry.ActorId), 10),
+			csvCell(entry.ActorRole),
+			csvCell(entry.Action),
+			csvCell(entry.TargetType),
+			csvCell(entry.TargetId),
+			csvCell(string(changes)),
+			csvCell(entry.IP),
+			csvCell(entry.UserAgent),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell keeps spreadsheet apps from running a value as a formula. Values such as the user agent
+// are sent by clients, so anything starting with = + - @ or a tab or carriage return is prefixed
+// with a quote.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func auditLogQuery(c *fiber.Ctx, db *gorm.DB) (*gorm.DB, error) {
+	query := db.Model(&users.AuditLog{})
+	if id := c.QueryInt("actor_id"); id > 0 {
+		query = query.Where("actor_id = ?", id)
+	}
+	if role := c.Query("actor_role"); role != "" {
+		query = query.Where("actor_role = ?", role)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetId := c.Query("target_id"); targetId != "" {
+		query = query.Where("target_id = ?", targetId)
+	}
+	if from := c.Query("from"); from != "" {
+		day, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("created_at >= ?", day)
+	}
+	if to := c.Query("to"); to != "" {
+		day, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("created_at < ?", day.AddDate(0, 0, 1))
+	}
+	// A new session so the count and the page are separate queries
+	return query.Session(&gorm.Session{}), nil
+}
+
+func invalidAuditFilter(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+		RetCode: "400",
+		Message: "Invalid date filter",
+		Data: errors.ErrorModel{
+			Message:   "from and to must be dates in YYYY-MM-DD format",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
+
+func auditLogError(c *fiber.Ctx, err error) error {
+	return c.JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Request failed",
+		Data: errors.ErrorModel{
+			Message:   "Failed to fetch data from database",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
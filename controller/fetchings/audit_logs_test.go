@@ -0,0 +1,23 @@
+package fetchings
+
+import "testing"
+
+func TestCSVCellNeutralisesFormulas(t *testing.T) {
+	tests := map[string]string{
+		"=HYPERLINK(\"http://evil\")": "'=HYPERLINK(\"http://evil\")",
+		"+1+1":                        "'+1+1",
+		"-2+3":                        "'-2+3",
+		"@SUM(A1)":                    "'@SUM(A1)",
+		"\t=1":                        "'\t=1",
+		"\r=1":                        "'\r=1",
+		"Mozilla/5.0 (Linux)":         "Mozilla/5.0 (Linux)",
+		"user.update":                 "user.update",
+		"a=b":                         "a=b",
+		"":                            "",
+	}
+	for in, want := range tests {
+		if got := csvCell(in); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -14,6 +14,8 @@ func Conversations(c *fiber.Ctx) error {
 	var conversations []users.ClientRepairmanConversation
 
 	err := db.Preload("Client").Preload("Repairman").
+		Preload("Request", requestSummary).
+		Preload("Request.ServiceCategory").
 		Order("created_at DESC").
 		Find(&conversations).Error
 
@@ -4,15 +4,37 @@ import (
 	"fixify_backend/middleware"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
+const (
+	defaultSyncLimit = 200
+	maxSyncLimit     = 500
+)
+
+// requestSummary limits a preloaded service request to what the conversation list shows
+func requestSummary(db *gorm.DB) *gorm.DB {
+	return db.Select("request_id, category_id, status, description, request_date, completion_date")
+}
+
 func FetchClientRepairmanMessages(c *fiber.Ctx) error {
 	db := middleware.DBConn
 	conversationID := c.Query("conversation_id")
 
+	claims, ok := c.Locals("user").(*users.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Unauthorized",
+			Data:    fiber.Map{"success": false},
+		})
+	}
+
 	if conversationID == "" {
 		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
 			RetCode: "400",
@@ -21,6 +43,17 @@ func FetchClientRepairmanMessages(c *fiber.Ctx) error {
 		})
 	}
 
+	// Only the client and repairman of the conversation may read it
+	var conversation users.ClientRepairmanConversation
+	if err := db.First(&conversation, "conversation_id = ?", conversationID).Error; err != nil ||
+		claims.IsAdmin() || !conversation.HasParticipant(claims.UserId) {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Conversation not found",
+			Data:    fiber.Map{"success": false},
+		})
+	}
+
 	var messages []users.ClientRepairmanMessage
 
 	if err := db.Preload("Conversation.Client", func(db *gorm.DB) *gorm.DB {
@@ -32,6 +65,7 @@ func FetchClientRepairmanMessages(c *fiber.Ctx) error {
 		Preload("Sender", func(db *gorm.DB) *gorm.DB {
 			return db.Select("user_id, first_name, last_name")
 		}).
+		Preload("Attachment").
 		Where("conversation_id = ?", conversationID).
 		Order("created_at ASC").
 		Find(&messages).Error; err != nil {
@@ -55,15 +89,17 @@ func FetchClientRepairmanMessages(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// FetchClientRepairmanConversations lists the authenticated user's conversations, whether they
+// take part as the client or as the repairman.
 func FetchClientRepairmanConversations(c *fiber.Ctx) error {
 	db := middleware.DBConn
-	clientID := c.Query("client_id")
-	repairmanID := c.Query("repairman_id")
 
-	if clientID == "" && repairmanID == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
-			RetCode: "400",
-			Message: "client_id or repairman_id is required",
+	claims, ok := c.Locals("user").(*users.Claims)
+	if !ok || claims.IsAdmin() {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Unauthorized",
 			Data:    fiber.Map{"success": false},
 		})
 	}
@@ -76,13 +112,11 @@ func FetchClientRepairmanConversations(c *fiber.Ctx) error {
 		Preload("Repairman", func(db *gorm.DB) *gorm.DB {
 			return db.Select("user_id, first_name, last_name")
 		}).
+		Preload("Request", requestSummary).
+		Preload("Request.ServiceCategory").
 		Order("created_at DESC")
 
-	if clientID != "" {
-		query = query.Where("client_id = ?", clientID)
-	} else {
-		query = query.Where("repairman_id = ?", repairmanID)
-	}
+	query = query.Where("client_id = ? OR repairman_id = ?", claims.UserId, claims.UserId)
 
 	if err := query.Find(&conversations).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
@@ -105,3 +139,149 @@ func FetchClientRepairmanConversations(c *fiber.Ctx) error {
 		},
 	})
 }
+
+// SyncClientRepairmanMessages returns the messages the authenticated user missed while offline.
+// Apps pass the last message_id they hold (since_id) or a timestamp (since, RFC3339) and page
+// through the result until has_more is false.
+func SyncClientRepairmanMessages(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	claims, ok := c.Locals("user").(*users.Claims)
+	if !ok || claims.IsAdmin() {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Unauthorized",
+			Data:    fiber.Map{"success": false},
+		})
+	}
+
+	sinceID, err := strconv.ParseUint(c.Query("since_id", "0"), 10, 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "since_id must be a valid number",
+			Data:    fiber.Map{"success": false},
+		})
+	}
+
+	limit := c.QueryInt("limit", defaultSyncLimit)
+	if limit <= 0 || limit > maxSyncLimit {
+		limit = maxSyncLimit
+	}
+
+	// Only conversations the user takes part in
+	query := db.Preload("Sender", func(db *gorm.DB) *gorm.DB {
+		return db.Select("user_id, first_name, last_name")
+	}).
+		Preload("Attachment").
+		Joins("JOIN client_repairman_conversations ON client_repairman_conversations.conversation_id = client_repairman_messages.conversation_id").
+		Where("client_repairman_conversations.client_id = ? OR client_repairman_conversations.repairman_id = ?", claims.UserId, claims.UserId).
+		Where("client_repairman_messages.message_id > ?", sinceID)
+
+	if since := c.Query("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+				RetCode: "400",
+				Message: "since must be an RFC3339 timestamp",
+				Data:    fiber.Map{"success": false},
+			})
+		}
+		query = query.Where("client_repairman_messages.created_at > ?", sinceTime)
+	}
+
+	if conversationID := c.Query("conversation_id"); conversationID != "" {
+		query = query.Where("client_repairman_messages.conversation_id = ?", conversationID)
+	}
+
+	// Fetch one extra row to know whether another page exists
+	var messages []users.ClientRepairmanMessage
+	if err := query.Order("client_repairman_messages.message_id ASC").
+		Limit(limit + 1).
+		Find(&messages).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to sync messages",
+			Data: fiber.Map{
+				"success": false,
+				"error":   err.Error(),
+			},
+		})
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[:limit]
+	}
+
+	lastMessageID := uint(sinceID)
+	if len(messages) > 0 {
+		lastMessageID = messages[len(messages)-1].MessageId
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"type":            "client_repairman_messages_sync",
+			"message_count":   len(messages),
+			"messages":        messages,
+			"last_message_id": lastMessageID,
+			"has_more":        hasMore,
+		},
+	})
+}
+
+// FetchChatAttachment streams an attachment to its uploader or to a participant of a
+// conversation in which it was sent.
+func FetchChatAttachment(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	claims, ok := c.Locals("user").(*users.Claims)
+	if !ok || claims.IsAdmin() {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Unauthorized",
+			Data:    fiber.Map{"success": false},
+		})
+	}
+
+	var attachment users.ChatAttachment
+	if err := db.First(&attachment, "attachment_id = ?", c.Params("id")).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Attachment not found",
+			Data:    fiber.Map{"success": false},
+		})
+	}
+
+	if attachment.UploaderId != claims.UserId {
+		var count int64
+		err := db.Model(&users.ClientRepairmanMessage{}).
+			Joins("JOIN client_repairman_conversations ON client_repairman_conversations.conversation_id = client_repairman_messages.conversation_id").
+			Where("client_repairman_messages.attachment_id = ?", attachment.AttachmentId).
+			Where("client_repairman_conversations.client_id = ? OR client_repairman_conversations.repairman_id = ?", claims.UserId, claims.UserId).
+			Count(&count).Error
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to fetch attachment",
+				Data: fiber.Map{
+					"success": false,
+					"error":   err.Error(),
+				},
+			})
+		}
+		if count == 0 {
+			return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+				RetCode: "403",
+				Message: "You do not have access to this attachment",
+				Data:    fiber.Map{"success": false},
+			})
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, attachment.ContentType)
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", attachment.FileName))
+	return c.Send(attachment.Data)
+}
@@ -0,0 +1,55 @@
+package fetchings
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FetchDocumentAccessLog lists who viewed identity documents, newest first. Filter with
+// ?verification_id=, ?owner_id= (the user the documents belong to) or ?viewer_id= with
+// ?viewer_role=. At most ?limit= rows are returned (200 by default).
+func FetchDocumentAccessLog(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	limit := c.QueryInt("limit", 200)
+	if limit <= 0 || limit > 1000 {
+		limit = 200
+	}
+
+	query := db.Order("accessed_at DESC").Limit(limit)
+	if id := c.QueryInt("verification_id"); id > 0 {
+		query = query.Where("verification_id = ?", id)
+	}
+	if id := c.QueryInt("owner_id"); id > 0 {
+		query = query.Where("owner_id = ?", id)
+	}
+	if id := c.QueryInt("viewer_id"); id > 0 {
+		query = query.Where("viewer_id = ?", id)
+	}
+	if role := c.Query("viewer_role"); role != "" {
+		query = query.Where("viewer_role = ?", role)
+	}
+
+	var accesses []users.DocumentAccessLog
+	if err := query.Find(&accesses).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    accesses,
+	})
+}
@@ -0,0 +1,44 @@
+package fetchings
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FetchLoginLocks lists accounts and IPs that are currently locked out after failed logins.
+// ?all=true also includes identifiers that have failures but are not locked yet.
+func FetchLoginLocks(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	query := db.Order("last_failure_at DESC")
+	if !c.QueryBool("all", false) {
+		query = query.Where("locked_until > ?", time.Now())
+	}
+	if scope := c.Query("scope"); scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+
+	var attempts []users.LoginAttempt
+	if err := query.Find(&attempts).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    attempts,
+	})
+}
@@ -0,0 +1,82 @@
+package fetchings
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// FetchBlockedUsers lists the users the caller has blocked
+func FetchBlockedUsers(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var blocks []users.UserBlock
+	err := db.Preload("Blocked", func(db *gorm.DB) *gorm.DB {
+		return db.Select("user_id, first_name, last_name, type")
+	}).
+		Where("blocker_id = ?", claims.UserId).
+		Order("created_at DESC").
+		Find(&blocks).Error
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    blocks,
+	})
+}
+
+// FetchMessageReports is the admin moderation queue. Reports are returned oldest first and
+// filtered by ?status= (pending by default, "all" for every report).
+func FetchMessageReports(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	status := c.Query("status", users.ReportStatusPending)
+
+	query := db.Preload("Message").
+		Preload("Message.Attachment").
+		Preload("Message.Sender", func(db *gorm.DB) *gorm.DB {
+			return db.Select("user_id, first_name, last_name, type")
+		}).
+		Preload("Reporter", func(db *gorm.DB) *gorm.DB {
+			return db.Select("user_id, first_name, last_name, type")
+		}).
+		Order("created_at ASC")
+
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+
+	var reports []users.MessageReport
+	if err := query.Find(&reports).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    reports,
+	})
+}
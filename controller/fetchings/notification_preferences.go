@@ -0,0 +1,67 @@
+package fetchings
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FetchNotificationPreferences returns the caller's preference for every notification category,
+// filling in defaults for categories they never changed, along with their quiet hours.
+func FetchNotificationPreferences(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var saved []users.NotificationPreference
+	if err := db.Where("user_id = ?", claims.UserId).Find(&saved).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	byCategory := make(map[string]users.NotificationPreference, len(saved))
+	for _, pref := range saved {
+		byCategory[pref.Category] = pref
+	}
+
+	preferences := make([]users.NotificationPreference, 0, len(users.NotificationCategories))
+	for _, category := range users.NotificationCategories {
+		pref, ok := byCategory[category]
+		if !ok {
+			pref = users.DefaultNotificationPreference(claims.UserId, category)
+		}
+		preferences = append(preferences, pref)
+	}
+
+	var user users.User
+	if err := db.Select("quiet_hours_start, quiet_hours_end").First(&user, "user_id = ?", claims.UserId).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"preferences":       preferences,
+			"quiet_hours_start": user.QuietHoursStart,
+			"quiet_hours_end":   user.QuietHoursEnd,
+		},
+	})
+}
@@ -1,6 +1,7 @@
 package fetchings
 
 import (
+	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
@@ -52,9 +53,30 @@ func ParamsNotification(c *fiber.Ctx) error {
 	}
 	userID := claims.UserId
 
+	// Newest first. Without ?limit= the whole inbox is returned, as before paging was added;
+	// with it, pages of ?limit= (max 100) from ?page= (from 1).
+	// ?archived=true lists the archive instead of the inbox, ?unread=true only unread rows.
+	query := db.Where("to_user = ? AND is_archived = ?", userID, c.QueryBool("archived", false))
+	if c.QueryBool("unread", false) {
+		query = query.Where("is_read = ?", false)
+	}
+	query = query.Order("createdat DESC, notification_id DESC")
+
+	if c.Query("limit") != "" {
+		page := c.QueryInt("page", 1)
+		if page < 1 {
+			page = 1
+		}
+		limit := c.QueryInt("limit", 20)
+		if limit <= 0 || limit > 100 {
+			limit = 20
+		}
+		query = query.Offset((page - 1) * limit).Limit(limit)
+	}
+
 	var notifications []users.UserNotification
 
-	err := db.Where("to_user = ?", userID).Find(&notifications).Error
+	err := query.Find(&notifications).Error
 	if err != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "500",
@@ -73,3 +95,30 @@ func ParamsNotification(c *fiber.Ctx) error {
 		Data:    notifications,
 	})
 }
+
+// FetchUnreadNotificationCount returns the caller's notification badge count
+func FetchUnreadNotificationCount(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	count, err := controller.CountUnreadNotifications(db, claims.UserId)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"unread_count": count,
+		},
+	})
+}
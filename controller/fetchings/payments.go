@@ -0,0 +1,81 @@
+package fetchings
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// FetchPayments lists GCash payments for the finance team, newest first. Filter with ?user_id=
+// (payer or payee) and ?from= / ?to= (YYYY-MM-DD, inclusive). Paged with ?limit= (100 by
+// default, at most 500) and ?offset=; the total amount covers every matching payment.
+func FetchPayments(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	query := db.Model(&users.GCashPayment{})
+	if userId := c.QueryInt("user_id"); userId > 0 {
+		query = query.Where("payment_from = ? OR payment_to = ?", userId, userId)
+	}
+	for param, condition := range map[string]string{"from": "payment_date >= ?", "to": "payment_date < ?"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return invalidAuditFilter(c, err)
+		}
+		if param == "to" {
+			day = day.AddDate(0, 0, 1)
+		}
+		query = query.Where(condition, day)
+	}
+	query = query.Session(&gorm.Session{})
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	var totals struct {
+		Count  int64
+		Amount float64
+	}
+	var payments []users.GCashPayment
+	err := query.Select("COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").Scan(&totals).Error
+	if err == nil {
+		err = query.Order("payment_date DESC").Limit(limit).Offset(offset).Find(&payments).Error
+	}
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"payments":     payments,
+			"total":        totals.Count,
+			"total_amount": totals.Amount,
+			"limit":        limit,
+			"offset":       offset,
+		},
+	})
+}
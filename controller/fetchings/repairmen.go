@@ -1,21 +1,39 @@
 package fetchings
 
 import (
+	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// FetchAllRepairmans fetches all repairmen from the database
+// FetchAllRepairmans fetches the repairmen clients can book, optionally searched by name (?q=) and
+// filtered by service (?category_id=). Unverified repairmen are hidden according to
+// REPAIRMAN_VERIFICATION_MODE, except from admins.
 func FetchAllRepairmen(c *fiber.Ctx) error {
 	db := middleware.DBConn
 	var repairman []users.Repairman
 
-	// Raw SQL query to fetch all repairmen
-	err := db.Preload("ServiceCategory").Where("type = ?", "Repairman").Find(&repairman).Error
+	query := db.Preload("ServiceCategory").Where("type = ?", "Repairman")
+	if claims, ok := c.Locals("user").(*users.Claims); !ok || !claims.IsAdmin() {
+		query = query.Scopes(controller.ScopeBookableRepairmen)
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("(first_name ILIKE ? OR last_name ILIKE ? OR first_name || ' ' || last_name ILIKE ?)", like, like, like)
+	}
+	if categoryId := c.QueryInt("category_id"); categoryId > 0 {
+		query = query.Where("category_id = ?", categoryId)
+	}
+
+	err := query.Find(&repairman).Error
+	if err == nil {
+		err = controller.MarkVerifiedRepairmen(db, repairman)
+	}
 	if err != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "500", // Internal server error
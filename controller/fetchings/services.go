@@ -1,6 +1,7 @@
 package fetchings
 
 import (
+	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
@@ -51,8 +52,21 @@ func DeleteServiceCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	var category users.ServiceCategory
+	if err := db.First(&category, "category_id = ?", categoryID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Category not found",
+			Data: errors.ErrorModel{
+				Message:   "No category with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
 	// Delete the category from the database
-	if err := db.Delete(&users.ServiceCategory{}, categoryID).Error; err != nil {
+	if err := db.Delete(&category).Error; err != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "500",
 			Message: "Failed to delete category",
@@ -64,6 +78,13 @@ func DeleteServiceCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditServiceCategoryDelete,
+		TargetType: "service_category",
+		TargetId:   category.CategoryId,
+		Before:     category,
+	})
+
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Category deleted successfully",
@@ -89,9 +110,22 @@ func DisableServiceCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	var category users.ServiceCategory
+	if err := db.First(&category, "category_id = ?", categoryID).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Category not found",
+			Data: errors.ErrorModel{
+				Message:   "No category with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
 	// Update is_active = false where category_id = :id
 	if err := db.Model(&users.ServiceCategory{}).
-		Where("category_id = ?", categoryID).
+		Where("category_id = ?", category.CategoryId).
 		Update("is_active", false).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
 			RetCode: "500",
@@ -104,6 +138,14 @@ func DisableServiceCategory(c *fiber.Ctx) error {
 		})
 	}
 
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditServiceCategoryDisable,
+		TargetType: "service_category",
+		TargetId:   category.CategoryId,
+		Before:     map[string]interface{}{"is_active": category.Is_active},
+		After:      map[string]interface{}{"is_active": false},
+	})
+
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Category disabled successfully",
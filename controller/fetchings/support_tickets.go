@@ -0,0 +1,227 @@
+package fetchings
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Active tickets are ordered by whichever SLA deadline comes next
+const ticketNextDue = "CASE WHEN first_responded_at IS NULL THEN first_response_due_at ELSE resolution_due_at END"
+
+func ticketAssignee(db *gorm.DB) *gorm.DB {
+	return db.Select("admin_id, username")
+}
+
+// FetchMySupportTickets lists the user's own tickets, most recently active first. Filter with
+// ?status=.
+func FetchMySupportTickets(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	query := db.Preload("Assignee", ticketAssignee).Where("user_id = ?", claims.UserId)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var tickets []users.SupportTicket
+	if err := query.Order("last_message_at DESC").Find(&tickets).Error; err != nil {
+		return supportTicketError(c, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    tickets,
+	})
+}
+
+// FetchMySupportTicket returns one of the user's tickets with its thread. Internal admin notes
+// are left out.
+func FetchMySupportTicket(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var ticket users.SupportTicket
+	err := db.Preload("Assignee", ticketAssignee).
+		Preload("Messages", func(db *gorm.DB) *gorm.DB {
+			return db.Where("internal = ?", false).Order("created_at, message_id")
+		}).
+		First(&ticket, "ticket_id = ? AND user_id = ?", c.Params("id"), claims.UserId).Error
+	if err != nil {
+		return supportTicketNotFound(c, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    ticket,
+	})
+}
+
+// FetchSupportTickets is the support team's queue. By default it lists open and pending tickets
+// by the next SLA deadline; ?status=all lists every ticket, newest first. Also filter with
+// ?status=, ?priority=, ?assigned_to= (an admin ID, "me" or "none"), ?user_id=, ?breached=true
+// and ?q= (subject). Paged with ?limit= (50 by default, at most 200) and ?offset=.
+func FetchSupportTickets(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	query := db.Model(&users.SupportTicket{})
+	order := "created_at DESC"
+	switch status := c.Query("status"); status {
+	case "":
+		query = query.Where("status IN ?", []string{users.TicketStatusOpen, users.TicketStatusPending})
+		order = ticketNextDue + " ASC"
+	case "all":
+	default:
+		query = query.Where("status = ?", status)
+	}
+	if priority := c.Query("priority"); priority != "" {
+		query = query.Where("priority = ?", priority)
+	}
+	switch assigned := c.Query("assigned_to"); assigned {
+	case "":
+	case "me":
+		query = query.Where("assigned_to = ?", claims.UserId)
+	case "none":
+		query = query.Where("assigned_to IS NULL")
+	default:
+		adminId, err := strconv.Atoi(assigned)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+				RetCode: "400",
+				Message: "Invalid filter",
+				Data: errors.ErrorModel{
+					Message:   "assigned_to must be an admin ID, me or none",
+					IsSuccess: false,
+				},
+			})
+		}
+		query = query.Where("assigned_to = ?", adminId)
+	}
+	if userId := c.QueryInt("user_id"); userId > 0 {
+		query = query.Where("user_id = ?", userId)
+	}
+	if c.QueryBool("breached") {
+		query = query.Where("first_response_breached OR resolution_breached")
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		query = query.Where("subject ILIKE ?", "%"+q+"%")
+	}
+	query = query.Session(&gorm.Session{})
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int64
+	var tickets []users.SupportTicket
+	err := query.Count(&total).Error
+	if err == nil {
+		err = query.Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("user_id, first_name, last_name, type, email")
+		}).Preload("Assignee", ticketAssignee).
+			Order(order + ", ticket_id").Limit(limit).Offset(offset).Find(&tickets).Error
+	}
+	if err != nil {
+		return supportTicketError(c, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"tickets": tickets,
+			"total":   total,
+			"limit":   limit,
+			"offset":  offset,
+		},
+	})
+}
+
+// FetchSupportTicket returns a ticket for the support team: the full thread including internal
+// notes, the user's contact details, the linked request or payment and the SLA state
+func FetchSupportTicket(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	var ticket users.SupportTicket
+	err := db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("user_id, first_name, last_name, type, email, phone, account_status")
+	}).Preload("Assignee", func(db *gorm.DB) *gorm.DB {
+		return db.Select("admin_id, username, email")
+	}).Preload("Messages", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at, message_id")
+	}).First(&ticket, "ticket_id = ?", c.Params("id")).Error
+	if err != nil {
+		return supportTicketNotFound(c, err)
+	}
+
+	var request *users.ServiceRequest
+	if ticket.RequestId != nil {
+		request = &users.ServiceRequest{}
+		if err := db.Preload("ServiceCategory").First(request, "request_id = ?", *ticket.RequestId).Error; err != nil {
+			request = nil
+		}
+	}
+	var payment *users.GCashPayment
+	if ticket.PaymentId != nil {
+		payment = &users.GCashPayment{}
+		if err := db.First(payment, "payment_id = ?", *ticket.PaymentId).Error; err != nil {
+			payment = nil
+		}
+	}
+
+	firstResponseLate, resolutionLate := ticket.SLABreaches(time.Now())
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"ticket":  ticket,
+			"request": request,
+			"payment": payment,
+			"sla": fiber.Map{
+				"first_response_due_at": ticket.FirstResponseDueAt,
+				"resolution_due_at":     ticket.ResolutionDueAt,
+				"first_response_late":   firstResponseLate,
+				"resolution_late":       resolutionLate,
+			},
+		},
+	})
+}
+
+func supportTicketNotFound(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+		RetCode: "404",
+		Message: "Ticket not found",
+		Data: errors.ErrorModel{
+			Message:   "No ticket with the given ID",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
+
+func supportTicketError(c *fiber.Ctx, err error) error {
+	return c.JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Request failed",
+		Data: errors.ErrorModel{
+			Message:   "Failed to fetch data from database",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
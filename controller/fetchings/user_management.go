@@ -0,0 +1,191 @@
+package fetchings
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fixify_backend/sms"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// userSearchDocument is the text SearchUsers matches words against. It is the expression of the
+// idx_users_search_trgm trigram index (migration 0005), so both must change together.
+const userSearchDocument = "(COALESCE(first_name, '') || ' ' || COALESCE(last_name, '') || ' ' || COALESCE(email, '') || ' ' || COALESCE(phone, ''))"
+
+// SearchUsers lists clients and repairmen for the admin console, newest first. ?q= matches
+// every word against the name, email and phone; a phone number typed in any usual format
+// finds the account. Filter with ?type=Client|Repairman and ?status= (an account status).
+// Paged with ?limit= (50 by default, at most 200) and ?offset=.
+func SearchUsers(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	query := db.Model(&users.Repairman{}).Omit("profile_picture", "password")
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		if phone, err := sms.NormalizePhone(q); err == nil {
+			query = query.Where("phone IN ?", sms.PhoneVariants(phone))
+		} else {
+			for _, word := range strings.Fields(q) {
+				query = query.Where(userSearchDocument+" ILIKE ?", "%"+escapeLike(word)+"%")
+			}
+		}
+	}
+	if userType := c.Query("type"); userType != "" {
+		query = query.Where("type = ?", userType)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("account_status = ?", status)
+	}
+	query = query.Session(&gorm.Session{})
+
+	limit := c.QueryInt("limit", 50)
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset := c.QueryInt("offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	var total int64
+	var results []users.Repairman
+	err := query.Count(&total).Error
+	if err == nil {
+		err = query.Order("createdat DESC, user_id DESC").Limit(limit).Offset(offset).Find(&results).Error
+	}
+	if err == nil {
+		err = controller.MarkVerifiedRepairmen(db, results)
+	}
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"users":  results,
+			"total":  total,
+			"limit":  limit,
+			"offset": offset,
+		},
+	})
+}
+
+// FetchUserOverview returns a user's profile together with their service requests (as client or
+// repairman), the reviews they wrote and received, and their payments, for the admin console
+func FetchUserOverview(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	userId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userId <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid user ID",
+			Data: errors.ErrorModel{
+				Message:   "User ID must be a valid number",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var user users.Repairman
+	if err := db.Preload("ServiceCategory").Omit("password").First(&user, "user_id = ?", userId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "User not found",
+			Data: errors.ErrorModel{
+				Message:   "No user with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	counterparty := func(db *gorm.DB) *gorm.DB {
+		return db.Select("user_id, first_name, last_name, type")
+	}
+
+	var (
+		requests        []users.ServiceRequest
+		reviewsWritten  []users.Review
+		reviewsReceived []users.Review
+		payments        []users.GCashPayment
+	)
+	queries := []*gorm.DB{
+		db.Preload("User", counterparty).Preload("Repairman", counterparty).Preload("ServiceCategory").
+			Where("user_id = ? OR fixer_id = ?", userId, userId).Order("request_id DESC").Find(&requests),
+		db.Preload("Repairman", counterparty).Where("client_id = ?", userId).Order("review_id DESC").Find(&reviewsWritten),
+		db.Preload("Client", counterparty).Where("repairman_id = ?", userId).Order("review_id DESC").Find(&reviewsReceived),
+		db.Where("payment_from = ? OR payment_to = ?", userId, userId).Order("payment_date DESC").Find(&payments),
+	}
+	for _, query := range queries {
+		if query.Error != nil {
+			return c.JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Request failed",
+				Data: errors.ErrorModel{
+					Message:   "Failed to fetch data from database",
+					IsSuccess: false,
+					Error:     query.Error.Error(),
+				},
+			})
+		}
+	}
+
+	if user.Type == "Repairman" {
+		repairmen := []users.Repairman{user}
+		if err := controller.MarkVerifiedRepairmen(db, repairmen); err == nil {
+			user = repairmen[0]
+		}
+	}
+
+	requestsByStatus := map[string]int{}
+	for _, request := range requests {
+		requestsByStatus[request.Status]++
+	}
+	var paid, received float64
+	for _, payment := range payments {
+		if payment.PaymentFrom == userId {
+			paid += payment.Amount
+		}
+		if payment.PaymentTo == userId {
+			received += payment.Amount
+		}
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"user":             user,
+			"service_requests": requests,
+			"reviews_written":  reviewsWritten,
+			"reviews_received": reviewsReceived,
+			"payments":         payments,
+			"summary": fiber.Map{
+				"requests_by_status": requestsByStatus,
+				"total_paid":         paid,
+				"total_received":     received,
+			},
+		},
+	})
+}
+
+// escapeLike makes a search word match literally inside an ILIKE pattern
+func escapeLike(word string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(word)
+}
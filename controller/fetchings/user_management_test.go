@@ -0,0 +1,32 @@
+package fetchings
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEscapeLike(t *testing.T) {
+	tests := map[string]string{
+		"ana":      "ana",
+		"100%":     `100\%`,
+		"first_nm": `first\_nm`,
+		`a\b`:      `a\\b`,
+	}
+	for in, want := range tests {
+		if got := escapeLike(in); got != want {
+			t.Errorf("escapeLike(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUserSearchDocumentMatchesTrigramIndex(t *testing.T) {
+	script, err := os.ReadFile("../../migrations/sql/0024_user_search_and_verified_email.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Postgres only uses the index when the query repeats its expression exactly
+	if !strings.Contains(string(script), userSearchDocument) {
+		t.Fatalf("idx_users_search_trgm is not built on %s", userSearchDocument)
+	}
+}
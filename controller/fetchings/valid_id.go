@@ -1,19 +1,24 @@
 package fetchings
 
 import (
+	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
+// FetchAllId lists every submission for admins. Images are only returned by FetchVerification,
+// where each view is logged.
 func FetchAllId(c *fiber.Ctx) error {
 	db := middleware.DBConn
 	var validID []users.UserVerification
 
-	err := db.Preload("User").Find(&validID).Error
+	err := verificationListQuery(db).Order("verification_id DESC").Find(&validID).Error
 	if err != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "500",
@@ -66,9 +71,9 @@ func FetchValid(c *fiber.Ctx) error {
 	// Get user ID from claims
 	userID := claims.UserId
 
-	// Step 2: Query the UserVerification using user_id
+	// Step 2: Query the user's latest UserVerification submission
 	var userVerification users.UserVerification
-	err := db.Preload("User").Where("user_id = ?", userID).First(&userVerification).Error
+	err := db.Preload("User").Where("user_id = ?", userID).Order("verification_id DESC").First(&userVerification).Error
 	if err != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "404",
@@ -81,9 +86,199 @@ func FetchValid(c *fiber.Ctx) error {
 		})
 	}
 
+	if err := controller.OpenVerificationDocuments(c, db, &userVerification, claims); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to open identity documents",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Success",
 		Data:    userVerification,
 	})
 }
+
+// FetchValidHistory lists all of the caller's ID submissions, newest first, without the images
+func FetchValidHistory(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var history []users.UserVerification
+	err := db.Omit("valid_id", "selfie", "back_id").
+		Where("user_id = ?", claims.UserId).
+		Order("verification_id DESC").
+		Find(&history).Error
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    history,
+	})
+}
+
+// FetchVerificationQueue is the admin ID review queue. Submissions are returned oldest first
+// without the images, filtered by ?status= (pending by default, "all" for every submission).
+func FetchVerificationQueue(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	status := c.Query("status", users.VerificationStatusPending)
+
+	query := verificationListQuery(db).Order("submitted_at ASC")
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+
+	var queue []users.UserVerification
+	if err := query.Find(&queue).Error; err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    queue,
+	})
+}
+
+// FetchExpiringVerifications lists approved verifications whose document lapses within ?days=
+// (30 by default) and the ones that have already expired, soonest first
+func FetchExpiringVerifications(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	days := c.QueryInt("days", 30)
+	if days < 0 {
+		days = 30
+	}
+	horizon := time.Now().AddDate(0, 0, days).Format("2006-01-02")
+
+	var expiring []users.UserVerification
+	err := verificationListQuery(db).
+		Where("status IN ? AND expires_at <= ?", []string{users.VerificationStatusApproved, users.VerificationStatusExpired}, horizon).
+		Order("expires_at ASC").
+		Find(&expiring).Error
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    expiring,
+	})
+}
+
+// FetchVerification returns one submission including the ID images, for reviewing it. Every
+// call is recorded in the document access log.
+func FetchVerification(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var verification users.UserVerification
+	err := db.Preload("User", func(db *gorm.DB) *gorm.DB {
+		return db.Select("user_id, first_name, last_name, email, phone, type")
+	}).
+		Preload("Reviewer", func(db *gorm.DB) *gorm.DB {
+			return db.Select("admin_id, username, email")
+		}).
+		First(&verification, "verification_id = ?", c.Params("id")).Error
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Verification not found",
+			Data: errors.ErrorModel{
+				Message:   "No verification with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	if err := controller.OpenVerificationDocuments(c, db, &verification, claims); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to open identity documents",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    verification,
+	})
+}
+
+// FetchUserVerificationHistory lists every submission of one user, newest first, for admins
+func FetchUserVerificationHistory(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	var history []users.UserVerification
+	err := verificationListQuery(db).
+		Where("user_id = ?", c.Params("id")).
+		Order("verification_id DESC").
+		Find(&history).Error
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Request failed",
+			Data: errors.ErrorModel{
+				Message:   "Failed to fetch data from database",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data:    history,
+	})
+}
+
+// verificationListQuery loads submissions with the submitter and reviewer but not the images
+func verificationListQuery(db *gorm.DB) *gorm.DB {
+	return db.Omit("valid_id", "selfie", "back_id").
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("user_id, first_name, last_name, email, type")
+		}).
+		Preload("Reviewer", func(db *gorm.DB) *gorm.DB {
+			return db.Select("admin_id, username, email")
+		})
+}
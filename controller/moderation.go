@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"fixify_backend/middleware"
+	"fixify_backend/model/users"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// IsBlocked reports whether either user has blocked the other
+func IsBlocked(db *gorm.DB, userA uint, userB uint) (bool, error) {
+	var count int64
+	err := db.Model(&users.UserBlock{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", userA, userB, userB, userA).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Default words masked when CHAT_FILTER_PROFANITY is enabled; CHAT_BLOCKED_WORDS (comma separated) adds more
+var defaultBlockedWords = []string{
+	"fuck", "shit", "bitch", "asshole", "bastard",
+	"putangina", "tangina", "puta", "gago", "ulol", "bobo", "tanga",
+}
+
+var (
+	// PH mobile/landline style numbers: 09171234567, +63 917 123 4567, (02) 8123-4567
+	phonePattern = regexp.MustCompile(`(\+?\d[\d\s().-]{8,}\d)`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// chatFilter is the chat filter configuration, read from the environment once
+type chatFilter struct {
+	contactInfo  bool
+	blockedWords *regexp.Regexp // nil when profanity filtering is off
+}
+
+var (
+	currentChatFilter chatFilter
+	chatFilterOnce    sync.Once
+)
+
+func loadChatFilter() chatFilter {
+	chatFilterOnce.Do(func() {
+		currentChatFilter.contactInfo = middleware.GetEnv("CHAT_FILTER_CONTACT_INFO") == "true"
+		if middleware.GetEnv("CHAT_FILTER_PROFANITY") == "true" {
+			currentChatFilter.blockedWords = compileBlockedWords(middleware.GetEnv("CHAT_BLOCKED_WORDS"))
+		}
+	})
+	return currentChatFilter
+}
+
+// compileBlockedWords builds one case-insensitive, whole-word pattern for the default words plus
+// the comma separated extra ones
+func compileBlockedWords(extra string) *regexp.Regexp {
+	var quoted []string
+	for _, w := range defaultBlockedWords {
+		quoted = append(quoted, regexp.QuoteMeta(w))
+	}
+	for _, w := range strings.Split(extra, ",") {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	return regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// FilterChatMessage applies the optional chat filters configured through the environment:
+// CHAT_FILTER_PROFANITY=true masks blocked words and CHAT_FILTER_CONTACT_INFO=true hides phone
+// numbers and email addresses so transactions stay on-platform. It returns the filtered text and
+// whether anything was changed.
+func FilterChatMessage(content string) (string, bool) {
+	return loadChatFilter().apply(content)
+}
+
+func (f chatFilter) apply(content string) (string, bool) {
+	filtered := content
+
+	if f.contactInfo {
+		filtered = phonePattern.ReplaceAllStringFunc(filtered, func(match string) string {
+			digits := 0
+			for _, r := range match {
+				if r >= '0' && r <= '9' {
+					digits++
+				}
+			}
+			// Short numbers are prices, quantities, etc.
+			if digits < 10 {
+				return match
+			}
+			return "[phone number hidden]"
+		})
+		filtered = emailPattern.ReplaceAllString(filtered, "[email hidden]")
+	}
+
+	if f.blockedWords != nil {
+		filtered = f.blockedWords.ReplaceAllStringFunc(filtered, func(match string) string {
+			return strings.Repeat("*", len(match))
+		})
+	}
+
+	return filtered, filtered != content
+}
@@ -0,0 +1,33 @@
+package controller
+
+import "testing"
+
+func TestChatFilterMasksBlockedWords(t *testing.T) {
+	f := chatFilter{blockedWords: compileBlockedWords(" scam , Budol")}
+
+	tests := []struct {
+		in, want string
+	}{
+		{"Gago ka", "**** ka"},
+		{"this is a SCAM", "this is a ****"},
+		{"budol daw", "***** daw"},
+		{"tangina", "*******"},
+		{"scampi and tangerines", "scampi and tangerines"},
+	}
+	for _, tt := range tests {
+		got, changed := f.apply(tt.in)
+		if got != tt.want || changed != (tt.in != tt.want) {
+			t.Errorf("apply(%q) = %q, %v; want %q", tt.in, got, changed, tt.want)
+		}
+	}
+}
+
+func TestChatFilterHidesContactInfo(t *testing.T) {
+	f := chatFilter{contactInfo: true}
+
+	got, changed := f.apply("call 0917 123 4567 or ana@example.com, budget 1500")
+	want := "call [phone number hidden] or [email hidden], budget 1500"
+	if got != want || !changed {
+		t.Errorf("apply = %q, %v; want %q", got, changed, want)
+	}
+}
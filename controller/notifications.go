@@ -48,3 +48,13 @@ func CreateChatNotification(
 
 	return db.Create(&notification).Error
 }
+
+// CountUnreadNotifications returns the number of unread, non-archived inbox notifications for a user.
+// This is the value shown on the app's notification badge.
+func CountUnreadNotifications(db *gorm.DB, userId uint) (int64, error) {
+	var count int64
+	err := db.Model(&users.UserNotification{}).
+		Where("to_user = ? AND is_read = ? AND is_archived = ?", userId, false, false).
+		Count(&count).Error
+	return count, err
+}
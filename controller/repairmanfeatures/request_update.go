@@ -1,7 +1,7 @@
 package repairmanfeatures
 
 import (
-	"fixify_backend/controller"
+	"fixify_backend/mailer"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
@@ -13,8 +13,37 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// requestTransitions lists, per current status, the statuses the assigned repairman and the
+// client may move a request to. Completed and canceled requests are final, so a locked chat is
+// never reopened.
+var requestTransitions = map[string]struct {
+	repairman []string
+	client    []string
+}{
+	"pending":     {repairman: []string{"in progress", "canceled"}, client: []string{"canceled"}},
+	"in progress": {repairman: []string{"completed", "canceled"}, client: []string{"canceled"}},
+}
+
+// requestTransitionAllowed reports whether the party may move a request from one status to another
+func requestTransitionAllowed(from string, to string, isRepairman bool) bool {
+	allowed := requestTransitions[from].client
+	if isRepairman {
+		allowed = requestTransitions[from].repairman
+	}
+	for _, status := range allowed {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// RequestUpdate changes the status of a service request. Only the assigned repairman and the
+// client who sent it may do so: the repairman accepts, completes or cancels, the client can only
+// cancel.
 func RequestUpdate(c *fiber.Ctx) error {
 	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
 
 	type UpdateStatusRequest struct {
 		Status string `json:"status"`
@@ -84,20 +113,57 @@ func RequestUpdate(c *fiber.Ctx) error {
 		})
 	}
 
+	isRepairman := request.RepairmanId == claims.UserId
+	if claims.IsAdmin() || (!isRepairman && request.UserId != claims.UserId) {
+		return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "Forbidden",
+			Data: errors.ErrorModel{
+				Message:   "Only the assigned repairman or the client can update this request",
+				IsSuccess: false,
+			},
+		})
+	}
+	if !requestTransitionAllowed(request.Status, update.Status, isRepairman) {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "Invalid status change",
+			Data: errors.ErrorModel{
+				Message:   "A request that is " + request.Status + " can't be set to " + update.Status,
+				IsSuccess: false,
+			},
+		})
+	}
+
+	// Only applies if nobody changed the status in the meantime
+	previous := request.Status
 	request.Status = update.Status
-	if err := db.Save(&request).Error; err != nil {
+	result := db.Model(&request).Where("status = ?", previous).Update("status", update.Status)
+	if result.Error != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "500",
 			Message: "Failed to Update Request",
 			Data: errors.ErrorModel{
 				Message:   "Failed to update status",
 				IsSuccess: false,
-				Error:     err.Error(),
+				Error:     result.Error.Error(),
+			},
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "Invalid status change",
+			Data: errors.ErrorModel{
+				Message:   "The request was updated by someone else, please reload it",
+				IsSuccess: false,
 			},
 		})
 	}
 	var notificationDescription string
 	var conversationId uint = 0
+	var emailTemplate string
+	var emailData interface{}
 
 	repairmanName := "the repairman"
 	if request.Repairman.UserId != 0 {
@@ -108,7 +174,18 @@ func RequestUpdate(c *fiber.Ctx) error {
 	case "in progress":
 		notificationDescription = "Good news! Your service request has been accepted by " + repairmanName + ". They will contact you shortly to schedule the service."
 
-		convID, err := websocketclient.EnsureClientRepairmanConversation(request.UserId, request.RepairmanId)
+		var category users.ServiceCategory
+		db.Select("category_name").First(&category, "category_id = ?", request.CategoryId)
+
+		emailTemplate = mailer.TemplateRequestAccepted
+		emailData = mailer.RequestAcceptedData{
+			ClientName:    request.User.First_name,
+			RepairmanName: repairmanName,
+			RequestId:     request.RequestId,
+			Category:      category.CategoryName,
+		}
+
+		convID, err := websocketclient.EnsureClientRepairmanConversation(request.UserId, request.RepairmanId, request.RequestId)
 		if err != nil {
 			log.Printf("Failed to ensure client-repairman conversation: %v", err)
 		} else {
@@ -118,21 +195,40 @@ func RequestUpdate(c *fiber.Ctx) error {
 	case "completed":
 		notificationDescription = "Your service request has been marked as completed by " + repairmanName + ". Thank you for using our service!"
 
+		if err := websocketclient.LockRequestConversation(request.RequestId); err != nil {
+			log.Printf("Failed to lock conversation for request %d: %v", request.RequestId, err)
+		}
+
 	case "canceled":
 		notificationDescription = "Unfortunately, your service request was canceled by " + repairmanName + ". Please feel free to request another service."
+		if !isRepairman {
+			notificationDescription = request.User.First_name + " canceled their service request."
+		}
+
+		if err := websocketclient.LockRequestConversation(request.RequestId); err != nil {
+			log.Printf("Failed to lock conversation for request %d: %v", request.RequestId, err)
+		}
 
 	default:
 		notificationDescription = "The status of your service request has been updated by " + repairmanName + "."
 	}
 
-	if err := controller.CreateUserNotification(
-		db,
-		"Request Response",
-		int(request.RequestId),
-		int(request.RepairmanId),
-		int(request.UserId),
-		notificationDescription,
-	); err != nil {
+	// The other party is told about the change
+	fromUser, toUser := request.RepairmanId, request.UserId
+	if !isRepairman {
+		fromUser, toUser = request.UserId, request.RepairmanId
+	}
+	if err := websocketclient.Notify(db, websocketclient.Notification{
+		Category:      users.NotificationCategoryRequest,
+		Type:          "Request Response",
+		RequestId:     request.RequestId,
+		FromUser:      fromUser,
+		ToUser:        toUser,
+		Title:         "Service request update",
+		Body:          notificationDescription,
+		EmailTemplate: emailTemplate,
+		EmailData:     emailData,
+	}); err != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "500",
 			Message: "Failed to create notification!",
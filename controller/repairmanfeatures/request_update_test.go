@@ -0,0 +1,29 @@
+package repairmanfeatures
+
+import "testing"
+
+func TestRequestTransitionAllowed(t *testing.T) {
+	tests := []struct {
+		from, to    string
+		isRepairman bool
+		want        bool
+	}{
+		{"pending", "in progress", true, true},
+		{"pending", "in progress", false, false},
+		{"pending", "canceled", true, true},
+		{"pending", "canceled", false, true},
+		{"pending", "completed", true, false},
+		{"in progress", "completed", true, true},
+		{"in progress", "completed", false, false},
+		{"in progress", "canceled", false, true},
+		{"completed", "in progress", true, false},
+		{"canceled", "in progress", true, false},
+		{"completed", "canceled", false, false},
+		{"pending", "anything", true, false},
+	}
+	for _, tt := range tests {
+		if got := requestTransitionAllowed(tt.from, tt.to, tt.isRepairman); got != tt.want {
+			t.Errorf("%q -> %q (repairman %v) = %v, want %v", tt.from, tt.to, tt.isRepairman, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package signuplogin
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fixify_backend/mailer"
+	"fixify_backend/middleware"
+	"fixify_backend/model/users"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AdminInviteTTL is how long an emailed admin invite can be used
+const AdminInviteTTL = 7 * 24 * time.Hour
+
+// IssueAdminInvite creates an invite for email with the given role and emails the token (and a
+// link, when ADMIN_INVITE_URL is set). Earlier pending invites for the same address stop working.
+func IssueAdminInvite(db *gorm.DB, email string, role string, inviter users.Admin) (users.AdminInvite, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return users.AdminInvite{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	invite := users.AdminInvite{
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		AdminRole: role,
+		TokenHash: hashAdminInviteToken(token),
+		InvitedBy: inviter.AdminId,
+		ExpiresAt: time.Now().Add(AdminInviteTTL),
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&users.AdminInvite{}).
+			Where("email = ? AND accepted_at IS NULL AND revoked_at IS NULL", invite.Email).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&invite).Error
+	})
+	if err != nil {
+		return invite, err
+	}
+
+	data := mailer.AdminInviteData{
+		InvitedBy:     inviter.Username,
+		Role:          strings.ReplaceAll(role, "_", " "),
+		Token:         token,
+		ExpiresInDays: int(AdminInviteTTL / (24 * time.Hour)),
+	}
+	if base := middleware.GetEnv("ADMIN_INVITE_URL"); base != "" {
+		link, err := url.Parse(base)
+		if err != nil {
+			return invite, fmt.Errorf("invalid ADMIN_INVITE_URL: %v", err)
+		}
+		query := link.Query()
+		query.Set("token", token)
+		link.RawQuery = query.Encode()
+		data.Link = link.String()
+	}
+
+	return invite, mailer.SendTemplate(db, invite.Email, mailer.TemplateAdminInvite, data)
+}
+
+// claimAdminInvite locks the pending invite matching token for the rest of the transaction
+func claimAdminInvite(tx *gorm.DB, token string) (users.AdminInvite, error) {
+	var invite users.AdminInvite
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?",
+			hashAdminInviteToken(strings.TrimSpace(token)), time.Now()).
+		First(&invite).Error
+	return invite, err
+}
+
+func hashAdminInviteToken(token string) string {
+	mac := hmac.New(sha256.New, purposeKey("admin-invite"))
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
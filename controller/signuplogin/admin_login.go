@@ -13,9 +13,12 @@ import (
 
 func AdminLogin(c *fiber.Ctx) error {
 	db := middleware.DBConn
-	logac := new(users.Admin)
+	logac := new(struct {
+		Email    string `json:"email"` // Email or username
+		Password string `json:"password"`
+	})
 
-	// Parse incoming request body to the Admin struct
+	// Parse incoming request body
 	if err := c.BodyParser(logac); err != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "401",
@@ -32,21 +35,21 @@ func AdminLogin(c *fiber.Ctx) error {
 	input := logac.Email // This could be either the email or username
 	password := logac.Password
 
+	// Refuse early while the account or this IP is locked out
+	guard := newLoginGuard(db, users.RoleAdmin, input, c.IP())
+	if retryAfter := guard.lockedFor(); retryAfter > 0 {
+		return loginLocked(c, retryAfter)
+	}
+
 	// Retrieve the admin record from the database based on either email or username
 	var admin users.Admin
 	// We now check both email and username
 	if err := db.Where("email = ? OR username = ?", input, input).First(&admin).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			// If no matching record is found, return a login failed response
-			return c.JSON(response.ResponseModel{
-				RetCode: "401",
-				Message: "Login Failed!",
-				Data: errors.ErrorModel{
-					Message:   "Invalid email/username or password",
-					IsSuccess: false,
-					Error:     err.Error(),
-				},
-			})
+			// Spend the same time as a wrong password and answer the same way
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+			guard.failed()
+			return loginFailed(c)
 		}
 		// If there's some other error (such as a database error), return it
 		return c.JSON(response.ResponseModel{
@@ -64,40 +67,18 @@ func AdminLogin(c *fiber.Ctx) error {
 	err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(password))
 	if err != nil {
 		// If the password doesn't match, return a login failed response
-		return c.JSON(response.ResponseModel{
-			RetCode: "401",
-			Message: "Login Failed!",
-			Data: errors.ErrorModel{
-				Message:   "Invalid email/username or password",
-				IsSuccess: false,
-				Error:     "Password does not match",
-			},
-		})
+		guard.failed()
+		return loginFailed(c)
 	}
 
-	// If credentials are valid, generate the JWT token
-	token, err := GenerateJWT(
-		int(admin.AdminId))
-	if err != nil {
-		// Handle token generation error
-		return c.JSON(response.ResponseModel{
-			RetCode: "500",
-			Message: "Internal Server Error!",
-			Data: errors.ErrorModel{
-				Message:   "Error generating token",
-				IsSuccess: false,
-				Error:     err.Error(),
-			},
-		})
+	// With two-factor authentication on (or required by config) the password only earns a
+	// short-lived challenge; the JWT is issued by the second step
+	if admin.TOTPEnabled || adminTwoFactorRequired() {
+		return twoFactorChallenge(c, admin, input)
 	}
 
-	// If we get here, the email/username and password match, return success
-	return c.JSON(response.ResponseModel{
-		RetCode: "200",
-		Message: "Login Successful!",
-		Data: map[string]interface{}{
-			"token": token,
-			"admin": admin, // Include the generated token
-		},
-	})
+	guard.succeeded()
+
+	// If we get here, the email/username and password match, return the token
+	return adminLoginSuccess(c, admin, nil)
 }
@@ -0,0 +1,43 @@
+package signuplogin
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fixify_backend/model/users"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAdminLoginSuccessOmitsPasswordHash(t *testing.T) {
+	const hash = "$2a$10$abcdefghijklmnopqrstuv"
+	admin := users.Admin{
+		AdminId:    1,
+		Username:   "ana",
+		Password:   hash,
+		TOTPSecret: "GEZDGNBVGY3TQOJQ",
+		AdminRole:  users.AdminRoleSupport,
+	}
+
+	app := fiber.New()
+	app.Post("/login", func(c *fiber.Ctx) error {
+		return adminLoginSuccess(c, admin, nil)
+	})
+	resp, err := app.Test(httptest.NewRequest("POST", "/login", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != fiber.StatusOK || !strings.Contains(string(body), `"token"`) {
+		t.Fatalf("status %d, body %s", resp.StatusCode, body)
+	}
+	if strings.Contains(string(body), hash) || strings.Contains(string(body), admin.TOTPSecret) {
+		t.Fatalf("login response leaks credentials: %s", body)
+	}
+}
@@ -0,0 +1,39 @@
+package signuplogin
+
+import (
+	"fixify_backend/middleware"
+	"fixify_backend/model/users"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission must run after JWTMiddleware. It only lets through enabled admins whose role
+// grants the permission (see users.AdminRolePermissions). The role is read on every request, so
+// a change by a super admin applies straight away.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		claims, ok := c.Locals("user").(*users.Claims)
+		if !ok || !claims.IsAdmin() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "Admin access required",
+			})
+		}
+
+		var admin users.Admin
+		if err := middleware.DBConn.Select("admin_id, admin_role, disabled_at").
+			First(&admin, "admin_id = ?", claims.UserId).Error; err != nil || admin.DisabledAt != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "This admin account is disabled",
+			})
+		}
+		if !users.AdminRoleHas(admin.AdminRole, permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message":    "Your admin role does not allow this",
+				"admin_role": admin.AdminRole,
+				"permission": permission,
+			})
+		}
+
+		return c.Next()
+	}
+}
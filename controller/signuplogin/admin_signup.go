@@ -1,22 +1,45 @@
 package signuplogin
 
 import (
+	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
+// adminSignupError is returned from the signup transaction with the response to send
+type adminSignupError struct {
+	status  int
+	message string
+}
+
+func (e *adminSignupError) Error() string {
+	return e.message
+}
+
+// AdminSignup creates an admin account from an invite (invite_token), with the role and email
+// the invite was issued for. Only while there are no admins at all can the first one sign up
+// without an invite; that account becomes the super admin.
 func AdminSignup(c *fiber.Ctx) error {
 	db := middleware.DBConn
-	logac := new(users.Admin)
 
-	// Parse incoming request body to the Admin struct
-	if err := c.BodyParser(logac); err != nil {
-		return c.JSON(response.ResponseModel{
-			RetCode: "401",
+	var body struct {
+		Username    string `json:"username"`
+		Email       string `json:"email"` // Only used by the first admin; invites fix the email
+		Password    string `json:"password"`
+		InviteToken string `json:"invite_token"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
 			Message: "Invalid Request!",
 			Data: errors.ErrorModel{
 				Message:   "Failed to parse request",
@@ -25,29 +48,18 @@ func AdminSignup(c *fiber.Ctx) error {
 			},
 		})
 	}
-
-	// Log received admin data (for debugging)
-	print("Admin Sign-up", logac.Username, "\n", logac.Password, "\n", logac.Email, "\n")
-
-	// Check if the email is already in use
-	var existingAdminByEmail users.Admin
-	if err := db.Where("email = ?", logac.Email).First(&existingAdminByEmail).Error; err == nil {
-		// If an admin with the same email exists, return an error
-		return c.JSON(response.ResponseModel{
-			RetCode: "400",
-			Message: "Email already in use!",
-			Data: errors.ErrorModel{
-				Message:   "The provided email is already associated with an existing account",
-				IsSuccess: false,
-				Error:     "Email already in use",
-			},
-		})
+	body.Username = strings.TrimSpace(body.Username)
+	if body.Username == "" {
+		return adminSignupFailed(c, fiber.StatusBadRequest, "Choose a username")
+	}
+	if err := middleware.ValidatePasswordStrength(body.Password); err != nil {
+		return adminSignupFailed(c, fiber.StatusBadRequest, err.Error())
 	}
 
 	// Hash the admin password before saving it to the database
-	hashedPassword, err := middleware.HashPassword(logac.Password)
+	hashedPassword, err := middleware.HashPassword(body.Password)
 	if err != nil {
-		return c.JSON(response.ResponseModel{
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
 			RetCode: "500",
 			Message: "Failed to hash password",
 			Data: errors.ErrorModel{
@@ -57,12 +69,69 @@ func AdminSignup(c *fiber.Ctx) error {
 			},
 		})
 	}
-	// Store the hashed password
-	logac.Password = hashedPassword
 
-	// Save the admin in the database (this will insert into the 'admins' table or whatever your table is called)
-	if err := db.Create(logac).Error; err != nil {
-		return c.JSON(response.ResponseModel{
+	admin := users.Admin{Username: body.Username, Password: hashedPassword}
+	var invite users.AdminInvite
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Serialises signups so two first admins can't both skip the invite
+		if err := tx.Exec("LOCK TABLE admins IN SHARE ROW EXCLUSIVE MODE").Error; err != nil {
+			return err
+		}
+		var existing int64
+		if err := tx.Model(&users.Admin{}).Count(&existing).Error; err != nil {
+			return err
+		}
+
+		if existing == 0 {
+			admin.Email = strings.ToLower(strings.TrimSpace(body.Email))
+			admin.AdminRole = users.AdminRoleSuper
+			if _, err := mail.ParseAddress(admin.Email); err != nil {
+				return &adminSignupError{fiber.StatusBadRequest, "Enter a valid email address"}
+			}
+		} else {
+			if body.InviteToken == "" {
+				return &adminSignupError{fiber.StatusForbidden, "An invite is required to create an admin account"}
+			}
+			claimed, err := claimAdminInvite(tx, body.InviteToken)
+			if err == gorm.ErrRecordNotFound {
+				return &adminSignupError{fiber.StatusBadRequest, "This invite is invalid, expired or already used"}
+			}
+			if err != nil {
+				return err
+			}
+			invite = claimed
+			admin.Email = invite.Email
+			admin.AdminRole = invite.AdminRole
+		}
+
+		var taken int64
+		if err := tx.Model(&users.Admin{}).
+			Where("LOWER(email) = ? OR LOWER(username) = ? OR LOWER(email) = ?",
+				admin.Email, strings.ToLower(admin.Username), strings.ToLower(admin.Username)).
+			Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return &adminSignupError{fiber.StatusConflict, "The email or username is already used by an admin"}
+		}
+
+		if err := tx.Create(&admin).Error; err != nil {
+			return err
+		}
+		if invite.InviteId != 0 {
+			now := time.Now()
+			return tx.Model(&invite).Updates(map[string]interface{}{
+				"accepted_at": now,
+				"admin_id":    admin.AdminId,
+			}).Error
+		}
+		return nil
+	})
+	if signupErr, ok := err.(*adminSignupError); ok {
+		return adminSignupFailed(c, signupErr.status, signupErr.message)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
 			RetCode: "500",
 			Message: "Cannot sign up!",
 			Data: errors.ErrorModel{
@@ -73,10 +142,30 @@ func AdminSignup(c *fiber.Ctx) error {
 		})
 	}
 
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAdminInviteAccept,
+		TargetType: users.RoleAdmin,
+		TargetId:   admin.AdminId,
+		After:      map[string]interface{}{"email": admin.Email, "admin_role": admin.AdminRole, "invite_id": invite.InviteId},
+		ActorId:    uint(admin.AdminId),
+		ActorRole:  users.RoleAdmin,
+	})
+
 	// Return the success response with the admin data
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Admin sign-up successful!",
-		Data:    logac,
+		Data:    admin,
+	})
+}
+
+func adminSignupFailed(c *fiber.Ctx, status int, message string) error {
+	return c.Status(status).JSON(response.ResponseModel{
+		RetCode: strconv.Itoa(status),
+		Message: message,
+		Data: errors.ErrorModel{
+			Message:   message,
+			IsSuccess: false,
+		},
 	})
 }
@@ -0,0 +1,713 @@
+package signuplogin
+
+import (
+	"crypto/hmac"
+	"fixify_backend/controller"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Challenge stages. After the password step an admin gets a challenge for the second step:
+// a TOTP (or recovery) code, or enrolment when ADMIN_2FA_REQUIRED is set and no authenticator
+// has been set up yet.
+const (
+	twoFactorStageVerify = "verify"
+	twoFactorStageEnroll = "enroll"
+
+	twoFactorChallengeTTL = 5 * time.Minute
+)
+
+// twoFactorChallengeClaims is the short-lived token returned by AdminLogin in place of a JWT.
+// Identifier is what the admin typed at login, so the second step counts against the same
+// brute-force counter. The fingerprint ties it to the current password.
+type twoFactorChallengeClaims struct {
+	Stage       string `json:"stage"`
+	Identifier  string `json:"idn"`
+	Fingerprint string `json:"fp"`
+	jwt.StandardClaims
+}
+
+// adminTwoFactorRequired reports whether every admin must use two-factor authentication
+func adminTwoFactorRequired() bool {
+	required, _ := strconv.ParseBool(middleware.GetEnv("ADMIN_2FA_REQUIRED"))
+	return required
+}
+
+// twoFactorChallenge answers a correct admin password when a second step is needed
+func twoFactorChallenge(c *fiber.Ctx, admin users.Admin, identifier string) error {
+	stage := twoFactorStageVerify
+	if !admin.TOTPEnabled {
+		stage = twoFactorStageEnroll
+	}
+
+	now := time.Now()
+	claims := twoFactorChallengeClaims{
+		Stage:       stage,
+		Identifier:  identifier,
+		Fingerprint: passwordFingerprint(admin.Password),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   strconv.Itoa(admin.AdminId),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(twoFactorChallengeTTL).Unix(),
+		},
+	}
+	challenge, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(purposeKey("admin-2fa-challenge"))
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Internal Server Error!",
+			Data: errors.ErrorModel{
+				Message:   "Error generating token",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	message := "Enter the code from your authenticator app"
+	if stage == twoFactorStageEnroll {
+		message = "Two-factor authentication must be set up before logging in"
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "202",
+		Message: message,
+		Data: fiber.Map{
+			"two_factor_required": true,
+			"enrollment_required": stage == twoFactorStageEnroll,
+			"challenge_token":     challenge,
+			"expires_in":          int(twoFactorChallengeTTL / time.Second),
+		},
+	})
+}
+
+// parseTwoFactorChallenge returns the admin a challenge was issued to, provided the password and
+// two-factor state haven't changed since
+func parseTwoFactorChallenge(db *gorm.DB, tokenString string, stage string) (users.Admin, string, error) {
+	claims := &twoFactorChallengeClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return purposeKey("admin-2fa-challenge"), nil
+	})
+	if err != nil {
+		return users.Admin{}, "", err
+	}
+	if claims.Stage != stage {
+		return users.Admin{}, "", fmt.Errorf("challenge was issued for another step")
+	}
+
+	var admin users.Admin
+	if err := db.First(&admin, "admin_id = ?", claims.Subject).Error; err != nil {
+		return users.Admin{}, "", fmt.Errorf("account not found")
+	}
+	if !hmac.Equal([]byte(claims.Fingerprint), []byte(passwordFingerprint(admin.Password))) {
+		return users.Admin{}, "", fmt.Errorf("password has changed")
+	}
+	if (stage == twoFactorStageVerify) != admin.TOTPEnabled {
+		return users.Admin{}, "", fmt.Errorf("two-factor settings have changed")
+	}
+	return admin, claims.Identifier, nil
+}
+
+// AdminTwoFactorLogin completes an admin login with a TOTP code or a recovery code
+func AdminTwoFactorLogin(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.ChallengeToken == "" || (body.Code == "" && body.RecoveryCode == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Challenge token and a code or recovery code are required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	admin, identifier, err := parseTwoFactorChallenge(db, body.ChallengeToken, twoFactorStageVerify)
+	if err != nil {
+		return invalidChallenge(c, err)
+	}
+
+	guard := newLoginGuard(db, users.RoleAdmin, identifier, c.IP())
+	if retryAfter := guard.lockedFor(); retryAfter > 0 {
+		return loginLocked(c, retryAfter)
+	}
+
+	usedRecovery := false
+	if body.Code != "" {
+		err = acceptTOTPCode(db, admin, body.Code)
+	} else {
+		err = useRecoveryCode(db, admin.AdminId, body.RecoveryCode)
+		usedRecovery = err == nil
+	}
+	if err == errTwoFactorCode {
+		guard.failed()
+		return twoFactorCodeRejected(c)
+	}
+	if err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+
+	guard.succeeded()
+
+	data := fiber.Map{}
+	if usedRecovery {
+		var remaining int64
+		db.Model(&users.AdminRecoveryCode{}).Where("admin_id = ? AND used_at IS NULL", admin.AdminId).Count(&remaining)
+		data["recovery_codes_left"] = remaining
+		log.Printf("Admin %d logged in with a recovery code (%d left)", admin.AdminId, remaining)
+	}
+	return adminLoginSuccess(c, admin, data)
+}
+
+// AdminTwoFactorEnrollStart begins enrolment for an admin who has to set up two-factor
+// authentication before their first login
+func AdminTwoFactorEnrollStart(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.ChallengeToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Challenge token is required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	admin, _, err := parseTwoFactorChallenge(db, body.ChallengeToken, twoFactorStageEnroll)
+	if err != nil {
+		return invalidChallenge(c, err)
+	}
+
+	return startEnrollment(c, db, admin)
+}
+
+// AdminTwoFactorEnrollConfirm finishes enrolment during login and issues the JWT together with
+// the recovery codes
+func AdminTwoFactorEnrollConfirm(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	var body struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.ChallengeToken == "" || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Challenge token and code are required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	admin, identifier, err := parseTwoFactorChallenge(db, body.ChallengeToken, twoFactorStageEnroll)
+	if err != nil {
+		return invalidChallenge(c, err)
+	}
+
+	guard := newLoginGuard(db, users.RoleAdmin, identifier, c.IP())
+	if retryAfter := guard.lockedFor(); retryAfter > 0 {
+		return loginLocked(c, retryAfter)
+	}
+
+	recoveryCodes, err := confirmEnrollment(db, &admin, body.Code)
+	if err == errTwoFactorCode {
+		guard.failed()
+		return twoFactorCodeRejected(c)
+	}
+	if err == errTwoFactorNotSetUp {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "Enrolment not started",
+			Data: errors.ErrorModel{
+				Message:   "Start enrolment first to get a secret for your authenticator app",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	if err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+
+	guard.succeeded()
+
+	return adminLoginSuccess(c, admin, fiber.Map{"recovery_codes": recoveryCodes})
+}
+
+// AdminTwoFactorStatus returns whether the logged-in admin has two-factor authentication enabled
+func AdminTwoFactorStatus(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var admin users.Admin
+	if err := db.First(&admin, "admin_id = ?", claims.UserId).Error; err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+
+	var remaining int64
+	db.Model(&users.AdminRecoveryCode{}).Where("admin_id = ? AND used_at IS NULL", admin.AdminId).Count(&remaining)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Success",
+		Data: fiber.Map{
+			"enabled":             admin.TOTPEnabled,
+			"enabled_at":          admin.TOTPEnabledAt,
+			"required":            adminTwoFactorRequired(),
+			"recovery_codes_left": remaining,
+		},
+	})
+}
+
+// AdminTwoFactorEnroll starts enrolment for a logged-in admin. Any unconfirmed secret from an
+// earlier attempt is replaced.
+func AdminTwoFactorEnroll(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var admin users.Admin
+	if err := db.First(&admin, "admin_id = ?", claims.UserId).Error; err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+
+	return startEnrollment(c, db, admin)
+}
+
+// AdminTwoFactorConfirm enables two-factor authentication with the first code from the
+// authenticator app and returns the recovery codes
+func AdminTwoFactorConfirm(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Code is required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var admin users.Admin
+	if err := db.First(&admin, "admin_id = ?", claims.UserId).Error; err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+
+	recoveryCodes, err := confirmEnrollment(db, &admin, body.Code)
+	switch err {
+	case nil:
+	case errTwoFactorCode:
+		return twoFactorCodeRejected(c)
+	case errTwoFactorEnrolled, errTwoFactorNotSetUp:
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "Cannot confirm two-factor authentication",
+			Data: errors.ErrorModel{
+				Message:   err.Error(),
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	default:
+		return twoFactorDatabaseError(c, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Two-factor authentication enabled. Store these recovery codes somewhere safe; they will not be shown again.",
+		Data: fiber.Map{
+			"recovery_codes": recoveryCodes,
+		},
+	})
+}
+
+// AdminTwoFactorRegenerateRecoveryCodes replaces all recovery codes. Needs a current TOTP code.
+func AdminTwoFactorRegenerateRecoveryCodes(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Code is required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var admin users.Admin
+	if err := db.First(&admin, "admin_id = ?", claims.UserId).Error; err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+	if !admin.TOTPEnabled {
+		return twoFactorNotEnabled(c)
+	}
+
+	var recoveryCodes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := acceptTOTPCode(tx, admin, body.Code); err != nil {
+			return err
+		}
+		var err error
+		recoveryCodes, err = replaceRecoveryCodes(tx, admin.AdminId)
+		return err
+	})
+	if err == errTwoFactorCode {
+		return twoFactorCodeRejected(c)
+	}
+	if err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Recovery codes regenerated. The old codes no longer work.",
+		Data: fiber.Map{
+			"recovery_codes": recoveryCodes,
+		},
+	})
+}
+
+// AdminTwoFactorDisable turns two-factor authentication off. Needs the password and a current
+// TOTP code, and is refused while ADMIN_2FA_REQUIRED is set.
+func AdminTwoFactorDisable(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	if adminTwoFactorRequired() {
+		return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "Two-factor authentication is required",
+			Data: errors.ErrorModel{
+				Message:   "Two-factor authentication cannot be disabled on this server",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var body struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Password == "" || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Password and code are required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var admin users.Admin
+	if err := db.First(&admin, "admin_id = ?", claims.UserId).Error; err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+	if !admin.TOTPEnabled {
+		return twoFactorNotEnabled(c)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(body.Password)) != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Incorrect password",
+			Data: errors.ErrorModel{
+				Message:   "The password you entered is incorrect",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := acceptTOTPCode(tx, admin, body.Code); err != nil {
+			return err
+		}
+		if err := tx.Model(&users.Admin{}).Where("admin_id = ?", admin.AdminId).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled":    false,
+			"totp_enabled_at": nil,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("admin_id = ?", admin.AdminId).Delete(&users.AdminRecoveryCode{}).Error
+	})
+	if err == errTwoFactorCode {
+		return twoFactorCodeRejected(c)
+	}
+	if err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAdminTwoFactorDisable,
+		TargetType: users.RoleAdmin,
+		TargetId:   admin.AdminId,
+		Before:     map[string]interface{}{"totp_enabled": true},
+		After:      map[string]interface{}{"totp_enabled": false},
+	})
+
+	log.Printf("Admin %d disabled two-factor authentication", admin.AdminId)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Two-factor authentication disabled",
+	})
+}
+
+// startEnrollment stores a fresh secret and returns it with the provisioning URI for the QR code
+func startEnrollment(c *fiber.Ctx, db *gorm.DB, admin users.Admin) error {
+	if admin.TOTPEnabled {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "Already enabled",
+			Data: errors.ErrorModel{
+				Message:   "Disable two-factor authentication before enrolling a new authenticator",
+				IsSuccess: false,
+				Error:     errTwoFactorEnrolled.Error(),
+			},
+		})
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+	if err := db.Model(&users.Admin{}).Where("admin_id = ?", admin.AdminId).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return twoFactorDatabaseError(c, err)
+	}
+
+	account := admin.Email
+	if account == "" {
+		account = admin.Username
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Scan the QR code with your authenticator app, then confirm with a code",
+		Data: fiber.Map{
+			"secret":           secret,
+			"provisioning_uri": totpProvisioningURI(secret, account),
+		},
+	})
+}
+
+// confirmEnrollment enables two-factor authentication once a code from the new secret checks out
+func confirmEnrollment(db *gorm.DB, admin *users.Admin, code string) ([]string, error) {
+	if admin.TOTPEnabled {
+		return nil, errTwoFactorEnrolled
+	}
+	if admin.TOTPSecret == "" {
+		return nil, errTwoFactorNotSetUp
+	}
+
+	var recoveryCodes []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := acceptTOTPCode(tx, *admin, code); err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Model(&users.Admin{}).Where("admin_id = ?", admin.AdminId).Updates(map[string]interface{}{
+			"totp_enabled":    true,
+			"totp_enabled_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		admin.TOTPEnabled = true
+		admin.TOTPEnabledAt = &now
+
+		var err error
+		recoveryCodes, err = replaceRecoveryCodes(tx, admin.AdminId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Admin %d enabled two-factor authentication", admin.AdminId)
+	return recoveryCodes, nil
+}
+
+// acceptTOTPCode checks a code and records its time step. The conditional update makes two
+// concurrent requests with the same code fail for one of them.
+func acceptTOTPCode(db *gorm.DB, admin users.Admin, code string) error {
+	step, ok := matchTOTP(admin.TOTPSecret, code, admin.TOTPLastStep, time.Now())
+	if !ok {
+		return errTwoFactorCode
+	}
+
+	result := db.Model(&users.Admin{}).
+		Where("admin_id = ? AND totp_last_step < ?", admin.AdminId, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errTwoFactorCode
+	}
+	return nil
+}
+
+// useRecoveryCode marks an unused recovery code as used
+func useRecoveryCode(db *gorm.DB, adminId int, code string) error {
+	result := db.Model(&users.AdminRecoveryCode{}).
+		Where("admin_id = ? AND code_hash = ? AND used_at IS NULL", adminId, hashRecoveryCode(adminId, code)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errTwoFactorCode
+	}
+	return nil
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, adminId int) ([]string, error) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Where("admin_id = ?", adminId).Delete(&users.AdminRecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]users.AdminRecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		rows = append(rows, users.AdminRecoveryCode{
+			AdminId:  adminId,
+			CodeHash: hashRecoveryCode(adminId, code),
+		})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// adminLoginSuccess issues the JWT and answers like a password-only login, plus any extra fields.
+// Every admin login path ends here, so disabled admins are refused here.
+func adminLoginSuccess(c *fiber.Ctx, admin users.Admin, extra fiber.Map) error {
+	if admin.DisabledAt != nil {
+		return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "This admin account is disabled",
+			Data: errors.ErrorModel{
+				Message:   "Ask a super admin to enable your account",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	token, err := GenerateJWT(admin.AdminId, users.RoleAdmin)
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Internal Server Error!",
+			Data: errors.ErrorModel{
+				Message:   "Error generating token",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	data := map[string]interface{}{
+		"token": token,
+		"admin": admin,
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Login Successful!",
+		Data:    data,
+	})
+}
+
+func invalidChallenge(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+		RetCode: "401",
+		Message: "Invalid or expired login challenge",
+		Data: errors.ErrorModel{
+			Message:   "Please log in again",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
+
+func twoFactorCodeRejected(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+		RetCode: "401",
+		Message: "Invalid code",
+		Data: errors.ErrorModel{
+			Message:   "The code is incorrect, expired or has already been used",
+			IsSuccess: false,
+			Error:     errTwoFactorCode.Error(),
+		},
+	})
+}
+
+func twoFactorNotEnabled(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+		RetCode: "409",
+		Message: "Two-factor authentication is not enabled",
+		Data: errors.ErrorModel{
+			Message:   "Enable two-factor authentication first",
+			IsSuccess: false,
+		},
+	})
+}
+
+func twoFactorDatabaseError(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Internal Server Error!",
+		Data: errors.ErrorModel{
+			Message:   "An error occurred while processing your request",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
@@ -1,65 +1,31 @@
 package signuplogin
 
 import (
+	"fixify_backend/mailer"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error" // Import the errors package
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
 	"fmt"
-	"log"
-	"math/rand"
-	"os"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"gopkg.in/gomail.v2"
 )
 
-// Function to generate a random verification code
-func GenerateVerificationCode() int {
-	rand.Seed(time.Now().UnixNano())
-	code := rand.Intn(999999-100000) + 100000 // Generate a 6-digit code
-	return (code)
-}
-
-// Function to send the verification email
-func SendVerificationEmail(userEmail string, verificationCode int) error {
-	from := os.Getenv("FROM")            // Your Gmail address
-	password := os.Getenv("APPASS")      // Your Gmail App Passwords
-	smtpHost := os.Getenv("SMTPHOST")    // Gmail's SMTP server
-	smtpPortStr := os.Getenv("SMTPPORT") // Port for TLS (recommended)
-
-	// Convert the SMTP port string to an integer
-	smtpPort, err := strconv.Atoi(smtpPortStr)
-	if err != nil {
-		log.Fatal("Error converting SMTP port to integer:", err)
-	}
-
-	// Convert the verification code to a string
-	verificationCodeStr := strconv.Itoa(verificationCode)
-
-	// Create the email message with the verification code
-	message := gomail.NewMessage()
-	message.SetHeader("From", from)                                                 // Sender's email
-	message.SetHeader("To", userEmail)                                              // Recipient's email
-	message.SetHeader("Subject", "Email Verification Code")                         // Email subject
-	message.SetBody("text/plain", "Your verification code is "+verificationCodeStr) // Email body
-	log.Println(verificationCode)
-	// Set up the SMTP client
-	dialer := gomail.NewDialer(smtpHost, smtpPort, from, password)
-
-	// Send the email
-	if err := dialer.DialAndSend(message); err != nil {
-		log.Println("Failed to send email:", err)
-		return err
-	}
-
-	log.Println("Verification email sent successfully!")
-	return nil
+// Function to send the verification email. The email is queued in the outbox, so a mail server
+// outage delays it instead of failing signup.
+func SendVerificationEmail(userEmail string, verificationCode string) error {
+	return mailer.SendTemplate(middleware.DBConn, userEmail, mailer.TemplateVerification, mailer.VerificationData{
+		Code:             verificationCode,
+		ExpiresInMinutes: int(VerificationCodeTTL / time.Minute),
+	})
 }
 
-// User signup function with email verification (no password or other fields)
+// User signup function with email verification (no password or other fields). Also used to resend
+// the code; a new code can be requested once a minute.
 func EmailVer(c *fiber.Ctx) error {
 	db := middleware.DBConn
 	logac := new(users.EmailVer)
@@ -77,108 +43,35 @@ func EmailVer(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if the email is already in use
-	var existingUserEmail users.EmailVer
-	if err := db.Where("email = ?", logac.Email).First(&existingUserEmail).Error; err == nil {
-		// Generate the verification code
-		code := GenerateVerificationCode()
-
-		// Save the email and verification code in the database
-		emailVer := &users.EmailVer{
-			Email: logac.Email,
-			Code:  strconv.Itoa(code),
-		}
-		if err := db.Model(&users.EmailVer{}).Where("email = ?", emailVer.Email).Update("code", emailVer.Code).Error; err != nil {
-			return c.JSON(response.ResponseModel{
-				RetCode: "500",
-				Message: "Error saving verification code",
-				Data: errors.ErrorModel{
-					Message:   "Failed to save verification code!",
-					IsSuccess: false,
-					Error:     err.Error(),
-				},
-			})
-		}
-
-		// Send the verification email
-		err := SendVerificationEmail(logac.Email, code)
-		if err != nil {
-			return c.JSON(response.ResponseModel{
-				RetCode: "500",
-				Message: "Failed to send verification email",
-				Data: errors.ErrorModel{
-					Message:   "Error sending verification email",
-					IsSuccess: false,
-					Error:     err.Error(),
-				},
-			})
-		}
-
-		// Set a 3-minute timer to automatically delete the verification code from the database
-		go func() {
-			db1 := middleware.DBConn
-			// Wait for 3 minutes (60 seconds)
-			time.AfterFunc(5*time.Minute, func() {
-				// Delete the email verification code after the delay
-				if err := db1.Where("email = ?", logac.Email).Delete(&users.EmailVer{}).Error; err != nil {
-					// Log an error if the deletion fails
-					// Replace with your preferred logging method
-					fmt.Println("Error deleting verification code:", err)
-				} else {
-					fmt.Println("Verification code deleted successfully.") // Success message
-				}
-			})
-		}()
-	} else {
-		// Generate the verification code
-		code := GenerateVerificationCode()
-
-		// Save the email and verification code in the database
-		emailVer := &users.EmailVer{
-			Email: logac.Email,
-			Code:  strconv.Itoa(code),
-		}
-		if err := db.Create(emailVer).Error; err != nil {
-			return c.JSON(response.ResponseModel{
-				RetCode: "500",
-				Message: "Error saving verification code",
-				Data: errors.ErrorModel{
-					Message:   "Failed to save verification code!",
-					IsSuccess: false,
-					Error:     err.Error(),
-				},
-			})
-		}
+	logac.Email = strings.ToLower(strings.TrimSpace(logac.Email))
+	if logac.Email == "" {
+		return c.JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Email is required",
+				IsSuccess: false,
+			},
+		})
+	}
 
-		// Send the verification email
-		err := SendVerificationEmail(logac.Email, code)
-		if err != nil {
-			return c.JSON(response.ResponseModel{
-				RetCode: "500",
-				Message: "Failed to send verification email",
-				Data: errors.ErrorModel{
-					Message:   "Error sending verification email",
-					IsSuccess: false,
-					Error:     err.Error(),
-				},
-			})
-		}
+	// Generate and store the verification code
+	code, status, err := IssueEmailCode(db, logac.Email, VerificationPurposeSignup)
+	if err != nil {
+		return verificationCodeError(c, status, err)
+	}
 
-		// Set a 3-minute timer to automatically delete the verification code from the database
-		go func() {
-			db1 := middleware.DBConn
-			// Wait for 3 minutes (60 seconds)
-			time.AfterFunc(5*time.Minute, func() {
-				// Delete the email verification code after the delay
-				if err := db1.Where("email = ?", logac.Email).Delete(&users.EmailVer{}).Error; err != nil {
-					// Log an error if the deletion fails
-					// Replace with your preferred logging method
-					fmt.Println("Error deleting verification code:", err)
-				} else {
-					fmt.Println("Verification code deleted successfully.") // Success message
-				}
-			})
-		}()
+	// Send the verification email
+	if err := SendVerificationEmail(logac.Email, code); err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to send verification email",
+			Data: errors.ErrorModel{
+				Message:   "Error sending verification email",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
 	}
 
 	// Return the success response
@@ -207,40 +100,93 @@ func EmailVerCode(c *fiber.Ctx) error {
 		})
 	}
 
-	// Check if the code matches
-	var existingCode users.EmailVer
-	if err := db.Where("code = ?", logac.Code).First(&existingCode).Error; err != nil {
-		// If no matching record is found, return an error
+	logac.Email = strings.ToLower(strings.TrimSpace(logac.Email))
+	if logac.Email == "" || logac.Code == "" {
 		return c.JSON(response.ResponseModel{
 			RetCode: "400",
-			Message: "Invalid Verification Code!",
+			Message: "Invalid Request!",
 			Data: errors.ErrorModel{
-				Message:   "The provided verification code is invalid",
+				Message:   "Email and code are required",
 				IsSuccess: false,
-				Error:     err.Error(),
 			},
 		})
 	}
 
-	// Automatically delete the verification code and email from the database after verification
-	go func() {
-		fmt.Println("Starting the deletion process...") // Debugging message
-
-		// Delete the email verification code after the delay
-		if err := db.Where("code = ?", logac.Code).Delete(&users.EmailVer{}).Error; err != nil {
-			// Log an error if the deletion fails
-			// Replace with your preferred logging method
-			fmt.Println("Error deleting verification code:", err)
-		} else {
-			fmt.Println("Verification code deleted successfully.") // Success message
-		}
-
-	}()
+	// The code must match the email it was sent to
+	if status, err := CheckEmailCode(db, logac.Email, VerificationPurposeSignup, logac.Code); err != nil {
+		return verificationCodeError(c, status, err)
+	}
 
 	// If the email and code match, return a success response
+	logac.Code = ""
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Verification Successful!",
 		Data:    logac,
 	})
 }
+
+// verificationCodeError maps the errors of IssueEmailCode/CheckEmailCode to a response.
+// Lockouts and cooldowns set Retry-After.
+func verificationCodeError(c *fiber.Ctx, status CodeStatus, err error) error {
+	retryAfter := int(math.Ceil(status.RetryAfter.Seconds()))
+
+	switch err {
+	case ErrCodeCooldown:
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return c.Status(fiber.StatusTooManyRequests).JSON(response.ResponseModel{
+			RetCode: "429",
+			Message: "Please wait before requesting another code",
+			Data: errors.ErrorModel{
+				Message:   fmt.Sprintf("You can request a new code in %d seconds", retryAfter),
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	case ErrCodeLocked:
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+		return c.Status(fiber.StatusTooManyRequests).JSON(response.ResponseModel{
+			RetCode: "429",
+			Message: "Too many attempts",
+			Data: errors.ErrorModel{
+				Message:   fmt.Sprintf("Too many incorrect codes. Try again in %d minutes", int(math.Ceil(status.RetryAfter.Minutes()))),
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	case ErrCodeExpired:
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Verification Code Expired!",
+			Data: errors.ErrorModel{
+				Message:   "The verification code has expired. Please request a new one",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	case ErrCodeInvalid:
+		message := "The provided verification code is invalid"
+		if status.AttemptsLeft > 0 {
+			message = fmt.Sprintf("%s. %d attempt(s) left", message, status.AttemptsLeft)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Verification Code!",
+			Data: errors.ErrorModel{
+				Message:   message,
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Error saving verification code",
+		Data: errors.ErrorModel{
+			Message:   "Failed to process verification code!",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
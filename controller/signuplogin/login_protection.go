@@ -0,0 +1,147 @@
+package signuplogin
+
+import (
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Brute-force protection. Each identifier gets a few free attempts, then every further failure
+// locks it for twice as long as the previous one. IPs get a larger allowance since many users
+// can share one address. Counters forget failures older than loginFailureWindow.
+const (
+	accountFreeAttempts = 5
+	ipFreeAttempts      = 20
+	loginBaseLockout    = time.Minute
+	loginMaxLockout     = time.Hour
+	loginFailureWindow  = 24 * time.Hour
+)
+
+// dummyPasswordHash is compared against when the account does not exist, so a missing account
+// takes as long to reject as a wrong password
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("fixify-dummy-password"), bcrypt.DefaultCost)
+
+// loginGuard tracks one login attempt for an account identifier and the client IP
+type loginGuard struct {
+	db         *gorm.DB
+	accountKey string
+	ip         string
+}
+
+func newLoginGuard(db *gorm.DB, role string, identifier string, ip string) loginGuard {
+	return loginGuard{
+		db:         db,
+		accountKey: role + ":" + strings.ToLower(strings.TrimSpace(identifier)),
+		ip:         ip,
+	}
+}
+
+// lockedFor returns how long the identifier or IP is still locked out, or 0
+func (g loginGuard) lockedFor() time.Duration {
+	var attempts []users.LoginAttempt
+	if err := g.db.Where("(scope = ? AND login_key = ?) OR (scope = ? AND login_key = ?)",
+		users.LoginScopeAccount, g.accountKey, users.LoginScopeIP, g.ip).
+		Find(&attempts).Error; err != nil {
+		log.Printf("Login guard lookup failed: %v", err)
+		return 0
+	}
+
+	var longest time.Duration
+	now := time.Now()
+	for _, attempt := range attempts {
+		if attempt.LockedUntil != nil && attempt.LockedUntil.After(now) {
+			if remaining := attempt.LockedUntil.Sub(now); remaining > longest {
+				longest = remaining
+			}
+		}
+	}
+	return longest
+}
+
+// failed records a failed attempt against both the identifier and the IP
+func (g loginGuard) failed() {
+	g.recordFailure(users.LoginScopeAccount, g.accountKey, accountFreeAttempts)
+	g.recordFailure(users.LoginScopeIP, g.ip, ipFreeAttempts)
+}
+
+// succeeded clears the identifier's counter. The IP counter is left alone so one valid account
+// can't be used to reset an attacker's budget.
+func (g loginGuard) succeeded() {
+	if err := g.db.Where("scope = ? AND login_key = ?", users.LoginScopeAccount, g.accountKey).
+		Delete(&users.LoginAttempt{}).Error; err != nil {
+		log.Printf("Failed to reset login attempts: %v", err)
+	}
+}
+
+func (g loginGuard) recordFailure(scope string, key string, freeAttempts int) {
+	now := time.Now()
+
+	err := g.db.Transaction(func(tx *gorm.DB) error {
+		attempt := users.LoginAttempt{Scope: scope, Key: key}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&attempt).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND login_key = ?", scope, key).
+			First(&attempt).Error; err != nil {
+			return err
+		}
+
+		if now.Sub(attempt.LastFailureAt) > loginFailureWindow {
+			attempt.Failures = 0
+		}
+		attempt.Failures++
+		attempt.LastFailureAt = now
+		attempt.LastIP = g.ip
+
+		if over := attempt.Failures - freeAttempts; over > 0 {
+			lockout := loginBaseLockout * time.Duration(math.Pow(2, float64(min(over-1, 10))))
+			if lockout > loginMaxLockout {
+				lockout = loginMaxLockout
+			}
+			lockedUntil := now.Add(lockout)
+			attempt.LockedUntil = &lockedUntil
+		}
+
+		return tx.Save(&attempt).Error
+	})
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+	}
+}
+
+// loginFailed is the single response for an unknown account or a wrong password
+func loginFailed(c *fiber.Ctx) error {
+	return c.JSON(response.ResponseModel{
+		RetCode: "401",
+		Message: "Login Failed!",
+		Data: errors.ErrorModel{
+			Message:   "Invalid email/username or password",
+			IsSuccess: false,
+			Error:     "invalid credentials",
+		},
+	})
+}
+
+func loginLocked(c *fiber.Ctx, retryAfter time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	return c.Status(fiber.StatusTooManyRequests).JSON(response.ResponseModel{
+		RetCode: "429",
+		Message: "Too many login attempts",
+		Data: errors.ErrorModel{
+			Message:   "Too many failed login attempts. Please try again later or reset your password",
+			IsSuccess: false,
+			Error:     "login temporarily locked",
+		},
+	})
+}
@@ -0,0 +1,401 @@
+package signuplogin
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fixify_backend/controller"
+	"fixify_backend/mailer"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Admins get their own purpose so a user and an admin sharing an address never share a code
+const VerificationPurposeAdminPasswordReset = "admin_password_reset"
+
+// passwordResetClaims is the signed token that authorizes setting a new password. It is handed
+// out after a correct code, or embedded in the emailed link. The fingerprint ties it to the
+// current password hash, so it stops working once the password has been changed.
+type passwordResetClaims struct {
+	Role        string `json:"role"`
+	Fingerprint string `json:"fp"`
+	jwt.StandardClaims
+}
+
+type resetAccount struct {
+	id           uint
+	email        string
+	passwordHash string
+	role         string
+}
+
+func (a resetAccount) purpose() string {
+	if a.role == users.RoleAdmin {
+		return VerificationPurposeAdminPasswordReset
+	}
+	return VerificationPurposePasswordReset
+}
+
+// ForgotPassword starts a password reset for a client or repairman
+func ForgotPassword(c *fiber.Ctx) error { return forgotPassword(c, users.RoleUser) }
+
+// AdminForgotPassword starts a password reset for an admin
+func AdminForgotPassword(c *fiber.Ctx) error { return forgotPassword(c, users.RoleAdmin) }
+
+// VerifyPasswordResetCode exchanges an emailed code for a reset token
+func VerifyPasswordResetCode(c *fiber.Ctx) error { return verifyPasswordResetCode(c, users.RoleUser) }
+
+// AdminVerifyPasswordResetCode exchanges an emailed code for an admin reset token
+func AdminVerifyPasswordResetCode(c *fiber.Ctx) error {
+	return verifyPasswordResetCode(c, users.RoleAdmin)
+}
+
+// ResetPassword sets a new password using a reset token and signs the account out everywhere
+func ResetPassword(c *fiber.Ctx) error { return resetPassword(c, users.RoleUser) }
+
+// AdminResetPassword sets a new admin password using a reset token
+func AdminResetPassword(c *fiber.Ctx) error { return resetPassword(c, users.RoleAdmin) }
+
+func forgotPassword(c *fiber.Ctx, role string) error {
+	db := middleware.DBConn
+
+	var body struct {
+		Email string `json:"email"`
+	}
+	if err := c.BodyParser(&body); err != nil || strings.TrimSpace(body.Email) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Email is required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	account, found, err := findResetAccountByEmail(db, role, body.Email)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Internal Server Error!",
+			Data: errors.ErrorModel{
+				Message:   "An error occurred while processing your request",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	// The response is the same whether or not the account exists, so this endpoint can't be used
+	// to find out which addresses are registered. Cooldowns are likewise applied silently.
+	if found {
+		if err := startPasswordReset(db, account); err != nil && err != ErrCodeCooldown && err != ErrCodeLocked {
+			log.Printf("Password reset for %s %d failed: %v", account.role, account.id, err)
+		}
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "If an account exists for this email, a password reset code has been sent.",
+	})
+}
+
+func verifyPasswordResetCode(c *fiber.Ctx, role string) error {
+	db := middleware.DBConn
+
+	var body struct {
+		Email string `json:"email"`
+		Code  string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || strings.TrimSpace(body.Email) == "" || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Email and code are required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	account, found, err := findResetAccountByEmail(db, role, body.Email)
+	if err != nil || !found {
+		return verificationCodeError(c, CodeStatus{}, ErrCodeInvalid)
+	}
+
+	if status, err := CheckEmailCode(db, account.email, account.purpose(), body.Code); err != nil {
+		return verificationCodeError(c, status, err)
+	}
+
+	token, err := newPasswordResetToken(account)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Internal Server Error!",
+			Data: errors.ErrorModel{
+				Message:   "Error generating token",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Code verified. You can now set a new password.",
+		Data: fiber.Map{
+			"reset_token": token,
+			"expires_in":  int(VerificationCodeTTL.Seconds()),
+		},
+	})
+}
+
+func resetPassword(c *fiber.Ctx, role string) error {
+	db := middleware.DBConn
+
+	var body struct {
+		ResetToken  string `json:"reset_token"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.ResetToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Reset token and new password are required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	account, err := parsePasswordResetToken(db, role, body.ResetToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Invalid or expired reset link",
+			Data: errors.ErrorModel{
+				Message:   "Please request a new password reset",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	if err := middleware.ValidatePasswordStrength(body.NewPassword); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Weak password",
+			Data: errors.ErrorModel{
+				Message:   err.Error(),
+				IsSuccess: false,
+			},
+		})
+	}
+
+	hashedPassword, err := middleware.HashPassword(body.NewPassword)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to hash password",
+			Data: errors.ErrorModel{
+				Message:   "Error hashing password",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := updateAccountPassword(tx, account, hashedPassword); err != nil {
+			return err
+		}
+		if err := RevokeSessions(tx, account.role, account.id); err != nil {
+			return err
+		}
+		// Any code still pending for this reset is no longer needed
+		return tx.Where("email = ? AND purpose = ?", account.email, account.purpose()).Delete(&users.EmailVer{}).Error
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to update password",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAccountPasswordReset,
+		TargetType: account.role,
+		TargetId:   account.id,
+		Before:     map[string]interface{}{"password": account.passwordHash},
+		After:      map[string]interface{}{"password": hashedPassword},
+		ActorId:    account.id,
+		ActorRole:  account.role,
+	})
+
+	if err := mailer.SendTemplate(db, account.email, mailer.TemplateNotification, mailer.NotificationData{
+		Title: "Your password was changed",
+		Body:  "Your password was just reset and you have been signed out of all devices. If this wasn't you, reset your password again and contact support.",
+	}); err != nil {
+		log.Printf("Failed to queue password changed email: %v", err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Password updated. Please log in with your new password.",
+	})
+}
+
+// StartPasswordReset emails a reset code (and link, when PASSWORD_RESET_URL is set) to an
+// account. role is users.RoleUser or users.RoleAdmin.
+func StartPasswordReset(db *gorm.DB, role string, id uint) error {
+	account, err := findResetAccountByID(db, role, id)
+	if err != nil {
+		return err
+	}
+	return startPasswordReset(db, account)
+}
+
+func startPasswordReset(db *gorm.DB, account resetAccount) error {
+	code, _, err := IssueEmailCode(db, account.email, account.purpose())
+	if err != nil {
+		return err
+	}
+
+	data := mailer.PasswordResetData{
+		Code:             code,
+		ExpiresInMinutes: int(VerificationCodeTTL / time.Minute),
+	}
+
+	if base := middleware.GetEnv("PASSWORD_RESET_URL"); base != "" {
+		token, err := newPasswordResetToken(account)
+		if err != nil {
+			return err
+		}
+		link, err := url.Parse(base)
+		if err != nil {
+			return fmt.Errorf("invalid PASSWORD_RESET_URL: %v", err)
+		}
+		query := link.Query()
+		query.Set("token", token)
+		query.Set("account", account.role)
+		link.RawQuery = query.Encode()
+		data.Link = link.String()
+	}
+
+	return mailer.SendTemplate(db, account.email, mailer.TemplatePasswordReset, data)
+}
+
+func findResetAccountByEmail(db *gorm.DB, role string, email string) (resetAccount, bool, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	var account resetAccount
+	var err error
+	if role == users.RoleAdmin {
+		var admin users.Admin
+		err = db.Where("LOWER(email) = ?", email).First(&admin).Error
+		account = resetAccount{id: uint(admin.AdminId), email: email, passwordHash: admin.Password, role: role}
+	} else {
+		// Users reset through their verified address, which an admin editing the email can't change.
+		// It is unique, so the lookup can't pick another account.
+		var user users.User
+		err = db.Where("LOWER(verified_email) = ?", email).First(&user).Error
+		account = resetAccount{id: user.UserId, email: email, passwordHash: user.Password, role: role}
+	}
+
+	if err == gorm.ErrRecordNotFound {
+		return account, false, nil
+	}
+	return account, err == nil, err
+}
+
+func findResetAccountByID(db *gorm.DB, role string, id uint) (resetAccount, error) {
+	if role == users.RoleAdmin {
+		var admin users.Admin
+		if err := db.First(&admin, "admin_id = ?", id).Error; err != nil {
+			return resetAccount{}, err
+		}
+		return resetAccount{id: id, email: strings.ToLower(admin.Email), passwordHash: admin.Password, role: role}, nil
+	}
+
+	var user users.User
+	if err := db.First(&user, "user_id = ?", id).Error; err != nil {
+		return resetAccount{}, err
+	}
+	return resetAccount{id: id, email: user.ResetEmail(), passwordHash: user.Password, role: role}, nil
+}
+
+func updateAccountPassword(db *gorm.DB, account resetAccount, hashedPassword string) error {
+	if account.role == users.RoleAdmin {
+		return db.Model(&users.Admin{}).Where("admin_id = ?", account.id).Update("password", hashedPassword).Error
+	}
+	return db.Model(&users.User{}).Where("user_id = ?", account.id).Update("password", hashedPassword).Error
+}
+
+func newPasswordResetToken(account resetAccount) (string, error) {
+	now := time.Now()
+	claims := passwordResetClaims{
+		Role:        account.role,
+		Fingerprint: passwordFingerprint(account.passwordHash),
+		StandardClaims: jwt.StandardClaims{
+			Subject:   strconv.FormatUint(uint64(account.id), 10),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(VerificationCodeTTL).Unix(),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(purposeKey("password-reset"))
+}
+
+// parsePasswordResetToken checks the signature, expiry, account type and that the password has not
+// changed since the token was issued (which makes each token single use)
+func parsePasswordResetToken(db *gorm.DB, role string, tokenString string) (resetAccount, error) {
+	claims := &passwordResetClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return purposeKey("password-reset"), nil
+	})
+	if err != nil {
+		return resetAccount{}, err
+	}
+	if claims.Role != role {
+		return resetAccount{}, fmt.Errorf("token was issued for another account type")
+	}
+
+	id, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		return resetAccount{}, fmt.Errorf("invalid token subject")
+	}
+	account, err := findResetAccountByID(db, role, uint(id))
+	if err != nil {
+		return resetAccount{}, fmt.Errorf("account not found")
+	}
+
+	if !hmac.Equal([]byte(claims.Fingerprint), []byte(passwordFingerprint(account.passwordHash))) {
+		return resetAccount{}, fmt.Errorf("token has already been used")
+	}
+	return account, nil
+}
+
+func passwordFingerprint(passwordHash string) string {
+	mac := hmac.New(sha256.New, purposeKey("password-fingerprint"))
+	mac.Write([]byte(passwordHash))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
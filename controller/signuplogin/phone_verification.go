@@ -0,0 +1,300 @@
+package signuplogin
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fixify_backend/sms"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// sendPhoneCode texts a freshly issued code
+func sendPhoneCode(db *gorm.DB, phone string, purpose string) (CodeStatus, error) {
+	code, status, err := IssuePhoneCode(db, phone, purpose)
+	if err != nil {
+		return status, err
+	}
+
+	body := fmt.Sprintf("Your Fixify verification code is %s. It expires in %d minutes. Never share this code with anyone.",
+		code, int(VerificationCodeTTL/time.Minute))
+	return CodeStatus{}, sms.Send(phone, body)
+}
+
+// findUserByPhone returns the account that uses a normalized number, in whatever form it was stored
+func findUserByPhone(db *gorm.DB, phone string) (users.User, bool, error) {
+	var user users.User
+	err := db.Where("phone IN ?", sms.PhoneVariants(phone)).First(&user).Error
+	if err == gorm.ErrRecordNotFound {
+		return user, false, nil
+	}
+	return user, err == nil, err
+}
+
+// SendPhoneVerification texts a code to the logged-in user's phone, or to a new number given in
+// the body (which replaces the old one once verified)
+func SendPhoneVerification(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var body struct {
+		Phone string `json:"phone"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Failed to parse request",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	var user users.User
+	if err := db.Select("user_id, phone").First(&user, claims.UserId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "User not found",
+			Data: errors.ErrorModel{
+				Message:   "Account not found",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	if body.Phone == "" {
+		body.Phone = user.Phone
+	}
+
+	phone, err := sms.NormalizePhone(body.Phone)
+	if err != nil {
+		return invalidPhone(c, err)
+	}
+	if taken, err := PhoneTaken(db, phone, user.UserId); taken || err != nil {
+		return phoneInUse(c, err)
+	}
+
+	if status, err := sendPhoneCode(db, phone, VerificationPurposePhoneVerify); err != nil {
+		return verificationCodeError(c, status, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "A verification code has been sent to your phone.",
+		Data: fiber.Map{
+			"phone":      phone,
+			"expires_in": int(VerificationCodeTTL.Seconds()),
+		},
+	})
+}
+
+// VerifyPhoneCode marks the number as verified and saves it on the account in E.164 form
+func VerifyPhoneCode(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var body struct {
+		Phone string `json:"phone"`
+		Code  string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Phone == "" || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Phone and code are required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	phone, err := sms.NormalizePhone(body.Phone)
+	if err != nil {
+		return invalidPhone(c, err)
+	}
+	if taken, err := PhoneTaken(db, phone, claims.UserId); taken || err != nil {
+		return phoneInUse(c, err)
+	}
+
+	if status, err := CheckPhoneCode(db, phone, VerificationPurposePhoneVerify, body.Code); err != nil {
+		return verificationCodeError(c, status, err)
+	}
+
+	now := time.Now()
+	if err := db.Model(&users.User{}).Where("user_id = ?", claims.UserId).Updates(map[string]interface{}{
+		"phone":             phone,
+		"phone_verified_at": now,
+	}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to verify phone",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Phone number verified!",
+		Data: fiber.Map{
+			"phone":             phone,
+			"phone_verified_at": now,
+		},
+	})
+}
+
+// PhoneLoginSend texts a login code to a verified phone number. The response is the same whether
+// or not the number belongs to an account, and cooldowns are applied silently.
+func PhoneLoginSend(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	var body struct {
+		Phone string `json:"phone"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Phone == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Phone is required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	phone, err := sms.NormalizePhone(body.Phone)
+	if err != nil {
+		return invalidPhone(c, err)
+	}
+
+	guard := newLoginGuard(db, users.RoleUser, phone, c.IP())
+	if retryAfter := guard.lockedFor(); retryAfter > 0 {
+		return loginLocked(c, retryAfter)
+	}
+
+	user, found, err := findUserByPhone(db, phone)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Internal Server Error!",
+			Data: errors.ErrorModel{
+				Message:   "An error occurred while processing your request",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	if found && user.PhoneVerifiedAt != nil {
+		if _, err := sendPhoneCode(db, phone, VerificationPurposePhoneLogin); err != nil && err != ErrCodeCooldown && err != ErrCodeLocked {
+			log.Printf("Phone login code for user %d failed: %v", user.UserId, err)
+		}
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "If this number belongs to a verified account, a login code has been sent.",
+		Data: fiber.Map{
+			"expires_in": int(VerificationCodeTTL.Seconds()),
+		},
+	})
+}
+
+// PhoneLogin logs a user in with their verified phone number and the code texted to it
+func PhoneLogin(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	var body struct {
+		Phone string `json:"phone"`
+		Code  string `json:"code"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Phone == "" || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Phone and code are required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	phone, err := sms.NormalizePhone(body.Phone)
+	if err != nil {
+		return invalidPhone(c, err)
+	}
+
+	guard := newLoginGuard(db, users.RoleUser, phone, c.IP())
+	if retryAfter := guard.lockedFor(); retryAfter > 0 {
+		return loginLocked(c, retryAfter)
+	}
+
+	user, found, err := findUserByPhone(db, phone)
+	if err != nil || !found || user.PhoneVerifiedAt == nil {
+		guard.failed()
+		return verificationCodeError(c, CodeStatus{}, ErrCodeInvalid)
+	}
+
+	if status, err := CheckPhoneCode(db, phone, VerificationPurposePhoneLogin, body.Code); err != nil {
+		if err == ErrCodeInvalid {
+			guard.failed()
+		}
+		return verificationCodeError(c, status, err)
+	}
+
+	guard.succeeded()
+
+	return userLoginSuccess(c, user)
+}
+
+// PhoneTaken reports whether an account other than userId already uses the number
+func PhoneTaken(db *gorm.DB, phone string, userId uint) (bool, error) {
+	owner, found, err := findUserByPhone(db, phone)
+	if err != nil {
+		return false, err
+	}
+	return found && owner.UserId != userId, nil
+}
+
+func phoneInUse(c *fiber.Ctx, err error) error {
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Internal Server Error!",
+			Data: errors.ErrorModel{
+				Message:   "An error occurred while processing your request",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+		RetCode: "409",
+		Message: "Phone number already in use!",
+		Data: errors.ErrorModel{
+			Message:   "Phone number is already registered to another account",
+			IsSuccess: false,
+		},
+	})
+}
+
+func invalidPhone(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+		RetCode: "400",
+		Message: "Invalid phone number",
+		Data: errors.ErrorModel{
+			Message:   "Enter a mobile number such as 09171234567 or +639171234567",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
@@ -1,6 +1,8 @@
 package signuplogin
 
 import (
+	"fixify_backend/middleware"
+	"fixify_backend/model/response"
 	"fixify_backend/model/users"
 	"fmt"
 	"os"
@@ -9,18 +11,21 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 // Secret key to sign the token
 var secretKey = []byte(os.Getenv("JWT_SECRET_KEY"))
 
-// GenerateJWT generates a new JWT token for a given username
-func GenerateJWT(id int) (string, error) {
+// GenerateJWT generates a new JWT token for a given account ID and role (users.RoleUser or users.RoleAdmin)
+func GenerateJWT(id int, role string) (string, error) {
 	// Create the claims
 	claims := users.Claims{
 		UserId: uint(id),
+		Role:   role,
 		StandardClaims: jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(24 * time.Hour).Unix(), // Token expires in 24 hours
+			IssuedAt:  time.Now().Unix(),                     // Compared with SessionsValidAfter
 			Issuer:    "Fixkify",                             // Issuer of the token
 		},
 	}
@@ -69,7 +74,7 @@ func JWTLogin(c *fiber.Ctx) error {
 	}
 
 	// Generate JWT Token
-	token, err := GenerateJWT(int(logac.UserId))
+	token, err := GenerateJWT(int(logac.UserId), users.RoleUser)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).SendString("Error generating token")
 	}
@@ -116,15 +121,19 @@ func JWTMiddleware(c *fiber.Ctx) error {
 	// Remove the "Bearer " prefix from the token if it's there
 	tokenString = strings.Replace(tokenString, "Bearer ", "", 1)
 
-	// Parse and validate the token
+	// Parse and validate the token, including sessions revoked by a password reset
 	claims := &users.Claims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		// Ensure the signing method is correct
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return secretKey, nil
-	})
+	token, err := ParseJWTClaims(tokenString, claims)
+
+	// Suspended and banned accounts are told why instead of getting a generic error
+	if restricted, ok := err.(*AccountRestrictedError); ok {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message":         restricted.Error(),
+			"account_status":  restricted.Status,
+			"suspended_until": restricted.Until,
+			"reason":          restricted.Reason,
+		})
+	}
 
 	// Check for any errors
 	if err != nil || !token.Valid {
@@ -139,12 +148,128 @@ func JWTMiddleware(c *fiber.Ctx) error {
 	return c.Next() // Continue processing the request
 }
 
-// ParseJWTClaims extracts and validates claims from a JWT token string
+// AdminOnly must run after JWTMiddleware and rejects tokens that were not issued to an admin
+func AdminOnly(c *fiber.Ctx) error {
+	claims, ok := c.Locals("user").(*users.Claims)
+	if !ok || !claims.IsAdmin() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Admin access required",
+		})
+	}
+
+	return c.Next()
+}
+
+// UserOnly must run after JWTMiddleware and rejects admin tokens. Admin and user IDs overlap, so
+// routes acting on the caller's own client or repairman account need it.
+func UserOnly(c *fiber.Ctx) error {
+	claims, ok := c.Locals("user").(*users.Claims)
+	if !ok || claims.IsAdmin() {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Client or repairman token required",
+		})
+	}
+
+	return c.Next()
+}
+
+// ParseJWTClaims extracts and validates claims from a JWT token string. Tokens issued before the
+// account's sessions were revoked (see RevokeSessions) are rejected, and so are tokens of banned
+// or suspended users, with an *AccountRestrictedError.
 func ParseJWTClaims(tokenString string, claims *users.Claims) (*jwt.Token, error) {
-	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return secretKey, nil
 	})
+	if err != nil {
+		return token, err
+	}
+
+	// Tokens from before roles existed carry neither a role nor an issue time. Admin and user IDs
+	// overlap, so there is no telling whose account such a token is for.
+	if claims.Role != users.RoleUser && claims.Role != users.RoleAdmin {
+		return token, fmt.Errorf("token has no valid role")
+	}
+	if claims.IssuedAt == 0 {
+		return token, fmt.Errorf("token has no issue time")
+	}
+
+	if err := checkSessionValid(claims); err != nil {
+		return token, err
+	}
+	return token, nil
+}
+
+// checkSessionValid rejects tokens issued before the account's sessions_valid_after, and tokens
+// of users who are banned or suspended
+func checkSessionValid(claims *users.Claims) error {
+	db := middleware.DBConn
+
+	var account struct {
+		SessionsValidAfter *time.Time
+		AccountStatus      string
+		SuspendedUntil     *time.Time
+		StatusReason       string
+	}
+	query := db.Model(&users.User{}).Where("user_id = ?", claims.UserId).
+		Select("sessions_valid_after, account_status, suspended_until, status_reason")
+	if claims.IsAdmin() {
+		query = db.Model(&users.Admin{}).Where("admin_id = ?", claims.UserId).Select("sessions_valid_after")
+	}
+	if err := query.Take(&account).Error; err != nil {
+		return fmt.Errorf("account not found")
+	}
+
+	if account.SessionsValidAfter != nil && claims.IssuedAt < account.SessionsValidAfter.Unix() {
+		return fmt.Errorf("session has been revoked")
+	}
+	if users.AccountRestricted(account.AccountStatus, account.SuspendedUntil, time.Now()) {
+		return &AccountRestrictedError{Status: account.AccountStatus, Until: account.SuspendedUntil, Reason: account.StatusReason}
+	}
+	return nil
+}
+
+// AccountRestrictedError is returned for a banned or currently suspended user
+type AccountRestrictedError struct {
+	Status string     // users.AccountStatusSuspended, Banned or Deleted
+	Until  *time.Time // End of a suspension
+	Reason string
+}
+
+func (e *AccountRestrictedError) Error() string {
+	if e.Status == users.AccountStatusBanned {
+		return "This account has been banned"
+	}
+	if e.Status == users.AccountStatusDeleted {
+		return "This account has been deleted"
+	}
+	if e.Until != nil {
+		return "This account is suspended until " + e.Until.Format("January 2, 2006 3:04 PM")
+	}
+	return "This account is suspended"
+}
+
+// accountRestrictedResponse refuses a login to a banned or suspended user
+func accountRestrictedResponse(c *fiber.Ctx, user users.User) error {
+	restricted := &AccountRestrictedError{Status: user.AccountStatus, Until: user.SuspendedUntil, Reason: user.StatusReason}
+	return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+		RetCode: "403",
+		Message: restricted.Error(),
+		Data: fiber.Map{
+			"account_status":  user.AccountStatus,
+			"suspended_until": user.SuspendedUntil,
+			"reason":          user.StatusReason,
+		},
+	})
+}
+
+// RevokeSessions invalidates every token issued to the account so far. Used after a password reset.
+func RevokeSessions(db *gorm.DB, role string, id uint) error {
+	now := time.Now().Truncate(time.Second)
+	if role == users.RoleAdmin {
+		return db.Model(&users.Admin{}).Where("admin_id = ?", id).Update("sessions_valid_after", now).Error
+	}
+	return db.Model(&users.User{}).Where("user_id = ?", id).Update("sessions_valid_after", now).Error
 }
@@ -0,0 +1,32 @@
+package signuplogin
+
+import (
+	"testing"
+	"time"
+
+	"fixify_backend/model/users"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestParseJWTClaimsRejectsTokensWithoutRoleOrIssueTime(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Unix()
+	tests := []struct {
+		name   string
+		claims users.Claims
+	}{
+		{"issued before roles", users.Claims{UserId: 7, StandardClaims: jwt.StandardClaims{ExpiresAt: expires}}},
+		{"unknown role", users.Claims{UserId: 7, Role: "owner", StandardClaims: jwt.StandardClaims{ExpiresAt: expires, IssuedAt: time.Now().Unix()}}},
+		{"no issue time", users.Claims{UserId: 7, Role: users.RoleUser, StandardClaims: jwt.StandardClaims{ExpiresAt: expires}}},
+	}
+	for _, tt := range tests {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString(secretKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Rejected before the account is looked up, so no database is needed
+		if _, err := ParseJWTClaims(signed, &users.Claims{}); err == nil {
+			t.Errorf("%s: token accepted", tt.name)
+		}
+	}
+}
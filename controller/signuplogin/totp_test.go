@@ -0,0 +1,121 @@
+package signuplogin
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// Secret "12345678901234567890" from the RFC 6238 test vectors, base32 encoded
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; ours are the last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := totpCode(rfcSecret, totpStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("totpCode(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("totpCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPCodeAcceptsLowercaseSecret(t *testing.T) {
+	upper, _ := totpCode(rfcSecret, 1)
+	lower, err := totpCode(strings.ToLower(rfcSecret), 1)
+	if err != nil || lower != upper {
+		t.Fatalf("lowercase secret gave %q, %v; want %q", lower, err, upper)
+	}
+	if _, err := totpCode("not base32!", 1); err == nil {
+		t.Fatal("expected an error for an invalid secret")
+	}
+}
+
+func TestMatchTOTPAllowsOneStepOfDrift(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := totpStep(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, _ := totpCode(rfcSecret, current+offset)
+		step, ok := matchTOTP(rfcSecret, code, 0, now)
+		if !ok || step != current+offset {
+			t.Errorf("offset %d: got step %d, ok %v; want step %d", offset, step, ok, current+offset)
+		}
+	}
+	for _, offset := range []int64{-2, 2} {
+		code, _ := totpCode(rfcSecret, current+offset)
+		if _, ok := matchTOTP(rfcSecret, code, 0, now); ok {
+			t.Errorf("offset %d: code outside the drift window was accepted", offset)
+		}
+	}
+}
+
+func TestMatchTOTPRefusesReplayedSteps(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := totpStep(now)
+	code, _ := totpCode(rfcSecret, current)
+
+	if _, ok := matchTOTP(rfcSecret, code, current, now); ok {
+		t.Fatal("a code for an already used step was accepted")
+	}
+	next, _ := totpCode(rfcSecret, current+1)
+	if step, ok := matchTOTP(rfcSecret, next, current, now); !ok || step != current+1 {
+		t.Fatalf("the next step's code was refused: step %d, ok %v", step, ok)
+	}
+}
+
+func TestMatchTOTPNormalizesInput(t *testing.T) {
+	now := time.Unix(59, 0)
+	if _, ok := matchTOTP(rfcSecret, " 287 082 ", 0, now); !ok {
+		t.Error("code with spaces was refused")
+	}
+	for _, code := range []string{"", "28708", "2870820", "abcdef"} {
+		if _, ok := matchTOTP(rfcSecret, code, 0, now); ok {
+			t.Errorf("malformed code %q was accepted", code)
+		}
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := generateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+	}
+
+	// Typed back in upper case, without the dash or with spaces, a code still matches
+	code := codes[0]
+	want := hashRecoveryCode(1, code)
+	for _, typed := range []string{strings.ToUpper(code), code[:5] + code[6:], " " + code + " ", code[:5] + " " + code[6:]} {
+		if got := hashRecoveryCode(1, typed); got != want {
+			t.Errorf("hash of %q differs from the hash of %q", typed, code)
+		}
+	}
+	if hashRecoveryCode(2, code) == want {
+		t.Error("recovery code hashes must differ between admins")
+	}
+}
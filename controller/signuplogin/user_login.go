@@ -5,6 +5,7 @@ import (
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt" // Import bcrypt for password hashing comparison
@@ -29,20 +30,20 @@ func UserLogin(c *fiber.Ctx) error {
 		})
 	}
 
+	// Refuse early while the account or this IP is locked out
+	guard := newLoginGuard(db, users.RoleUser, logac.Email, c.IP())
+	if retryAfter := guard.lockedFor(); retryAfter > 0 {
+		return loginLocked(c, retryAfter)
+	}
+
 	// Retrieve the User record from the database based on email
 	var user users.User
 	if err := db.Where("email = ?", logac.Email).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			// If no matching record is found, return a login failed response
-			return c.JSON(response.ResponseModel{
-				RetCode: "401",
-				Message: "Login Failed!",
-				Data: errors.ErrorModel{
-					Message:   "Invalid email/username or password",
-					IsSuccess: false,
-					Error:     err.Error(),
-				},
-			})
+			// Spend the same time as a wrong password and answer the same way
+			bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(logac.Password))
+			guard.failed()
+			return loginFailed(c)
 		}
 		// Handle any other database errors
 		return c.JSON(response.ResponseModel{
@@ -60,20 +61,24 @@ func UserLogin(c *fiber.Ctx) error {
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(logac.Password))
 	if err != nil {
 		// If the password doesn't match, return a login failed response
-		return c.JSON(response.ResponseModel{
-			RetCode: "401",
-			Message: "Login Failed!",
-			Data: errors.ErrorModel{
-				Message:   "Invalid email/username or password",
-				IsSuccess: false,
-				Error:     "Password does not match",
-			},
-		})
+		guard.failed()
+		return loginFailed(c)
+	}
+
+	guard.succeeded()
+
+	return userLoginSuccess(c, user)
+}
+
+// userLoginSuccess generates the JWT and returns it with the user, for every way of logging in.
+// Banned and suspended users are refused here, after their credentials were checked.
+func userLoginSuccess(c *fiber.Ctx, user users.User) error {
+	if users.AccountRestricted(user.AccountStatus, user.SuspendedUntil, time.Now()) {
+		return accountRestrictedResponse(c, user)
 	}
 
-	// If credentials are valid, generate the JWT token
 	token, err := GenerateJWT(
-		int(user.UserId))
+		int(user.UserId), users.RoleUser)
 	if err != nil {
 		// Handle token generation error
 		return c.JSON(response.ResponseModel{
@@ -5,8 +5,10 @@ import (
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 func UserSignup(c *fiber.Ctx) error {
@@ -41,10 +43,21 @@ func UserSignup(c *fiber.Ctx) error {
 	}
 	// Store the hashed password
 	logac.Password = hashedPassword
+	// The app verifies the address with a code before signing up; resets go to it from now on
+	logac.VerifiedEmail = strings.ToLower(strings.TrimSpace(logac.Email))
 
-	// Check if the email is already in use
-	var existingUserEmail users.User
-	if err := db.Where("email = ?", logac.Email).First(&existingUserEmail).Error; err == nil {
+	// Check if the email is already in use, as a login or as another account's reset address
+	if taken, err := EmailTaken(db, logac.VerifiedEmail, 0); err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Cannot sign up!",
+			Data: errors.ErrorModel{
+				Message:   "Failed to sign up!",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	} else if taken {
 		// If an user with the same email exists, return an error
 		return c.JSON(response.ResponseModel{
 			RetCode: "400",
@@ -122,10 +135,23 @@ func RepairmanSignup(c *fiber.Ctx) error {
 	}
 	// Store the hashed password
 	logac.Password = hashedPassword
+	// Verification status is only ever set by an admin reviewing the repairman's ID
+	logac.Verification_status = ""
+	// The app verifies the address with a code before signing up; resets go to it from now on
+	logac.VerifiedEmail = strings.ToLower(strings.TrimSpace(logac.Email))
 
-	// Check if the email is already in use
-	var existingUserEmail users.EmailVer
-	if err := db.Where("email = ?", existingUserEmail.Email).First(&existingUserEmail).Error; err == nil {
+	// Check if the email is already in use, as a login or as another account's reset address
+	if taken, err := EmailTaken(db, logac.VerifiedEmail, 0); err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Cannot sign up!",
+			Data: errors.ErrorModel{
+				Message:   "Failed to sign up!",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	} else if taken {
 		// If an user with the same email exists, return an error
 		return c.JSON(response.ResponseModel{
 			RetCode: "400",
@@ -170,3 +196,14 @@ func RepairmanSignup(c *fiber.Ctx) error {
 		Data:    logac,
 	})
 }
+
+// EmailTaken reports whether an account other than userId uses the address, either to log in or
+// as the verified address its password resets go to
+func EmailTaken(db *gorm.DB, email string, userId uint) (bool, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	var count int64
+	err := db.Model(&users.User{}).
+		Where("(LOWER(email) = ? OR (verified_email <> '' AND LOWER(verified_email) = ?)) AND user_id <> ?", email, email, userId).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,270 @@
+package signuplogin
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fixify_backend/middleware"
+	"fmt"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Verification code purposes. A code issued for one purpose cannot be used for another.
+const (
+	VerificationPurposeSignup        = "signup"
+	VerificationPurposePasswordReset = "password_reset"
+	VerificationPurposePhoneVerify   = "phone_verify"
+	VerificationPurposePhoneLogin    = "phone_login"
+)
+
+const (
+	VerificationCodeTTL    = 10 * time.Minute
+	maxCodeAttempts        = 5
+	codeLockoutDuration    = 15 * time.Minute
+	verificationCodeResend = 60 * time.Second
+)
+
+var (
+	ErrCodeInvalid  = errors.New("invalid verification code")
+	ErrCodeExpired  = errors.New("verification code has expired")
+	ErrCodeLocked   = errors.New("too many failed attempts")
+	ErrCodeCooldown = errors.New("a code was sent recently")
+)
+
+// CodeStatus describes why a code was rejected: how long until the caller may retry (cooldown or
+// lockout) and how many guesses are left before the lockout.
+type CodeStatus struct {
+	RetryAfter   time.Duration
+	AttemptsLeft int
+}
+
+// codeTarget is the table and column a code is bound to (emailver.email or phonever.phone), so
+// the issue/check rules live in one place.
+type codeTarget struct {
+	table  string
+	column string
+}
+
+var (
+	emailCodeTarget = codeTarget{table: "emailver", column: "email"}
+	phoneCodeTarget = codeTarget{table: "phonever", column: "phone"}
+)
+
+type verificationRow struct {
+	CodeHash    string
+	ExpiresAt   *time.Time
+	Attempts    int
+	LastSentAt  *time.Time
+	LockedUntil *time.Time
+}
+
+// IssueEmailCode creates a fresh code for an email address and returns it for sending
+func IssueEmailCode(db *gorm.DB, email string, purpose string) (string, CodeStatus, error) {
+	return issueVerificationCode(db, emailCodeTarget, email, purpose)
+}
+
+// CheckEmailCode verifies a code sent to an email address. A correct code is consumed.
+func CheckEmailCode(db *gorm.DB, email string, purpose string, code string) (CodeStatus, error) {
+	return checkVerificationCode(db, emailCodeTarget, email, purpose, code)
+}
+
+// IssuePhoneCode creates a fresh code for a normalized phone number and returns it for sending
+func IssuePhoneCode(db *gorm.DB, phone string, purpose string) (string, CodeStatus, error) {
+	return issueVerificationCode(db, phoneCodeTarget, phone, purpose)
+}
+
+// CheckPhoneCode verifies a code sent by SMS. A correct code is consumed.
+func CheckPhoneCode(db *gorm.DB, phone string, purpose string, code string) (CodeStatus, error) {
+	return checkVerificationCode(db, phoneCodeTarget, phone, purpose, code)
+}
+
+func issueVerificationCode(db *gorm.DB, t codeTarget, value string, purpose string) (string, CodeStatus, error) {
+	now := time.Now()
+
+	row, found, err := loadVerificationRow(db, t, value, purpose)
+	if err != nil {
+		return "", CodeStatus{}, err
+	}
+	if found {
+		if row.LockedUntil != nil && row.LockedUntil.After(now) {
+			return "", CodeStatus{RetryAfter: row.LockedUntil.Sub(now)}, ErrCodeLocked
+		}
+		if row.LastSentAt != nil && now.Sub(*row.LastSentAt) < verificationCodeResend {
+			return "", CodeStatus{RetryAfter: verificationCodeResend - now.Sub(*row.LastSentAt)}, ErrCodeCooldown
+		}
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return "", CodeStatus{}, err
+	}
+
+	values := map[string]interface{}{
+		"code_hash":    hashVerificationCode(t, value, purpose, code),
+		"expires_at":   now.Add(VerificationCodeTTL),
+		"last_sent_at": now,
+		"locked_until": nil,
+	}
+	// Failed attempts carry over to a resent code; they only reset once a lockout has run out
+	if !found || row.LockedUntil != nil {
+		values["attempts"] = 0
+	}
+
+	if found {
+		err = db.Table(t.table).Where(t.column+" = ? AND purpose = ?", value, purpose).Updates(values).Error
+	} else {
+		values[t.column] = value
+		values["purpose"] = purpose
+		values["attempts"] = 0
+		values["createdat"] = now
+		err = db.Table(t.table).Create(values).Error
+	}
+	if err != nil {
+		return "", CodeStatus{}, err
+	}
+
+	return code, CodeStatus{}, nil
+}
+
+func checkVerificationCode(db *gorm.DB, t codeTarget, value string, purpose string, code string) (CodeStatus, error) {
+	store := sqlCodeStore{db: db, t: t}
+	return checkCode(store, value, purpose, hashVerificationCode(t, value, purpose, code), time.Now())
+}
+
+// codeStore is the storage a code check needs. Every write is a single statement, so parallel
+// checks of the same code can't share an attempt or both consume the code.
+type codeStore interface {
+	load(value string, purpose string) (verificationRow, bool, error)
+	// countAttempt adds one to the attempts of an unused code and returns the row as updated
+	countAttempt(value string, purpose string) (verificationRow, bool, error)
+	// burn locks the code out, if it still has the given hash
+	burn(value string, purpose string, codeHash string, lockedUntil time.Time) error
+	// consume deletes the code if it still has the given hash, reporting whether it did
+	consume(value string, purpose string, codeHash string) (bool, error)
+}
+
+func checkCode(store codeStore, value string, purpose string, expected string, now time.Time) (CodeStatus, error) {
+	row, found, err := store.load(value, purpose)
+	if err != nil {
+		return CodeStatus{}, err
+	}
+	if !found || row.CodeHash == "" {
+		return CodeStatus{}, ErrCodeInvalid
+	}
+	if row.LockedUntil != nil && row.LockedUntil.After(now) {
+		return CodeStatus{RetryAfter: row.LockedUntil.Sub(now)}, ErrCodeLocked
+	}
+	if row.ExpiresAt == nil || row.ExpiresAt.Before(now) {
+		return CodeStatus{}, ErrCodeExpired
+	}
+
+	// Every guess is counted before it is compared, so a burst of parallel guesses gets no more
+	// tries than sequential ones
+	row, found, err = store.countAttempt(value, purpose)
+	if err != nil {
+		return CodeStatus{}, err
+	}
+	if !found {
+		// Used or burned by a check running at the same time
+		return CodeStatus{}, ErrCodeInvalid
+	}
+	if row.Attempts > maxCodeAttempts {
+		return CodeStatus{RetryAfter: codeLockoutDuration}, ErrCodeLocked
+	}
+
+	if !hmac.Equal([]byte(expected), []byte(row.CodeHash)) {
+		if row.Attempts >= maxCodeAttempts {
+			// Burn the code; a new one can be requested after the lockout
+			if err := store.burn(value, purpose, row.CodeHash, now.Add(codeLockoutDuration)); err != nil {
+				return CodeStatus{}, err
+			}
+			return CodeStatus{RetryAfter: codeLockoutDuration}, ErrCodeLocked
+		}
+		return CodeStatus{AttemptsLeft: maxCodeAttempts - row.Attempts}, ErrCodeInvalid
+	}
+
+	// Codes are single use: only the check that deletes the code succeeds
+	consumed, err := store.consume(value, purpose, row.CodeHash)
+	if err != nil {
+		return CodeStatus{}, err
+	}
+	if !consumed {
+		return CodeStatus{}, ErrCodeInvalid
+	}
+	return CodeStatus{}, nil
+}
+
+// sqlCodeStore keeps codes in the emailver or phonever table
+type sqlCodeStore struct {
+	db *gorm.DB
+	t  codeTarget
+}
+
+func (s sqlCodeStore) load(value string, purpose string) (verificationRow, bool, error) {
+	return loadVerificationRow(s.db, s.t, value, purpose)
+}
+
+func (s sqlCodeStore) countAttempt(value string, purpose string) (verificationRow, bool, error) {
+	var row verificationRow
+	result := s.db.Raw("UPDATE "+s.t.table+" SET attempts = COALESCE(attempts, 0) + 1"+
+		" WHERE "+s.t.column+" = ? AND purpose = ? AND code_hash <> ''"+
+		" RETURNING code_hash, expires_at, attempts, last_sent_at, locked_until", value, purpose).Scan(&row)
+	if result.Error != nil {
+		return row, false, result.Error
+	}
+	return row, result.RowsAffected > 0, nil
+}
+
+func (s sqlCodeStore) burn(value string, purpose string, codeHash string, lockedUntil time.Time) error {
+	return s.db.Table(s.t.table).
+		Where(s.t.column+" = ? AND purpose = ? AND code_hash = ?", value, purpose, codeHash).
+		Updates(map[string]interface{}{"code_hash": "", "locked_until": lockedUntil}).Error
+}
+
+func (s sqlCodeStore) consume(value string, purpose string, codeHash string) (bool, error) {
+	result := s.db.Exec("DELETE FROM "+s.t.table+" WHERE "+s.t.column+" = ? AND purpose = ? AND code_hash = ?",
+		value, purpose, codeHash)
+	return result.RowsAffected == 1, result.Error
+}
+
+func loadVerificationRow(db *gorm.DB, t codeTarget, value string, purpose string) (verificationRow, bool, error) {
+	var row verificationRow
+	err := db.Table(t.table).
+		Select("code_hash, expires_at, attempts, last_sent_at, locked_until").
+		Where(t.column+" = ? AND purpose = ?", value, purpose).
+		Take(&row).Error
+	if err == gorm.ErrRecordNotFound {
+		return row, false, nil
+	}
+	return row, err == nil, err
+}
+
+// generateCode returns a uniformly random 6-digit code from crypto/rand
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashVerificationCode binds the code to its target and purpose so a leaked table row can't be
+// replayed for another address, and a 6-digit code can't be brute forced offline without the key.
+func hashVerificationCode(t codeTarget, value string, purpose string, code string) string {
+	mac := hmac.New(sha256.New, purposeKey("verification-code"))
+	mac.Write([]byte(t.table + "\x00" + value + "\x00" + purpose + "\x00" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// purposeKey derives a separate HMAC key per use from JWT_SECRET_KEY, so a value signed for one
+// purpose can never be accepted as another (or as a session token).
+func purposeKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(middleware.GetEnv("JWT_SECRET_KEY")))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
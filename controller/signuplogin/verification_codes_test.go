@@ -0,0 +1,142 @@
+package signuplogin
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryCodeStore holds one code and applies each operation atomically, like the SQL statements
+// in sqlCodeStore
+type memoryCodeStore struct {
+	mu    sync.Mutex
+	row   verificationRow
+	found bool
+}
+
+func newMemoryCodeStore(codeHash string, now time.Time) *memoryCodeStore {
+	expires := now.Add(VerificationCodeTTL)
+	return &memoryCodeStore{row: verificationRow{CodeHash: codeHash, ExpiresAt: &expires}, found: true}
+}
+
+func (s *memoryCodeStore) load(string, string) (verificationRow, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.row, s.found, nil
+}
+
+func (s *memoryCodeStore) countAttempt(string, string) (verificationRow, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.found || s.row.CodeHash == "" {
+		return verificationRow{}, false, nil
+	}
+	s.row.Attempts++
+	return s.row, true, nil
+}
+
+func (s *memoryCodeStore) burn(_ string, _ string, codeHash string, lockedUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.found && s.row.CodeHash == codeHash {
+		s.row.CodeHash = ""
+		s.row.LockedUntil = &lockedUntil
+	}
+	return nil
+}
+
+func (s *memoryCodeStore) consume(_ string, _ string, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.found || s.row.CodeHash != codeHash {
+		return false, nil
+	}
+	s.found = false
+	return true, nil
+}
+
+// checkInParallel runs one check per guess at the same time and returns the results
+func checkInParallel(store codeStore, guesses []string, now time.Time) ([]CodeStatus, []error) {
+	statuses := make([]CodeStatus, len(guesses))
+	errs := make([]error, len(guesses))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, guess := range guesses {
+		wg.Add(1)
+		go func(i int, guess string) {
+			defer wg.Done()
+			<-start
+			statuses[i], errs[i] = checkCode(store, "ana@example.com", VerificationPurposePasswordReset, guess, now)
+		}(i, guess)
+	}
+	close(start)
+	wg.Wait()
+	return statuses, errs
+}
+
+func TestCheckCodeLocksOutParallelGuesses(t *testing.T) {
+	now := time.Now()
+	store := newMemoryCodeStore("right", now)
+
+	guesses := make([]string, 50)
+	for i := range guesses {
+		guesses[i] = "wrong"
+	}
+	statuses, errs := checkInParallel(store, guesses, now)
+
+	compared := 0
+	for i, err := range errs {
+		if err == ErrCodeInvalid && statuses[i].AttemptsLeft > 0 {
+			compared++
+		} else if err != ErrCodeLocked && err != ErrCodeInvalid {
+			t.Fatalf("guess %d: unexpected error %v", i, err)
+		}
+	}
+	if compared != maxCodeAttempts-1 {
+		t.Errorf("%d guesses were told to try again, want %d", compared, maxCodeAttempts-1)
+	}
+
+	row, _, _ := store.load("", "")
+	if row.CodeHash != "" || row.LockedUntil == nil {
+		t.Fatalf("code was not burned after the lockout: %+v", row)
+	}
+	if _, err := checkCode(store, "ana@example.com", VerificationPurposePasswordReset, "right", now); err == nil {
+		t.Fatal("right code was accepted after the lockout")
+	}
+}
+
+func TestCheckCodeAcceptsParallelCorrectCodeOnce(t *testing.T) {
+	now := time.Now()
+	store := newMemoryCodeStore("right", now)
+
+	guesses := make([]string, 20)
+	for i := range guesses {
+		guesses[i] = "right"
+	}
+	_, errs := checkInParallel(store, guesses, now)
+
+	accepted := 0
+	for _, err := range errs {
+		if err == nil {
+			accepted++
+		}
+	}
+	if accepted != 1 {
+		t.Fatalf("code was accepted %d times, want once", accepted)
+	}
+}
+
+func TestCheckCodeAllowsRightCodeOnLastAttempt(t *testing.T) {
+	now := time.Now()
+	store := newMemoryCodeStore("right", now)
+
+	for i := 1; i < maxCodeAttempts; i++ {
+		status, err := checkCode(store, "ana@example.com", VerificationPurposePasswordReset, "wrong", now)
+		if err != ErrCodeInvalid || status.AttemptsLeft != maxCodeAttempts-i {
+			t.Fatalf("guess %d: status %+v, err %v", i, status, err)
+		}
+	}
+	if _, err := checkCode(store, "ana@example.com", VerificationPurposePasswordReset, "right", now); err != nil {
+		t.Fatalf("right code on the last attempt: %v", err)
+	}
+}
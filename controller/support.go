@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"fixify_backend/model/users"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxTicketMessageLength limits one support ticket message
+const MaxTicketMessageLength = 5000
+
+// AddTicketMessage appends a message to a ticket's thread and updates the ticket's counters. The
+// first reply from an admin stops the first-response SLA clock. Internal notes only add the
+// message. It should run in the same transaction as any status change.
+func AddTicketMessage(tx *gorm.DB, ticket *users.SupportTicket, senderId uint, senderRole string, body string, internal bool) (users.SupportTicketMessage, error) {
+	message := users.SupportTicketMessage{
+		TicketId:   ticket.TicketId,
+		SenderId:   senderId,
+		SenderRole: senderRole,
+		Body:       strings.TrimSpace(body),
+		Internal:   internal,
+	}
+	if err := tx.Create(&message).Error; err != nil {
+		return message, err
+	}
+	if internal {
+		return message, nil
+	}
+
+	updates := map[string]interface{}{
+		"message_count":    gorm.Expr("message_count + 1"),
+		"last_message_at":  message.CreatedAt,
+		"last_sender_role": senderRole,
+	}
+	if senderRole == users.RoleAdmin && ticket.FirstRespondedAt == nil {
+		now := time.Now()
+		updates["first_responded_at"] = now
+		ticket.FirstRespondedAt = &now
+	}
+	if err := tx.Model(&users.SupportTicket{}).Where("ticket_id = ?", ticket.TicketId).Updates(updates).Error; err != nil {
+		return message, err
+	}
+	ticket.MessageCount++
+	ticket.LastMessageAt = message.CreatedAt
+	ticket.LastSenderRole = senderRole
+	return message, nil
+}
@@ -22,6 +22,7 @@ func TopRepairmen(c *fiber.Ctx) error {
 		LastName       string  `json:"last_name"`
 		ProfilePicture string  `json:"profile_picture"`
 		ServiceName    string  `json:"service_name"`
+		IsVerified     bool    `json:"is_verified"`
 	}
 
 	var results []AvgRatingResult
@@ -33,12 +34,13 @@ func TopRepairmen(c *fiber.Ctx) error {
 			AVG(reviews.rating) AS avg_rating,
 			users.first_name,
 			users.last_name,
-			service_categories.category_name AS service_name
-		`).
+			service_categories.category_name AS service_name,
+		`+VerifiedSelect).
 		Joins("JOIN users ON users.user_id = reviews.repairman_id").
 		Joins("JOIN service_categories ON service_categories.category_id = users.category_id").
 		Where("users.type = ?", "Repairman").
-		Group("reviews.repairman_id, users.first_name, users.last_name, service_categories.category_name").
+		Scopes(ScopeBookableRepairmen).
+		Group("reviews.repairman_id, users.user_id, users.first_name, users.last_name, service_categories.category_name").
 		Order("avg_rating DESC").
 		Scan(&results).Error
 
@@ -0,0 +1,274 @@
+package userfeatures
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"fixify_backend/controller"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// accountDataExport is everything stored about a user, as returned by ExportAccountData
+type accountDataExport struct {
+	GeneratedAt             time.Time                           `json:"generated_at"`
+	Profile                 users.User                          `json:"profile"`
+	ServiceRequests         []users.ServiceRequest              `json:"service_requests"`
+	ReviewsWritten          []users.Review                      `json:"reviews_written"`
+	ReviewsReceived         []users.Review                      `json:"reviews_received"`
+	Conversations           []users.ClientRepairmanConversation `json:"conversations"`
+	Messages                []users.ClientRepairmanMessage      `json:"messages"`
+	ChatAttachments         []users.ChatAttachment              `json:"chat_attachments"`
+	Payments                []users.GCashPayment                `json:"payments"`
+	GcashAccounts           []users.Gcash                       `json:"gcash_accounts"`
+	IDVerifications         []users.UserVerification            `json:"id_verifications"`
+	Notifications           []users.UserNotification            `json:"notifications"`
+	NotificationPreferences []users.NotificationPreference      `json:"notification_preferences"`
+	Devices                 []users.DeviceToken                 `json:"devices"`
+	BlockedUsers            []users.UserBlock                   `json:"blocked_users"`
+	SupportTickets          []users.SupportTicket               `json:"support_tickets"`
+}
+
+// ExportAccountData lets a user download their personal data. ?format=zip (the default) returns
+// an archive with data.json plus the profile picture, ID documents and chat attachments they
+// uploaded; ?format=json returns data.json only.
+func ExportAccountData(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+	if claims.IsAdmin() {
+		return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "Not available for admin accounts",
+			Data: errors.ErrorModel{
+				Message:   "Only clients and repairmen can export their data here",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	format := c.Query("format", "zip")
+	if format != "zip" && format != "json" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid format",
+			Data: errors.ErrorModel{
+				Message:   "format must be zip or json",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	export, err := collectAccountData(db, claims.UserId)
+	if err != nil {
+		return exportFailed(c, err)
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAccountDataExport,
+		TargetType: users.RoleUser,
+		TargetId:   claims.UserId,
+		After:      map[string]interface{}{"format": format},
+	})
+
+	fileName := fmt.Sprintf("fixify-data-%d-%s", claims.UserId, export.GeneratedAt.Format("20060102"))
+	if format == "json" {
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+fileName+`.json"`)
+		return c.JSON(export)
+	}
+
+	// The ID documents are logged as viewed up front. The archive is then streamed, so an account
+	// with many attachments is never held in memory.
+	documents, err := recordExportedDocuments(c, db, export)
+	if err != nil {
+		return exportFailed(c, err)
+	}
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+fileName+`.zip"`)
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := writeAccountArchive(w, db, export, documents); err != nil {
+			// The status has been sent already; the client gets a truncated archive
+			log.Printf("Account export for user %d failed: %v", export.Profile.UserId, err)
+		}
+	})
+	return nil
+}
+
+// collectAccountData loads the user's records. Binary data (profile picture, ID images,
+// attachment files) is left out; writeAccountArchive adds it as separate files.
+func collectAccountData(db *gorm.DB, userId uint) (accountDataExport, error) {
+	export := accountDataExport{GeneratedAt: time.Now()}
+
+	if err := db.Omit("profile_picture").First(&export.Profile, "user_id = ?", userId).Error; err != nil {
+		return export, err
+	}
+	export.Profile.Password = ""
+
+	counterparty := func(db *gorm.DB) *gorm.DB {
+		return db.Select("user_id, first_name, last_name, type")
+	}
+
+	queries := []*gorm.DB{
+		db.Preload("User", counterparty).Preload("Repairman", counterparty).Preload("ServiceCategory").
+			Where("user_id = ? OR fixer_id = ?", userId, userId).Order("request_id").Find(&export.ServiceRequests),
+		db.Preload("Repairman", counterparty).Where("client_id = ?", userId).Order("review_id").Find(&export.ReviewsWritten),
+		db.Preload("Client", counterparty).Where("repairman_id = ?", userId).Order("review_id").Find(&export.ReviewsReceived),
+		db.Preload("Client", counterparty).Preload("Repairman", counterparty).
+			Where("client_id = ? OR repairman_id = ?", userId, userId).Order("conversation_id").Find(&export.Conversations),
+		db.Where("conversation_id IN (?)", db.Model(&users.ClientRepairmanConversation{}).
+			Select("conversation_id").Where("client_id = ? OR repairman_id = ?", userId, userId)).
+			Order("message_id").Find(&export.Messages),
+		db.Omit("data").Where("uploader_id = ?", userId).Order("attachment_id").Find(&export.ChatAttachments),
+		db.Where("payment_from = ? OR payment_to = ?", userId, userId).Order("payment_id").Find(&export.Payments),
+		db.Where("user_id = ?", userId).Find(&export.GcashAccounts),
+		db.Omit("valid_id", "selfie", "back_id").Where("user_id = ?", userId).Order("verification_id").Find(&export.IDVerifications),
+		db.Where("to_user = ?", userId).Order("notification_id").Find(&export.Notifications),
+		db.Where("user_id = ?", userId).Find(&export.NotificationPreferences),
+		db.Where("owner_type = ? AND owner_id = ?", users.RoleUser, userId).Find(&export.Devices),
+		db.Preload("Blocked", counterparty).Where("blocker_id = ?", userId).Find(&export.BlockedUsers),
+		db.Preload("Messages", func(db *gorm.DB) *gorm.DB {
+			return db.Where("internal = ?", false).Order("message_id")
+		}).Where("user_id = ?", userId).Order("ticket_id").Find(&export.SupportTickets),
+	}
+	for _, query := range queries {
+		if query.Error != nil {
+			return export, query.Error
+		}
+	}
+	return export, nil
+}
+
+// recordExportedDocuments writes a document access log entry for every submission whose ID images
+// go into the archive, and returns their IDs
+func recordExportedDocuments(c *fiber.Ctx, db *gorm.DB, export accountDataExport) ([]uint, error) {
+	claims := c.Locals("user").(*users.Claims)
+
+	// Wiped submissions have no images left to export
+	var ids []uint
+	if err := db.Model(&users.UserVerification{}).
+		Where("user_id = ?", export.Profile.UserId).
+		Where("COALESCE(octet_length(valid_id), 0) + COALESCE(octet_length(selfie), 0) + COALESCE(octet_length(back_id), 0) > 0").
+		Order("verification_id").
+		Pluck("verification_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	exported := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		exported[id] = true
+	}
+	for _, summary := range export.IDVerifications {
+		if !exported[summary.VerificationId] {
+			continue
+		}
+		if err := controller.RecordDocumentAccess(c, db, summary, claims); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// writeAccountArchive zips data.json with the user's uploaded files into w. Files are loaded one
+// at a time, so memory use doesn't grow with the size of the account.
+func writeAccountArchive(w io.Writer, db *gorm.DB, export accountDataExport, documents []uint) error {
+	archive := zip.NewWriter(w)
+	addFile := func(name string, data []byte) error {
+		file, err := archive.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(data)
+		return err
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := addFile("data.json", data); err != nil {
+		return err
+	}
+
+	var picture users.User
+	if err := db.Select("user_id, profile_picture").First(&picture, "user_id = ?", export.Profile.UserId).Error; err != nil {
+		return err
+	}
+	if len(picture.Profile_picture) > 0 {
+		if err := addFile("profile_picture"+exportExtension(picture.Profile_picture), picture.Profile_picture); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range documents {
+		var verification users.UserVerification
+		if err := db.First(&verification, "verification_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := controller.DecryptVerificationDocuments(&verification); err != nil {
+			return err
+		}
+		images := map[string][]byte{"front": verification.ValidId, "back": verification.BackId, "selfie": verification.Selfie}
+		for side, image := range images {
+			if len(image) == 0 {
+				continue
+			}
+			name := fmt.Sprintf("id_documents/%d_%s%s", verification.VerificationId, side, exportExtension(image))
+			if err := addFile(name, image); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, summary := range export.ChatAttachments {
+		var attachment users.ChatAttachment
+		if err := db.First(&attachment, "attachment_id = ?", summary.AttachmentId).Error; err != nil {
+			return err
+		}
+		base := filepath.Base(attachment.FileName)
+		if base == "." || base == "/" {
+			base = "file" + exportExtension(attachment.Data)
+		}
+		name := fmt.Sprintf("chat_attachments/%d_%s", attachment.AttachmentId, base)
+		if err := addFile(name, attachment.Data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
+
+func exportExtension(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/webp":
+		return ".webp"
+	case "application/pdf":
+		return ".pdf"
+	}
+	return ".bin"
+}
+
+func exportFailed(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Failed to export data",
+		Data: errors.ErrorModel{
+			Message:   "Could not collect your data",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
@@ -0,0 +1,138 @@
+package userfeatures
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	maxChatImageSize = 5 * 1024 * 1024  // 5 MB
+	maxChatFileSize  = 10 * 1024 * 1024 // 10 MB
+)
+
+// Allowed attachment content types and the message kind each one is sent as
+var chatAttachmentTypes = map[string]string{
+	"image/jpeg":      users.MessageTypeImage,
+	"image/png":       users.MessageTypeImage,
+	"image/webp":      users.MessageTypeImage,
+	"application/pdf": users.MessageTypeFile,
+}
+
+// UploadChatAttachment stores a photo or PDF for a chat message. The returned attachment_id
+// is then sent over the websocket with message_type "image" or "file".
+func UploadChatAttachment(c *fiber.Ctx) error {
+	db := middleware.DBConn
+
+	claims, ok := c.Locals("user").(*users.Claims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Unauthorized",
+			Data: errors.ErrorModel{
+				Message:   "User not authenticated",
+				IsSuccess: false,
+				Error:     "Missing user claims",
+			},
+		})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "File is required",
+			Data: errors.ErrorModel{
+				Message:   "No file uploaded",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	if fileHeader.Size > maxChatFileSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(response.ResponseModel{
+			RetCode: "413",
+			Message: "File too large",
+			Data: errors.ErrorModel{
+				Message:   "Attachments must be 10 MB or smaller",
+				IsSuccess: false,
+				Error:     "File exceeds size limit",
+			},
+		})
+	}
+
+	fileBytes, err := readFileBytes(fileHeader)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to read file",
+			Data: errors.ErrorModel{
+				Message:   "Could not read uploaded file",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	// Sniff the content instead of trusting the client-supplied header
+	contentType := http.DetectContentType(fileBytes)
+	kind, allowed := chatAttachmentTypes[contentType]
+	if !allowed {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(response.ResponseModel{
+			RetCode: "415",
+			Message: "Unsupported file type",
+			Data: errors.ErrorModel{
+				Message:   "Only JPEG, PNG, WebP images and PDF documents are allowed",
+				IsSuccess: false,
+				Error:     contentType,
+			},
+		})
+	}
+
+	if kind == users.MessageTypeImage && len(fileBytes) > maxChatImageSize {
+		return c.Status(fiber.StatusRequestEntityTooLarge).JSON(response.ResponseModel{
+			RetCode: "413",
+			Message: "Image too large",
+			Data: errors.ErrorModel{
+				Message:   "Images must be 5 MB or smaller",
+				IsSuccess: false,
+				Error:     "File exceeds size limit",
+			},
+		})
+	}
+
+	attachment := users.ChatAttachment{
+		UploaderId:  claims.UserId,
+		FileName:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        int64(len(fileBytes)),
+		Data:        fileBytes,
+	}
+
+	if err := db.Create(&attachment).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to save attachment",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Attachment uploaded successfully",
+		Data: fiber.Map{
+			"attachment":   attachment,
+			"message_type": kind,
+		},
+	})
+}
@@ -0,0 +1,217 @@
+package userfeatures
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/controller/signuplogin"
+	"fixify_backend/mailer"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fixify_backend/sms"
+	"fixify_backend/websocketclient"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Requests in these states still need both parties, so the account can't be closed yet
+var activeRequestStatuses = []string{"pending", "in progress"}
+
+// DeleteAccount closes the caller's account after confirming their password. The account is
+// anonymised rather than removed: service requests, reviews, payments and chat messages stay so
+// the other party's history and the books remain intact, but they no longer point to a person.
+// Contact details, the profile picture, ID images, devices, notifications and payout details
+// are erased.
+func DeleteAccount(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+	if claims.IsAdmin() {
+		return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "Not available for admin accounts",
+			Data: errors.ErrorModel{
+				Message:   "Only clients and repairmen can delete their account here",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var body struct {
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Enter your password to delete your account",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var user users.User
+	if err := db.Omit("profile_picture").First(&user, "user_id = ?", claims.UserId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "User not found",
+			Data: errors.ErrorModel{
+				Message:   "Account not found",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(body.Password)) != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Incorrect password",
+			Data: errors.ErrorModel{
+				Message:   "The password you entered is incorrect",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var active int64
+	if err := db.Model(&users.ServiceRequest{}).
+		Where("(user_id = ? OR fixer_id = ?) AND status IN ?", user.UserId, user.UserId, activeRequestStatuses).
+		Count(&active).Error; err != nil {
+		return deleteAccountFailed(c, err)
+	}
+	if active > 0 {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "You have open service requests",
+			Data: errors.ErrorModel{
+				Message:   "Complete or cancel your pending and in-progress requests before deleting your account",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return anonymiseUser(tx, user)
+	}); err != nil {
+		return deleteAccountFailed(c, err)
+	}
+
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAccountDelete,
+		TargetType: users.RoleUser,
+		TargetId:   user.UserId,
+		After:      map[string]interface{}{"account_status": users.AccountStatusDeleted},
+	})
+
+	websocketclient.HubInstance.Disconnect(user.UserId)
+
+	// Sent without the outbox, which would keep the erased address on file
+	go func(email string) {
+		if err := mailer.SendUnstored(email, mailer.TemplateNotification, mailer.NotificationData{
+			Title: "Your Fixify account has been deleted",
+			Body:  "Your account and personal details have been deleted as you requested. Thank you for using Fixify.",
+		}); err != nil {
+			log.Printf("Failed to send account deleted email: %v", err)
+		}
+	}(user.Email)
+
+	log.Printf("User %d deleted their account", user.UserId)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Your account has been deleted",
+	})
+}
+
+// anonymiseUser erases a user's personal data. Email and phone are unique, so they are replaced
+// with values derived from the ID.
+func anonymiseUser(tx *gorm.DB, user users.User) error {
+	placeholder := fmt.Sprintf("deleted-%d", user.UserId)
+	if err := tx.Model(&users.User{}).Where("user_id = ?", user.UserId).Updates(map[string]interface{}{
+		"first_name":        "Deleted",
+		"last_name":         "User",
+		"email":             placeholder + "@deleted.invalid",
+		"verified_email":    "",
+		"phone":             placeholder,
+		"password":          "", // Never matches a bcrypt comparison
+		"gender":            "",
+		"address":           "",
+		"availability":      "",
+		"profile_picture":   nil,
+		"fcm_token":         "",
+		"quiet_hours_start": "",
+		"quiet_hours_end":   "",
+		"phone_verified_at": nil,
+		"account_status":    users.AccountStatusDeleted,
+		"suspended_until":   nil,
+		"status_reason":     "",
+	}).Error; err != nil {
+		return err
+	}
+	if err := signuplogin.RevokeSessions(tx, users.RoleUser, user.UserId); err != nil {
+		return err
+	}
+
+	// ID images are erased; the review decisions are kept
+	if err := tx.Model(&users.UserVerification{}).Where("user_id = ?", user.UserId).Updates(map[string]interface{}{
+		"valid_id": []byte{},
+		"selfie":   []byte{},
+		"back_id":  []byte{},
+		"key_id":   "",
+	}).Error; err != nil {
+		return err
+	}
+
+	// Payments refer to the payout account, so it is kept without the name and number
+	if err := tx.Model(&users.Gcash{}).Where("user_id = ?", user.UserId).Updates(map[string]interface{}{
+		"gcash_name":   "Deleted User",
+		"gcash_number": "",
+	}).Error; err != nil {
+		return err
+	}
+
+	// Nobody is left to answer, so open tickets are closed
+	if err := tx.Model(&users.SupportTicket{}).
+		Where("user_id = ? AND status <> ?", user.UserId, users.TicketStatusClosed).
+		Updates(map[string]interface{}{"status": users.TicketStatusClosed, "closed_at": time.Now()}).Error; err != nil {
+		return err
+	}
+
+	deletions := []*gorm.DB{
+		tx.Where("owner_type = ? AND owner_id = ?", users.RoleUser, user.UserId).Delete(&users.DeviceToken{}),
+		tx.Where("user_id = ?", user.UserId).Delete(&users.NotificationPreference{}),
+		tx.Where("to_user = ?", user.UserId).Delete(&users.UserNotification{}),
+		tx.Where("to_user = ?", user.UserId).Delete(&users.ChatNotification{}),
+		tx.Where("blocker_id = ? OR blocked_id = ?", user.UserId, user.UserId).Delete(&users.UserBlock{}),
+		tx.Where("email = ?", user.Email).Delete(&users.EmailVer{}),
+		tx.Where("scope = ? AND login_key = ?", users.LoginScopeAccount, users.RoleUser+":"+strings.ToLower(user.Email)).Delete(&users.LoginAttempt{}),
+		tx.Where("recipient = ?", user.Email).Delete(&users.EmailOutbox{}),
+	}
+	if phone, err := sms.NormalizePhone(user.Phone); err == nil {
+		deletions = append(deletions, tx.Where("phone = ?", phone).Delete(&users.PhoneVer{}))
+	}
+	for _, deletion := range deletions {
+		if deletion.Error != nil {
+			return deletion.Error
+		}
+	}
+	return nil
+}
+
+func deleteAccountFailed(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Failed to delete account",
+		Data: errors.ErrorModel{
+			Message:   "Database error",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
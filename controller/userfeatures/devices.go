@@ -0,0 +1,129 @@
+package userfeatures
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DeviceBody struct {
+	Token      string `json:"token"`
+	FCMToken   string `json:"fcm_token"` // Field name used by older app versions
+	Platform   string `json:"platform"`
+	AppVersion string `json:"app_version"`
+}
+
+func (b DeviceBody) token() string {
+	if b.Token != "" {
+		return strings.TrimSpace(b.Token)
+	}
+	return strings.TrimSpace(b.FCMToken)
+}
+
+// RegisterDevice adds the caller's FCM token to their device list. Calling it again on app start
+// refreshes the platform, app version and last seen time.
+func RegisterDevice(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var body DeviceBody
+	if err := c.BodyParser(&body); err != nil || body.token() == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data: errors.ErrorModel{
+				Message:   "A device token is required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	platform := strings.ToLower(strings.TrimSpace(body.Platform))
+	if len(platform) > 20 || len(body.AppVersion) > 32 || len(body.token()) > 255 {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data: errors.ErrorModel{
+				Message:   "Device details are too long",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	device, err := websocketclient.RegisterDevice(db, deviceOwnerType(claims), claims.UserId, body.token(), platform, body.AppVersion)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to register device",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Device registered",
+		Data:    device,
+	})
+}
+
+// UnregisterDevice removes one of the caller's FCM tokens so the device stops receiving pushes
+func UnregisterDevice(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var body DeviceBody
+	if err := c.BodyParser(&body); err != nil || body.token() == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data: errors.ErrorModel{
+				Message:   "A device token is required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	removed, err := websocketclient.UnregisterDevice(db, deviceOwnerType(claims), claims.UserId, body.token())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to unregister device",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	if !removed {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Device not found",
+			Data: errors.ErrorModel{
+				Message:   "This token is not registered to your account",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Device unregistered",
+	})
+}
+
+func deviceOwnerType(claims *users.Claims) string {
+	if claims.IsAdmin() {
+		return users.RoleAdmin
+	}
+	return users.RoleUser
+}
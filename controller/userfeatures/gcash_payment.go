@@ -0,0 +1,218 @@
+package userfeatures
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"fixify_backend/mailer"
+	"fixify_backend/middleware"
+	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/gofiber/fiber/v2"
+)
+
+func InitiateXenditGCash(c *fiber.Ctx) error {
+	type RequestBody struct {
+		Amount float64 `json:"amount"`
+	}
+
+	var body RequestBody
+	if err := c.BodyParser(&body); err != nil || body.Amount <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid input",
+		})
+	}
+
+	// SAFELY get authenticated user from context
+	userClaims := c.Locals("user")
+	if userClaims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "User not authenticated",
+		})
+	}
+
+	claims, ok := userClaims.(*users.Claims)
+	if !ok || claims == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid token claims",
+		})
+	}
+
+	db := middleware.GetDB()
+
+	// The charge is made for the account's own email, never an address from the request
+	var user users.User
+	if err := db.Select("user_id, email").First(&user, "user_id = ?", claims.UserId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "User not found",
+		})
+	}
+
+	// Get API Key from environment variable
+	apiKey := os.Getenv("XENDIT_API_KEY")
+	if apiKey == "" {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Xendit API key not configured",
+		})
+	}
+
+	client := resty.New()
+	refID := "gcash-ref-" + time.Now().Format("20060102150405")
+
+	resp, err := client.R().
+		SetBasicAuth(apiKey, "").
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]interface{}{
+			"reference_id":    refID,
+			"currency":        "PHP",
+			"amount":          body.Amount,
+			"checkout_method": "ONE_TIME_PAYMENT",
+			"channel_code":    "PH_GCASH",
+			"channel_properties": map[string]string{
+				"success_redirect_url": os.Getenv("SUCCESS_REDIRECT_URL"),
+				"failure_redirect_url": os.Getenv("FAILURE_REDIRECT_URL"),
+			},
+			"customer": map[string]string{
+				"email": user.Email,
+			},
+		}).
+		Post("https://api.xendit.co/ewallets/charges")
+
+	if err != nil || resp.StatusCode() >= 400 {
+		errorMsg := "GCash payment failed"
+		if err != nil {
+			errorMsg = fmt.Sprintf("%s: %v", errorMsg, err)
+		}
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error":    errorMsg,
+			"status":   resp.StatusCode(),
+			"response": string(resp.Body()),
+		})
+	}
+
+	var responseBody struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(resp.Body(), &responseBody); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to parse Xendit response",
+		})
+	}
+
+	// Save payment to database. It stays pending until XenditGCashCallback confirms it.
+	payment := users.GCashPayment{
+		PaymentFrom:   int(claims.UserId),
+		PaymentTo:     0, // Replace with the actual recipient ID if applicable
+		TransactionId: responseBody.ID,
+		Amount:        body.Amount,
+		GcashID:       0,
+		PaymentDate:   time.Now(),
+		ReferenceId:   refID,
+		Status:        users.PaymentStatusPending,
+	}
+
+	if err := db.Create(&payment).Error; err != nil {
+		fmt.Println("DB Save Error:", err) // Print in logs
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(), // Return full error
+		})
+	}
+
+	return c.Status(fiber.StatusOK).Send(resp.Body())
+}
+
+// XenditGCashCallback receives e-wallet charge updates from Xendit. Requests must carry the
+// X-CALLBACK-TOKEN configured in the Xendit dashboard (XENDIT_CALLBACK_TOKEN). The first update
+// that settles a pending charge notifies the payer, and a successful one sends the receipt.
+func XenditGCashCallback(c *fiber.Ctx) error {
+	expected := os.Getenv("XENDIT_CALLBACK_TOKEN")
+	if expected == "" || subtle.ConstantTimeCompare([]byte(c.Get("X-CALLBACK-TOKEN")), []byte(expected)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "Invalid callback token",
+		})
+	}
+
+	var event struct {
+		Event string `json:"event"`
+		Data  struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := c.BodyParser(&event); err != nil || event.Data.ID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid input",
+		})
+	}
+
+	var status string
+	switch event.Data.Status {
+	case "SUCCEEDED":
+		status = users.PaymentStatusSucceeded
+	case "FAILED", "VOIDED":
+		status = users.PaymentStatusFailed
+	default:
+		// Still pending, nothing to record
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	db := middleware.GetDB()
+
+	var payment users.GCashPayment
+	if err := db.Where("transaction_id = ?", event.Data.ID).First(&payment).Error; err != nil {
+		// Unknown to us; acknowledge so Xendit stops retrying
+		log.Printf("Xendit callback for unknown charge %s", event.Data.ID)
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	// Xendit retries callbacks, so only the update that settles the charge notifies
+	now := time.Now()
+	result := db.Model(&payment).Where("status = ?", users.PaymentStatusPending).
+		Updates(map[string]interface{}{"status": status, "confirmed_at": now})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update payment",
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.SendStatus(fiber.StatusOK)
+	}
+
+	notification := websocketclient.Notification{
+		Category: users.NotificationCategoryPayment,
+		Type:     "Payment",
+		ToUser:   uint(payment.PaymentFrom),
+		Data: map[string]string{
+			"payment_id": fmt.Sprintf("%d", payment.PaymentID),
+			"status":     status,
+		},
+	}
+	if status == users.PaymentStatusSucceeded {
+		notification.Title = "Payment received"
+		notification.Body = fmt.Sprintf("Your GCash payment of PHP %.2f was received.", payment.Amount)
+		notification.EmailTemplate = mailer.TemplateReceipt
+		notification.EmailData = mailer.ReceiptData{
+			Reference: payment.ReferenceId,
+			Amount:    payment.Amount,
+			Currency:  "PHP",
+			Method:    "GCash",
+			Date:      now,
+			Status:    "Paid",
+		}
+	} else {
+		notification.Title = "Payment failed"
+		notification.Body = fmt.Sprintf("Your GCash payment of PHP %.2f did not go through.", payment.Amount)
+	}
+
+	if err := websocketclient.Notify(db, notification); err != nil {
+		log.Printf("Payment notification for %d failed: %v", payment.PaymentID, err)
+	}
+
+	return c.SendStatus(fiber.StatusOK)
+}
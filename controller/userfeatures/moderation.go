@@ -0,0 +1,190 @@
+package userfeatures
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm/clause"
+)
+
+// BlockUser blocks the user in the route so they can no longer chat with or send requests to the caller
+func BlockUser(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	blockedId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || blockedId <= 0 || uint(blockedId) == claims.UserId {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid user ID",
+			Data: errors.ErrorModel{
+				Message:   "User ID must be a valid number other than your own",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var blocked users.User
+	if err := db.First(&blocked, "user_id = ?", blockedId).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "User not found",
+			Data: errors.ErrorModel{
+				Message:   "No user with the given ID",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	block := users.UserBlock{
+		BlockerId: claims.UserId,
+		BlockedId: uint(blockedId),
+	}
+
+	// Blocking twice is a no-op
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to block user",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "User blocked",
+		Data: fiber.Map{
+			"blocked_id": blockedId,
+		},
+	})
+}
+
+// UnblockUser removes a block created by the caller
+func UnblockUser(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	blockedId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || blockedId <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid user ID",
+			Data: errors.ErrorModel{
+				Message:   "User ID must be a valid number",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	if err := db.Where("blocker_id = ? AND blocked_id = ?", claims.UserId, blockedId).
+		Delete(&users.UserBlock{}).Error; err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to unblock user",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "User unblocked",
+		Data: fiber.Map{
+			"blocked_id": blockedId,
+		},
+	})
+}
+
+// ReportMessage flags a chat message the caller received for the admin moderation queue
+func ReportMessage(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	messageId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || messageId <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid message ID",
+			Data: errors.ErrorModel{
+				Message:   "Message ID must be a valid number",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil || strings.TrimSpace(body.Reason) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data: errors.ErrorModel{
+				Message:   "A reason is required",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	// Only the other participant of the conversation can report a message
+	var message users.ClientRepairmanMessage
+	if err := db.Preload("Conversation").First(&message, "message_id = ?", messageId).Error; err != nil ||
+		!message.Conversation.HasParticipant(claims.UserId) || message.SenderId == claims.UserId {
+		return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Message not found",
+			Data: errors.ErrorModel{
+				Message:   "No reportable message with the given ID",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	report := users.MessageReport{
+		MessageId:  uint(messageId),
+		ReporterId: claims.UserId,
+		Reason:     strings.TrimSpace(body.Reason),
+		Status:     users.ReportStatusPending,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&report)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to report message",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     result.Error.Error(),
+			},
+		})
+	}
+	if result.RowsAffected == 0 {
+		return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+			RetCode: "409",
+			Message: "Message already reported",
+			Data: errors.ErrorModel{
+				Message:   "You have already reported this message",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Message reported. Our team will review it shortly.",
+		Data:    report,
+	})
+}
@@ -0,0 +1,130 @@
+package userfeatures
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationPreferencesBody struct {
+	Preferences []struct {
+		Category     string `json:"category"`
+		Muted        bool   `json:"muted"`
+		PushEnabled  bool   `json:"push_enabled"`
+		EmailEnabled bool   `json:"email_enabled"`
+	} `json:"preferences"`
+	QuietHoursStart *string `json:"quiet_hours_start"` // "HH:MM", empty string clears
+	QuietHoursEnd   *string `json:"quiet_hours_end"`
+}
+
+// UpdateNotificationPreferences saves the caller's per-category preferences and quiet hours.
+// Categories left out of the body keep their current settings.
+func UpdateNotificationPreferences(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	var body NotificationPreferencesBody
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid request body",
+			Data: errors.ErrorModel{
+				Message:   "Failed to parse request",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	preferences := make([]users.NotificationPreference, 0, len(body.Preferences))
+	for _, p := range body.Preferences {
+		if !isNotificationCategory(p.Category) {
+			return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+				RetCode: "400",
+				Message: "Invalid category",
+				Data: errors.ErrorModel{
+					Message:   "Unknown notification category: " + p.Category,
+					IsSuccess: false,
+				},
+			})
+		}
+		preferences = append(preferences, users.NotificationPreference{
+			UserId:       claims.UserId,
+			Category:     p.Category,
+			Muted:        p.Muted,
+			PushEnabled:  p.PushEnabled,
+			EmailEnabled: p.EmailEnabled,
+		})
+	}
+
+	quietHours := map[string]interface{}{}
+	if body.QuietHoursStart != nil {
+		quietHours["quiet_hours_start"] = *body.QuietHoursStart
+	}
+	if body.QuietHoursEnd != nil {
+		quietHours["quiet_hours_end"] = *body.QuietHoursEnd
+	}
+	for _, value := range quietHours {
+		if v := value.(string); v != "" {
+			if _, err := time.Parse("15:04", v); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+					RetCode: "400",
+					Message: "Invalid quiet hours",
+					Data: errors.ErrorModel{
+						Message:   "Quiet hours must be in HH:MM format",
+						IsSuccess: false,
+					},
+				})
+			}
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(preferences) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "category"}},
+				DoUpdates: clause.AssignmentColumns([]string{"muted", "push_enabled", "email_enabled", "updated_at"}),
+			}).Create(&preferences).Error; err != nil {
+				return err
+			}
+		}
+		if len(quietHours) > 0 {
+			if err := tx.Model(&users.User{}).Where("user_id = ?", claims.UserId).Updates(quietHours).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to update preferences",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Notification preferences updated",
+		Data:    preferences,
+	})
+}
+
+func isNotificationCategory(category string) bool {
+	for _, c := range users.NotificationCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package userfeatures
+
+import (
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MarkNotificationRead marks one of the caller's notifications as read
+func MarkNotificationRead(c *fiber.Ctx) error {
+	return updateOwnNotification(c, map[string]interface{}{"is_read": true}, "Notification marked as read")
+}
+
+// ArchiveNotification moves one of the caller's notifications out of the inbox. Archived
+// notifications are treated as read and can still be listed with ?archived=true.
+func ArchiveNotification(c *fiber.Ctx) error {
+	return updateOwnNotification(c, map[string]interface{}{"is_read": true, "is_archived": true}, "Notification archived")
+}
+
+// MarkAllNotificationsRead marks every unread notification of the caller as read
+func MarkAllNotificationsRead(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	result := db.Model(&users.UserNotification{}).
+		Where("to_user = ? AND is_read = ?", claims.UserId, false).
+		Update("is_read", true)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to update notifications",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     result.Error.Error(),
+			},
+		})
+	}
+
+	websocketclient.PushUnreadCount(db, claims.UserId)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "All notifications marked as read",
+		Data: fiber.Map{
+			"updated": result.RowsAffected,
+		},
+	})
+}
+
+// DeleteNotification permanently removes one of the caller's notifications
+func DeleteNotification(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	notificationId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || notificationId <= 0 {
+		return invalidNotificationId(c)
+	}
+
+	result := db.Where("notification_id = ? AND to_user = ?", notificationId, claims.UserId).
+		Delete(&users.UserNotification{})
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to delete notification",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     result.Error.Error(),
+			},
+		})
+	}
+	if result.RowsAffected == 0 {
+		return notificationNotFound(c)
+	}
+
+	websocketclient.PushUnreadCount(db, claims.UserId)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Notification deleted",
+		Data: fiber.Map{
+			"notification_id": notificationId,
+		},
+	})
+}
+
+// updateOwnNotification applies updates to a notification addressed to the caller and pushes the
+// new unread count. Other users' notifications are reported as not found.
+func updateOwnNotification(c *fiber.Ctx, updates map[string]interface{}, message string) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	notificationId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || notificationId <= 0 {
+		return invalidNotificationId(c)
+	}
+
+	result := db.Model(&users.UserNotification{}).
+		Where("notification_id = ? AND to_user = ?", notificationId, claims.UserId).
+		Updates(updates)
+	if result.Error != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Failed to update notification",
+			Data: errors.ErrorModel{
+				Message:   "Database error",
+				IsSuccess: false,
+				Error:     result.Error.Error(),
+			},
+		})
+	}
+	if result.RowsAffected == 0 {
+		// Updates reports zero rows when nothing changed, so check the row really is missing
+		var count int64
+		db.Model(&users.UserNotification{}).
+			Where("notification_id = ? AND to_user = ?", notificationId, claims.UserId).
+			Count(&count)
+		if count == 0 {
+			return notificationNotFound(c)
+		}
+	}
+
+	websocketclient.PushUnreadCount(db, claims.UserId)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: message,
+		Data: fiber.Map{
+			"notification_id": notificationId,
+		},
+	})
+}
+
+func invalidNotificationId(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+		RetCode: "400",
+		Message: "Invalid notification ID",
+		Data: errors.ErrorModel{
+			Message:   "Notification ID must be a valid number",
+			IsSuccess: false,
+		},
+	})
+}
+
+func notificationNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+		RetCode: "404",
+		Message: "Notification not found",
+		Data: errors.ErrorModel{
+			Message:   "No notification with the given ID",
+			IsSuccess: false,
+		},
+	})
+}
@@ -1,11 +1,11 @@
 package userfeatures
 
 import (
-	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
 	"math"
 	"strconv"
 	"time"
@@ -133,14 +133,15 @@ func ReviewRequest(c *fiber.Ctx) error {
 	}
 
 	// === Call centralized notification creator ===
-	if err := controller.CreateUserNotification(
-		db,
-		"Service Review",
-		requestId,
-		int(user.UserId),
-		int(serviceRequest.RepairmanId),
-		"You received a new review from "+client.First_name,
-	); err != nil {
+	if err := websocketclient.Notify(db, websocketclient.Notification{
+		Category:  users.NotificationCategoryReview,
+		Type:      "Service Review",
+		RequestId: requestId,
+		FromUser:  user.UserId,
+		ToUser:    serviceRequest.RepairmanId,
+		Title:     "New review",
+		Body:      "You received a new review from " + client.First_name,
+	}); err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
 			RetCode: "500",
 			Message: "Failed to create notification",
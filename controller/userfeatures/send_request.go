@@ -6,6 +6,7 @@ import (
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -78,6 +79,56 @@ func ServiceRequest(c *fiber.Ctx) error {
 		})
 	}
 
+	// Only verified repairmen (or new ones in grace mode) can take requests
+	bookable, err := controller.IsRepairmanBookable(db, uint(repairmanId))
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Cannot create request!",
+			Data: errors.ErrorModel{
+				Message:   "Failed to check repairman verification",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	if !bookable {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "Repairman not verified!",
+			Data: errors.ErrorModel{
+				Message:   "This repairman has not completed ID verification yet",
+				IsSuccess: false,
+				Error:     "Repairman is not verified",
+			},
+		})
+	}
+
+	// Blocked pairs cannot start new jobs with each other
+	blocked, err := controller.IsBlocked(db, user.UserId, uint(repairmanId))
+	if err != nil {
+		return c.JSON(response.ResponseModel{
+			RetCode: "500",
+			Message: "Cannot create request!",
+			Data: errors.ErrorModel{
+				Message:   "Failed to check block list",
+				IsSuccess: false,
+				Error:     err.Error(),
+			},
+		})
+	}
+	if blocked {
+		return c.JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "Cannot send request to this repairman!",
+			Data: errors.ErrorModel{
+				Message:   "You cannot send requests to this repairman",
+				IsSuccess: false,
+				Error:     "User is blocked",
+			},
+		})
+	}
+
 	// Create the service request
 	request := &users.ServiceRequest{
 		UserId:      user.UserId,
@@ -120,14 +171,15 @@ func ServiceRequest(c *fiber.Ctx) error {
 	// After successfully creating the request and preloading it
 	notificationDescription := "You have received a new service request from " + client.First_name + "."
 
-	if err := controller.CreateUserNotification(
-		db,
-		"Service Request",
-		int(fullRequest.RequestId),
-		int(user.UserId),
-		int(fullRequest.RepairmanId),
-		notificationDescription,
-	); err != nil {
+	if err := websocketclient.Notify(db, websocketclient.Notification{
+		Category:  users.NotificationCategoryRequest,
+		Type:      "Service Request",
+		RequestId: int(fullRequest.RequestId),
+		FromUser:  user.UserId,
+		ToUser:    fullRequest.RepairmanId,
+		Title:     "New service request",
+		Body:      notificationDescription,
+	}); err != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "500",
 			Message: "Notification creation failed!",
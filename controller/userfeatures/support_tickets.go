@@ -0,0 +1,288 @@
+package userfeatures
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/middleware"
+	errors "fixify_backend/model/error"
+	"fixify_backend/model/response"
+	"fixify_backend/model/users"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// A user can't have more unresolved tickets than this at once
+const maxOpenTicketsPerUser = 10
+
+var ticketCategories = map[string]bool{"general": true, "request": true, "payment": true, "account": true}
+
+// CreateSupportTicket opens a support ticket with a first message. Body: subject, message, and
+// optionally category (general, request, payment or account), priority (low, normal or high),
+// and the request_id or payment_id it is about, which must be the user's own.
+func CreateSupportTicket(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+	if claims.IsAdmin() {
+		return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+			RetCode: "403",
+			Message: "Not available for admin accounts",
+			Data: errors.ErrorModel{
+				Message:   "Only clients and repairmen can open support tickets",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	var body struct {
+		Subject   string `json:"subject"`
+		Message   string `json:"message"`
+		Category  string `json:"category"`
+		Priority  string `json:"priority"`
+		RequestId *int   `json:"request_id"`
+		PaymentId *uint  `json:"payment_id"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return invalidTicket(c, "Failed to parse request")
+	}
+	body.Subject = strings.TrimSpace(body.Subject)
+	body.Message = strings.TrimSpace(body.Message)
+	if body.Subject == "" || len(body.Subject) > 200 {
+		return invalidTicket(c, "Subject must be between 1 and 200 characters")
+	}
+	if body.Message == "" || len(body.Message) > controller.MaxTicketMessageLength {
+		return invalidTicket(c, "Message must be between 1 and 5000 characters")
+	}
+
+	switch body.Priority {
+	case "":
+		body.Priority = users.TicketPriorityNormal
+	case users.TicketPriorityLow, users.TicketPriorityNormal, users.TicketPriorityHigh:
+	default:
+		return invalidTicket(c, "Priority must be low, normal or high")
+	}
+
+	if body.RequestId != nil {
+		var count int64
+		if err := db.Model(&users.ServiceRequest{}).
+			Where("request_id = ? AND (user_id = ? OR fixer_id = ?)", *body.RequestId, claims.UserId, claims.UserId).
+			Count(&count).Error; err != nil {
+			return ticketFailed(c, err)
+		}
+		if count == 0 {
+			return invalidTicket(c, "You can only link your own service requests")
+		}
+		if body.Category == "" {
+			body.Category = "request"
+		}
+	}
+	if body.PaymentId != nil {
+		var count int64
+		if err := db.Model(&users.GCashPayment{}).
+			Where("payment_id = ? AND (payment_from = ? OR payment_to = ?)", *body.PaymentId, claims.UserId, claims.UserId).
+			Count(&count).Error; err != nil {
+			return ticketFailed(c, err)
+		}
+		if count == 0 {
+			return invalidTicket(c, "You can only link your own payments")
+		}
+		if body.Category == "" {
+			body.Category = "payment"
+		}
+	}
+	if body.Category == "" {
+		body.Category = "general"
+	}
+	if !ticketCategories[body.Category] {
+		return invalidTicket(c, "Category must be general, request, payment or account")
+	}
+
+	var open int64
+	if err := db.Model(&users.SupportTicket{}).
+		Where("user_id = ? AND status IN ?", claims.UserId, []string{users.TicketStatusOpen, users.TicketStatusPending}).
+		Count(&open).Error; err != nil {
+		return ticketFailed(c, err)
+	}
+	if open >= maxOpenTicketsPerUser {
+		return c.Status(fiber.StatusTooManyRequests).JSON(response.ResponseModel{
+			RetCode: "429",
+			Message: "Too many open tickets",
+			Data: errors.ErrorModel{
+				Message:   "Please wait for your open tickets to be answered before opening more",
+				IsSuccess: false,
+			},
+		})
+	}
+
+	now := time.Now()
+	ticket := users.SupportTicket{
+		UserId:        claims.UserId,
+		Subject:       body.Subject,
+		Category:      body.Category,
+		Status:        users.TicketStatusOpen,
+		Priority:      body.Priority,
+		RequestId:     body.RequestId,
+		PaymentId:     body.PaymentId,
+		LastMessageAt: now,
+		CreatedAt:     now,
+	}
+	ticket.SetDueTimes()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("User", "Assignee", "Messages").Create(&ticket).Error; err != nil {
+			return err
+		}
+		_, err := controller.AddTicketMessage(tx, &ticket, claims.UserId, users.RoleUser, body.Message, false)
+		return err
+	})
+	if err != nil {
+		return ticketFailed(c, err)
+	}
+
+	log.Printf("User %d opened support ticket %d", claims.UserId, ticket.TicketId)
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Ticket created. Our support team will get back to you soon.",
+		Data:    ticket,
+	})
+}
+
+// ReplySupportTicket adds the user's message to their ticket. Replying to a ticket that was
+// waiting on them or marked resolved puts it back in the support team's queue.
+func ReplySupportTicket(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	ticket, found, err := ownTicket(c, db, claims)
+	if !found {
+		return err
+	}
+	if ticket.Status == users.TicketStatusClosed {
+		return closedTicket(c)
+	}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return invalidTicket(c, "Failed to parse request")
+	}
+	body.Message = strings.TrimSpace(body.Message)
+	if body.Message == "" || len(body.Message) > controller.MaxTicketMessageLength {
+		return invalidTicket(c, "Message must be between 1 and 5000 characters")
+	}
+
+	var message users.SupportTicketMessage
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if message, err = controller.AddTicketMessage(tx, &ticket, claims.UserId, users.RoleUser, body.Message, false); err != nil {
+			return err
+		}
+		if ticket.Status == users.TicketStatusOpen {
+			return nil
+		}
+		ticket.Status = users.TicketStatusOpen
+		ticket.ResolvedAt = nil
+		return tx.Model(&users.SupportTicket{}).Where("ticket_id = ?", ticket.TicketId).Updates(map[string]interface{}{
+			"status":      users.TicketStatusOpen,
+			"resolved_at": nil,
+		}).Error
+	})
+	if err != nil {
+		return ticketFailed(c, err)
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Message sent",
+		Data:    message,
+	})
+}
+
+// CloseSupportTicket lets the user close their ticket when they need no more help
+func CloseSupportTicket(c *fiber.Ctx) error {
+	db := middleware.DBConn
+	claims := c.Locals("user").(*users.Claims)
+
+	ticket, found, err := ownTicket(c, db, claims)
+	if !found {
+		return err
+	}
+
+	if ticket.Status != users.TicketStatusClosed {
+		now := time.Now()
+		if err := db.Model(&users.SupportTicket{}).Where("ticket_id = ?", ticket.TicketId).Updates(map[string]interface{}{
+			"status":    users.TicketStatusClosed,
+			"closed_at": now,
+		}).Error; err != nil {
+			return ticketFailed(c, err)
+		}
+		ticket.Status = users.TicketStatusClosed
+		ticket.ClosedAt = &now
+	}
+
+	return c.JSON(response.ResponseModel{
+		RetCode: "200",
+		Message: "Ticket closed",
+		Data:    ticket,
+	})
+}
+
+// ownTicket loads the ticket in the :id param if it belongs to the user. When it doesn't, found
+// is false and err is the response that was sent.
+func ownTicket(c *fiber.Ctx, db *gorm.DB, claims *users.Claims) (users.SupportTicket, bool, error) {
+	var ticket users.SupportTicket
+	ticketId, err := strconv.Atoi(c.Params("id"))
+	if err != nil || ticketId <= 0 {
+		return ticket, false, invalidTicket(c, "Ticket ID must be a valid number")
+	}
+	if claims.IsAdmin() || db.First(&ticket, "ticket_id = ? AND user_id = ?", ticketId, claims.UserId).Error != nil {
+		return ticket, false, c.Status(fiber.StatusNotFound).JSON(response.ResponseModel{
+			RetCode: "404",
+			Message: "Ticket not found",
+			Data: errors.ErrorModel{
+				Message:   "No ticket with the given ID",
+				IsSuccess: false,
+			},
+		})
+	}
+	return ticket, true, nil
+}
+
+func closedTicket(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusConflict).JSON(response.ResponseModel{
+		RetCode: "409",
+		Message: "Ticket closed",
+		Data: errors.ErrorModel{
+			Message:   "This ticket is closed. Please open a new one.",
+			IsSuccess: false,
+		},
+	})
+}
+
+func invalidTicket(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+		RetCode: "400",
+		Message: "Invalid Request!",
+		Data: errors.ErrorModel{
+			Message:   message,
+			IsSuccess: false,
+		},
+	})
+}
+
+func ticketFailed(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusInternalServerError).JSON(response.ResponseModel{
+		RetCode: "500",
+		Message: "Request failed",
+		Data: errors.ErrorModel{
+			Message:   "Database error",
+			IsSuccess: false,
+			Error:     err.Error(),
+		},
+	})
+}
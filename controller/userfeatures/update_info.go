@@ -1,32 +1,28 @@
 package userfeatures
 
 import (
+	"fixify_backend/controller"
+	"fixify_backend/controller/signuplogin"
 	"fixify_backend/middleware"
 	errors "fixify_backend/model/error"
 	"fixify_backend/model/response"
 	"fixify_backend/model/users"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 func UpdateAccount(c *fiber.Ctx) error {
 	db := middleware.DBConn
 
-	// Get the user ID from the route parameter
-	userIdParam := c.Params("id")
-	userId, err := strconv.Atoi(userIdParam)
-	if err != nil || userId <= 0 {
-		return c.JSON(response.ResponseModel{
-			RetCode: "400",
-			Message: "Invalid User ID!",
-			Data: errors.ErrorModel{
-				Message:   "User ID must be a valid number",
-				IsSuccess: false,
-				Error:     err.Error(),
-			},
-		})
+	// The path ID must name the caller's own account
+	claims := c.Locals("user").(*users.Claims)
+	userId := int(claims.UserId)
+	if c.Params("id") != strconv.Itoa(userId) {
+		return notOwnAccount(c)
 	}
 
 	// Create a variable to hold the data received in the request body
@@ -56,6 +52,8 @@ func UpdateAccount(c *fiber.Ctx) error {
 	}
 	if update.Phone != "" {
 		updates["phone"] = update.Phone
+		// A different number has to be verified again; the CASE compares against the old value
+		updates["phone_verified_at"] = gorm.Expr("CASE WHEN phone = ? THEN phone_verified_at ELSE NULL END", update.Phone)
 	}
 	if update.Address != "" {
 		updates["address"] = update.Address
@@ -64,7 +62,43 @@ func UpdateAccount(c *fiber.Ctx) error {
 		updates["availability"] = update.Availability
 	}
 	if update.Password != "" {
-		updates["password"] = update.Password
+		// Passwords are changed through PATCH /account/password/:id, which checks the current one
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Use the change password endpoint to update your password",
+				IsSuccess: false,
+				Error:     "password cannot be updated here",
+			},
+		})
+	}
+	if update.Email != "" {
+		// The email is the login and password resets go to the verified address, so users can't
+		// change it here; support does that
+		var current string
+		if err := db.Model(&users.User{}).Where("user_id = ?", userId).Pluck("email", &current).Error; err != nil {
+			return c.JSON(response.ResponseModel{
+				RetCode: "500",
+				Message: "Failed to Update User",
+				Data: errors.ErrorModel{
+					Message:   "Database update failed",
+					IsSuccess: false,
+					Error:     err.Error(),
+				},
+			})
+		}
+		if !strings.EqualFold(strings.TrimSpace(update.Email), current) {
+			return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+				RetCode: "400",
+				Message: "Invalid Request!",
+				Data: errors.ErrorModel{
+					Message:   "Contact support to change your email address",
+					IsSuccess: false,
+					Error:     "email cannot be updated here",
+				},
+			})
+		}
 	}
 	// If no fields were provided for update, return an error
 	if len(updates) == 0 {
@@ -79,6 +113,10 @@ func UpdateAccount(c *fiber.Ctx) error {
 		})
 	}
 
+	// Keep the current values for the audit log
+	var before users.Repairman
+	db.Omit("profile_picture").Where("user_id = ?", userId).First(&before)
+
 	// Update only the fields provided
 	result := db.Model(&users.Repairman{}).
 		Where("user_id = ?", userId).
@@ -108,6 +146,16 @@ func UpdateAccount(c *fiber.Ctx) error {
 		})
 	}
 
+	var after users.Repairman
+	db.Omit("profile_picture").Where("user_id = ?", userId).First(&after)
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAccountUpdate,
+		TargetType: "user",
+		TargetId:   userId,
+		Before:     before,
+		After:      after,
+	})
+
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
 		Message: "Account updated successfully",
@@ -118,22 +166,15 @@ func UpdateAccount(c *fiber.Ctx) error {
 		},
 	})
 }
+
 func UpdateAPassword(c *fiber.Ctx) error {
 	db := middleware.DBConn
 
-	// Get the user ID from the route parameter
-	userIdParam := c.Params("id")
-	userId, err := strconv.Atoi(userIdParam)
-	if err != nil || userId <= 0 {
-		return c.JSON(response.ResponseModel{
-			RetCode: "400",
-			Message: "Invalid User ID!",
-			Data: errors.ErrorModel{
-				Message:   "User ID must be a valid number",
-				IsSuccess: false,
-				Error:     err.Error(),
-			},
-		})
+	// The path ID must name the caller's own account
+	claims := c.Locals("user").(*users.Claims)
+	userId := int(claims.UserId)
+	if c.Params("id") != strconv.Itoa(userId) {
+		return notOwnAccount(c)
 	}
 
 	// Parse the body of the request
@@ -154,8 +195,17 @@ func UpdateAPassword(c *fiber.Ctx) error {
 			},
 		})
 	}
-	// Create a map to hold the fields that will be updated
-	updates := map[string]interface{}{}
+	if updatepass.OldPass == "" || updatepass.NewPass == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
+			RetCode: "400",
+			Message: "Invalid Request!",
+			Data: errors.ErrorModel{
+				Message:   "Both the current and the new password are required",
+				IsSuccess: false,
+				Error:     "Missing fields",
+			},
+		})
+	}
 
 	// Fetch the existing user
 	var existingUser users.User
@@ -170,85 +220,91 @@ func UpdateAPassword(c *fiber.Ctx) error {
 			},
 		})
 	}
-	if updatepass.OldPass != "" {
-		// Compare the provided password with the stored hashed password
-		err := bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(updatepass.OldPass))
-		if err != nil {
-			// If the password doesn't match, return a login failed response
-			return c.JSON(response.ResponseModel{
-				RetCode: "401",
-				Message: "Login Failed!",
-				Data: errors.ErrorModel{
-					Message:   "Invalid email/username or password",
-					IsSuccess: false,
-					Error:     "Password does not match",
-				},
-			})
-		}
-	}
 
-	// If new password is provided, hash and update
-	if updatepass.NewPass != "" {
-		hashedPassword, err := middleware.HashPassword(updatepass.NewPass)
-		if err != nil {
-			return c.JSON(response.ResponseModel{
-				RetCode: "500",
-				Message: "Failed to hash password",
-				Data: errors.ErrorModel{
-					Message:   "Error hashing password",
-					IsSuccess: false,
-					Error:     err.Error(),
-				},
-			})
-		}
-		updates["password"] = hashedPassword
+	// Compare the provided password with the stored hashed password
+	if err := bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(updatepass.OldPass)); err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(response.ResponseModel{
+			RetCode: "401",
+			Message: "Incorrect password",
+			Data: errors.ErrorModel{
+				Message:   "The current password is incorrect",
+				IsSuccess: false,
+				Error:     "Password does not match",
+			},
+		})
 	}
 
-	// If no update fields provided
-	if len(updates) == 0 {
-		return c.JSON(response.ResponseModel{
+	if err := middleware.ValidatePasswordStrength(updatepass.NewPass); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(response.ResponseModel{
 			RetCode: "400",
-			Message: "Invalid Request!",
+			Message: "Weak password",
 			Data: errors.ErrorModel{
-				Message:   "At least one field must be provided for update",
+				Message:   err.Error(),
 				IsSuccess: false,
-				Error:     "No fields to update",
 			},
 		})
 	}
 
-	// Perform the update
-	result := db.Model(&users.User{}).Where("user_id = ?", userId).Updates(updates)
-	if result.Error != nil {
+	hashedPassword, err := middleware.HashPassword(updatepass.NewPass)
+	if err != nil {
 		return c.JSON(response.ResponseModel{
 			RetCode: "500",
-			Message: "Failed to Update User",
+			Message: "Failed to hash password",
 			Data: errors.ErrorModel{
-				Message:   "Database update failed",
+				Message:   "Error hashing password",
 				IsSuccess: false,
-				Error:     result.Error.Error(),
+				Error:     err.Error(),
 			},
 		})
 	}
-	if result.RowsAffected == 0 {
+	updates := map[string]interface{}{"password": hashedPassword}
+
+	// Store the new password and sign out every session, this one included
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&users.User{}).Where("user_id = ?", userId).Updates(updates).Error; err != nil {
+			return err
+		}
+		return signuplogin.RevokeSessions(tx, users.RoleUser, uint(userId))
+	})
+	if err != nil {
 		return c.JSON(response.ResponseModel{
-			RetCode: "404",
-			Message: "User Not Found",
+			RetCode: "500",
+			Message: "Failed to Update User",
 			Data: errors.ErrorModel{
-				Message:   "No user found with the given ID",
+				Message:   "Database update failed",
 				IsSuccess: false,
-				Error:     "No rows affected",
+				Error:     err.Error(),
 			},
 		})
 	}
 
+	controller.RecordAudit(c, db, controller.Audit{
+		Action:     users.AuditAccountPasswordChange,
+		TargetType: "user",
+		TargetId:   userId,
+		Before:     map[string]interface{}{"password": existingUser.Password},
+		After:      updates,
+	})
+
 	return c.JSON(response.ResponseModel{
 		RetCode: "200",
-		Message: "Account updated successfully",
+		Message: "Password changed, please log in again",
 		Data: errors.ErrorModel{
-			Message:   "Account updated successfully",
+			Message:   "Password changed, please log in again",
 			IsSuccess: true,
 			Error:     "",
 		},
 	})
 }
+
+// notOwnAccount refuses an update addressed to someone else's account
+func notOwnAccount(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(response.ResponseModel{
+		RetCode: "403",
+		Message: "Forbidden",
+		Data: errors.ErrorModel{
+			Message:   "You can only update your own account",
+			IsSuccess: false,
+		},
+	})
+}
@@ -2,10 +2,13 @@ package userfeatures
 
 import (
 	"encoding/base64"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 
+	"fixify_backend/controller"
 	"fixify_backend/middleware"
 	"fixify_backend/model/users"
 )
@@ -19,6 +22,26 @@ func UploadIDCardAndSelfie(c *fiber.Ctx) error {
 		return unauthorizedResponse()
 	}
 
+	// Document type and, for documents that expire, the last day it is valid (YYYY-MM-DD)
+	documentType := strings.ToLower(strings.TrimSpace(c.FormValue("document_type")))
+	hasExpiry, known := users.DocumentTypes[documentType]
+	if !known {
+		return fiber.NewError(fiber.StatusBadRequest, "document_type must be one of passport, drivers_license, national_id, umid, prc_id, postal_id or voters_id")
+	}
+
+	var expiresAt *time.Time
+	if hasExpiry {
+		expiry, err := time.Parse("2006-01-02", strings.TrimSpace(c.FormValue("expires_at")))
+		if err != nil {
+			return badRequestResponse("expires_at (YYYY-MM-DD) is required for this document", err)
+		}
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		if expiry.Before(today) {
+			return fiber.NewError(fiber.StatusBadRequest, "This document has already expired")
+		}
+		expiresAt = &expiry
+	}
+
 	// Retrieve both files from the request
 	idCardFile, err := c.FormFile("valid_id") // Expecting 'id_card' field in the request
 	if err != nil {
@@ -51,40 +74,61 @@ func UploadIDCardAndSelfie(c *fiber.Ctx) error {
 		return serverErrorResponse("Failed to read Back ID file", err)
 	}
 
-	// Check if the user already has a verification record
-	var verification users.UserVerification
-	if err := db.Where("user_id = ?", claims.UserId).First(&verification).Error; err != nil {
-		// Create a new record if it doesn't exist
-		verification = users.UserVerification{
-			UserId:      claims.UserId,
-			ValidId:     idCardBytes,
-			Selfie:      selfieBytes,
-			BackId:      backIdByte,
-			Status:      "pending",
-			SubmittedAt: time.Now(),
+	// Every upload is a new submission so earlier decisions stay on record. A submission still
+	// waiting for review is superseded by the new one.
+	var latest users.UserVerification
+	if err := db.Omit("valid_id", "selfie", "back_id").
+		Where("user_id = ?", claims.UserId).
+		Order("verification_id DESC").
+		First(&latest).Error; err == nil && latest.Status == users.VerificationStatusApproved {
+		// A verified user may only upload a replacement when their document is about to lapse
+		if latest.ExpiresAt == nil || time.Until(*latest.ExpiresAt) > controller.VerificationRenewalWindow {
+			return fiber.NewError(fiber.StatusConflict, "Your ID is already verified")
 		}
-		if err := db.Create(&verification).Error; err != nil {
-			return serverErrorResponse("Failed to create verification record", err)
+	}
+
+	verification := users.UserVerification{
+		UserId:       claims.UserId,
+		ValidId:      idCardBytes,
+		Selfie:       selfieBytes,
+		BackId:       backIdByte,
+		Status:       users.VerificationStatusPending,
+		DocumentType: documentType,
+		ExpiresAt:    expiresAt,
+		SubmittedAt:  time.Now(),
+	}
+	if err := controller.SealVerificationDocuments(&verification); err != nil {
+		return serverErrorResponse("Failed to encrypt documents", err)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&users.UserVerification{}).
+			Where("user_id = ? AND status = ?", claims.UserId, users.VerificationStatusPending).
+			Update("status", users.VerificationStatusSuperseded).Error; err != nil {
+			return err
 		}
-	} else {
-		// Update the existing record if it exists
-		verification.ValidId = idCardBytes
-		verification.Selfie = selfieBytes
-		verification.SubmittedAt = time.Now()
-		if err := db.Save(&verification).Error; err != nil {
-			return serverErrorResponse("Failed to update verification record", err)
+		if err := tx.Create(&verification).Error; err != nil {
+			return err
 		}
+		// A renewal keeps the current approval (and status) until it is reviewed
+		return tx.Model(&users.Repairman{}).
+			Where("user_id = ? AND verification_status IS DISTINCT FROM ?", claims.UserId, users.VerificationStatusApproved).
+			Update("verification_status", users.VerificationStatusPending).Error
+	})
+	if err != nil {
+		return serverErrorResponse("Failed to create verification record", err)
 	}
 
-	// Encode the uploaded ID card and selfie to base64 for response
+	// Echo back what was uploaded; the stored copies are encrypted
 	encodedIDCard := base64.StdEncoding.EncodeToString(idCardBytes)
 	encodedSelfie := base64.StdEncoding.EncodeToString(selfieBytes)
 
 	// Prepare response
 	response := fiber.Map{
-		"message": "ID card and selfie uploaded successfully",
-		"id_card": encodedIDCard,
-		"selfie":  encodedSelfie,
+		"message":         "ID card and selfie uploaded successfully",
+		"verification_id": verification.VerificationId,
+		"status":          verification.Status,
+		"id_card":         encodedIDCard,
+		"selfie":          encodedSelfie,
 	}
 
 	return c.JSON(response)
@@ -99,18 +143,22 @@ func GetUploadedDocuments(c *fiber.Ctx) error {
 		return unauthorizedResponse()
 	}
 
-	// Retrieve the user's verification record
+	// Retrieve the user's latest verification record
 	var verification users.UserVerification
-	if err := db.Where("user_id = ?", claims.UserId).First(&verification).Error; err != nil {
+	if err := db.Where("user_id = ?", claims.UserId).Order("verification_id DESC").First(&verification).Error; err != nil {
 		return notFoundResponse("No verification data found", err)
 	}
 
 	// Check if the ID card or selfie is available
-	var response fiber.Map
+	response := fiber.Map{}
 	if len(verification.ValidId) == 0 && len(verification.Selfie) == 0 {
 		return successResponse("No ID card or selfie with ID uploaded yet", nil)
 	}
 
+	if err := controller.OpenVerificationDocuments(c, db, &verification, claims); err != nil {
+		return serverErrorResponse("Failed to open documents", err)
+	}
+
 	// Prepare base64-encoded data for both ID card and selfie
 	if len(verification.ValidId) > 0 {
 		encodedIDCard := base64.StdEncoding.EncodeToString(verification.ValidId)
@@ -146,6 +194,9 @@ func successResponse(message string, data interface{}) error {
 	return fiber.NewError(fiber.StatusOK, message)
 }
 
+// DeleteVerificationIfRejected used to delete a rejected submission so a new one could be
+// uploaded. Uploading is now always allowed after a rejection and submissions are kept as review
+// history, so this only confirms that the latest submission was rejected.
 func DeleteVerificationIfRejected(c *fiber.Ctx) error {
 	db := middleware.DBConn
 	claims, ok := c.Locals("user").(*users.Claims)
@@ -154,21 +205,18 @@ func DeleteVerificationIfRejected(c *fiber.Ctx) error {
 	}
 
 	var verification users.UserVerification
-	if err := db.Where("user_id = ?", claims.UserId).First(&verification).Error; err != nil {
+	if err := db.Omit("valid_id", "selfie", "back_id").
+		Where("user_id = ?", claims.UserId).
+		Order("verification_id DESC").
+		First(&verification).Error; err != nil {
 		return notFoundResponse("Verification record not found", err)
 	}
 
-	// Only delete if status is "rejected"
-	if verification.Status != "rejected" {
-		return badRequestResponse("Verification status is not rejected", nil)
-	}
-
-	// ✅ SAFE DELETE with WHERE clause
-	if err := db.Where("user_id = ?", claims.UserId).Delete(&users.UserVerification{}).Error; err != nil {
-		return serverErrorResponse("Failed to delete verification record", err)
+	if verification.Status != users.VerificationStatusRejected {
+		return fiber.NewError(fiber.StatusBadRequest, "Verification status is not rejected")
 	}
 
 	return c.JSON(fiber.Map{
-		"message": "Rejected verification record deleted successfully",
+		"message": "Rejected verification kept for history. You can upload new documents",
 	})
 }
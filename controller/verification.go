@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"fixify_backend/middleware"
+	"fixify_backend/model/users"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repairman verification modes, set per deployment with REPAIRMAN_VERIFICATION_MODE:
+//   - strict: only repairmen with an approved ID are listed and can receive requests (default)
+//   - grace:  new repairmen are also allowed for REPAIRMAN_VERIFICATION_GRACE_DAYS (14 by
+//     default) after signing up, so they can start working while their ID is reviewed
+//   - off:    nobody is hidden; responses still carry the verified badge
+const (
+	VerificationModeStrict = "strict"
+	VerificationModeGrace  = "grace"
+	VerificationModeOff    = "off"
+)
+
+const defaultVerificationGraceDays = 14
+
+// VerificationRenewalWindow is how long before a document lapses the user is reminded and may
+// upload a replacement while still verified
+const VerificationRenewalWindow = 30 * 24 * time.Hour
+
+// verifiedRepairmanSQL matches users rows that have an approved ID submission whose document has
+// not lapsed. The expiry job also marks lapsed submissions expired, this just doesn't wait for it.
+const verifiedRepairmanSQL = "EXISTS (SELECT 1 FROM user_verifications uv WHERE uv.user_id = users.user_id" +
+	" AND uv.status = '" + users.VerificationStatusApproved + "' AND (uv.expires_at IS NULL OR uv.expires_at >= CURRENT_DATE))"
+
+// RepairmanVerificationMode returns the configured mode, falling back to strict
+func RepairmanVerificationMode() string {
+	switch mode := middleware.GetEnv("REPAIRMAN_VERIFICATION_MODE"); mode {
+	case VerificationModeGrace, VerificationModeOff:
+		return mode
+	}
+	return VerificationModeStrict
+}
+
+func verificationGracePeriod() time.Duration {
+	days, err := strconv.Atoi(middleware.GetEnv("REPAIRMAN_VERIFICATION_GRACE_DAYS"))
+	if err != nil || days < 0 {
+		days = defaultVerificationGraceDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ScopeBookableRepairmen restricts a query on the users table to the repairmen that may be listed
+// and receive requests under the current mode. Banned and suspended repairmen are always left
+// out. Use with db.Scopes.
+func ScopeBookableRepairmen(db *gorm.DB) *gorm.DB {
+	db = ScopeUsableAccounts(db)
+	switch RepairmanVerificationMode() {
+	case VerificationModeOff:
+		return db
+	case VerificationModeGrace:
+		return db.Where("("+verifiedRepairmanSQL+" OR users.createdat > ?)", time.Now().Add(-verificationGracePeriod()))
+	}
+	return db.Where(verifiedRepairmanSQL)
+}
+
+// IsRepairmanBookable reports whether a repairman may receive new service requests
+func IsRepairmanBookable(db *gorm.DB, repairmanId uint) (bool, error) {
+	var count int64
+	err := db.Model(&users.Repairman{}).
+		Scopes(ScopeBookableRepairmen).
+		Where("users.user_id = ?", repairmanId).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// VerifiedSelect is a select expression that adds the verified badge as is_verified
+const VerifiedSelect = verifiedRepairmanSQL + " AS is_verified"
+
+// MarkVerifiedRepairmen fills IsVerified on loaded repairmen
+func MarkVerifiedRepairmen(db *gorm.DB, repairmen []users.Repairman) error {
+	if len(repairmen) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(repairmen))
+	for _, r := range repairmen {
+		ids = append(ids, r.UserId)
+	}
+
+	var verified []uint
+	if err := db.Model(&users.UserVerification{}).
+		Where("user_id IN ? AND status = ?", ids, users.VerificationStatusApproved).
+		Where("expires_at IS NULL OR expires_at >= CURRENT_DATE").
+		Distinct().
+		Pluck("user_id", &verified).Error; err != nil {
+		return err
+	}
+
+	verifiedSet := make(map[uint]bool, len(verified))
+	for _, id := range verified {
+		verifiedSet[id] = true
+	}
+	for i := range repairmen {
+		repairmen[i].IsVerified = verifiedSet[repairmen[i].UserId]
+	}
+	return nil
+}
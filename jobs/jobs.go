@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"log"
+	"time"
+)
+
+// Every runs job once right away and then every interval until the process exits. A failed run is
+// logged and simply tried again on the next tick.
+func Every(name string, interval time.Duration, job func() error) {
+	go func() {
+		run := func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Job %s panicked: %v", name, r)
+				}
+			}()
+			if err := job(); err != nil {
+				log.Printf("Job %s failed: %v", name, err)
+			}
+		}
+
+		run()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			run()
+		}
+	}()
+}
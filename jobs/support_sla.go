@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"fixify_backend/mailer"
+	"fixify_backend/model/users"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StartSupportSLAJob looks for support tickets that missed their SLA every interval
+func StartSupportSLAJob(db *gorm.DB, interval time.Duration) {
+	Every("support SLA", interval, func() error {
+		return CheckTicketSLAs(db, time.Now())
+	})
+}
+
+// CheckTicketSLAs flags open and pending tickets that passed their first-response or resolution
+// due time and emails the assigned admin, or the whole support team when nobody is assigned.
+// Each breach is flagged and reported once.
+func CheckTicketSLAs(db *gorm.DB, now time.Time) error {
+	active := []string{users.TicketStatusOpen, users.TicketStatusPending}
+	checks := []struct {
+		flag  string
+		where string
+		label string
+	}{
+		{"first_response_breached", "first_responded_at IS NULL AND first_response_due_at <= ?", "first reply"},
+		{"resolution_breached", "resolved_at IS NULL AND resolution_due_at <= ?", "resolution"},
+	}
+
+	for _, check := range checks {
+		var breached []users.SupportTicket
+		if err := db.Where("status IN ? AND "+check.flag+" = ?", active, false).
+			Where(check.where, now).
+			Find(&breached).Error; err != nil {
+			return err
+		}
+
+		for _, ticket := range breached {
+			result := db.Model(&users.SupportTicket{}).
+				Where("ticket_id = ? AND "+check.flag+" = ?", ticket.TicketId, false).
+				Update(check.flag, true)
+			if result.Error != nil {
+				log.Printf("Failed to flag SLA breach of ticket %d: %v", ticket.TicketId, result.Error)
+				continue
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			log.Printf("Support ticket %d missed its %s SLA", ticket.TicketId, check.label)
+			alertSupportTeam(db, ticket, check.label)
+		}
+	}
+	return nil
+}
+
+func alertSupportTeam(db *gorm.DB, ticket users.SupportTicket, missed string) {
+	query := db.Select("admin_id, email, admin_role").Where("disabled_at IS NULL")
+	if ticket.AssignedTo != nil {
+		query = query.Where("admin_id = ?", *ticket.AssignedTo)
+	}
+	var admins []users.Admin
+	if err := query.Find(&admins).Error; err != nil {
+		log.Printf("Failed to load admins for ticket %d SLA alert: %v", ticket.TicketId, err)
+		return
+	}
+
+	for _, admin := range admins {
+		if admin.Email == "" || !users.AdminRoleHas(admin.AdminRole, users.PermissionSupport) {
+			continue
+		}
+		if err := mailer.SendTemplate(db, admin.Email, mailer.TemplateNotification, mailer.NotificationData{
+			Title: fmt.Sprintf("Support ticket #%d missed its %s target", ticket.TicketId, missed),
+			Body:  fmt.Sprintf("%q (%s priority) is overdue for its %s.", ticket.Subject, ticket.Priority, missed),
+		}); err != nil {
+			log.Printf("Failed to email admin %d about ticket %d: %v", admin.AdminId, ticket.TicketId, err)
+		}
+	}
+}
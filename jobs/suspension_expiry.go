@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StartSuspensionExpiryJob lifts suspensions that have run out every interval
+func StartSuspensionExpiryJob(db *gorm.DB, interval time.Duration) {
+	Every("suspension expiry", interval, func() error {
+		return LiftExpiredSuspensions(db, time.Now())
+	})
+}
+
+// LiftExpiredSuspensions sets suspended accounts whose suspension has ended back to active and
+// lets the user know. Logins already work once the end passes; this tidies up the status.
+func LiftExpiredSuspensions(db *gorm.DB, now time.Time) error {
+	var ended []users.User
+	if err := db.Select("user_id").
+		Where("account_status = ? AND suspended_until <= ?", users.AccountStatusSuspended, now).
+		Find(&ended).Error; err != nil {
+		return err
+	}
+
+	for _, user := range ended {
+		result := db.Model(&users.User{}).
+			Where("user_id = ? AND account_status = ? AND suspended_until <= ?", user.UserId, users.AccountStatusSuspended, now).
+			Updates(map[string]interface{}{
+				"account_status":  users.AccountStatusActive,
+				"suspended_until": nil,
+				"status_reason":   "",
+			})
+		if result.Error != nil {
+			log.Printf("Failed to lift suspension of user %d: %v", user.UserId, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		log.Printf("Suspension of user %d ended", user.UserId)
+		if err := websocketclient.Notify(db, websocketclient.Notification{
+			Category: users.NotificationCategoryAccount,
+			Type:     "Account Status",
+			ToUser:   user.UserId,
+			Title:    "Your suspension has ended",
+			Body:     "Your Fixify account is active again. Welcome back!",
+			Data: map[string]string{
+				"account_status": users.AccountStatusActive,
+			},
+		}); err != nil {
+			log.Printf("Failed to notify user %d about the end of their suspension: %v", user.UserId, err)
+		}
+	}
+	return nil
+}
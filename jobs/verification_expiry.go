@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"fixify_backend/controller"
+	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Reminders go out when an approved document is this close to its expiry date, shortest first
+var expiryReminderLeads = []time.Duration{7 * 24 * time.Hour, controller.VerificationRenewalWindow}
+
+// StartVerificationExpiryJob checks ID expiry dates every interval
+func StartVerificationExpiryJob(db *gorm.DB, interval time.Duration) {
+	Every("verification expiry", interval, func() error {
+		return RunVerificationExpiry(db, time.Now())
+	})
+}
+
+// RunVerificationExpiry marks approved verifications whose document has lapsed as expired, which
+// stops the repairman from receiving new requests, and reminds repairmen whose document is about
+// to lapse to upload a new one.
+func RunVerificationExpiry(db *gorm.DB, now time.Time) error {
+	if err := expireLapsedVerifications(db, now); err != nil {
+		return err
+	}
+	return sendExpiryReminders(db, now)
+}
+
+func expireLapsedVerifications(db *gorm.DB, now time.Time) error {
+	var lapsed []users.UserVerification
+	if err := db.Omit("valid_id", "selfie", "back_id").
+		Where("status = ? AND expires_at < ?", users.VerificationStatusApproved, now.Format("2006-01-02")).
+		Find(&lapsed).Error; err != nil {
+		return err
+	}
+
+	for _, v := range lapsed {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&users.UserVerification{}).
+				Where("verification_id = ? AND status = ?", v.VerificationId, users.VerificationStatusApproved).
+				Update("status", users.VerificationStatusExpired).Error; err != nil {
+				return err
+			}
+			return tx.Model(&users.Repairman{}).Where("user_id = ?", v.UserId).
+				Update("verification_status", users.VerificationStatusExpired).Error
+		})
+		if err != nil {
+			log.Printf("Failed to expire verification %d: %v", v.VerificationId, err)
+			continue
+		}
+
+		log.Printf("Verification %d of user %d expired", v.VerificationId, v.UserId)
+		notifyVerification(db, v, "Your ID has expired",
+			"Your ID document has expired. You won't receive new service requests until you upload a valid ID.")
+	}
+	return nil
+}
+
+func sendExpiryReminders(db *gorm.DB, now time.Time) error {
+	today := now.Format("2006-01-02")
+	horizon := now.Add(controller.VerificationRenewalWindow).Format("2006-01-02")
+
+	var expiring []users.UserVerification
+	if err := db.Omit("valid_id", "selfie", "back_id").
+		Joins("JOIN users ON users.user_id = user_verifications.user_id").
+		Where("users.type = ?", "Repairman").
+		Where("user_verifications.status = ? AND user_verifications.expires_at BETWEEN ? AND ?", users.VerificationStatusApproved, today, horizon).
+		Find(&expiring).Error; err != nil {
+		return err
+	}
+
+	for _, v := range expiring {
+		if !reminderDue(v, now) {
+			continue
+		}
+
+		// A replacement already waiting for review makes the reminder pointless
+		var pending int64
+		db.Model(&users.UserVerification{}).
+			Where("user_id = ? AND status = ?", v.UserId, users.VerificationStatusPending).
+			Count(&pending)
+		if pending == 0 {
+			expires := v.ExpiresAt.Format("January 2, 2006")
+			notifyVerification(db, v, "Your ID is about to expire",
+				fmt.Sprintf("Your ID document expires on %s. Upload a new one before then to keep receiving service requests.", expires))
+		}
+
+		if err := db.Model(&users.UserVerification{}).
+			Where("verification_id = ?", v.VerificationId).
+			Update("reminder_sent_at", now).Error; err != nil {
+			log.Printf("Failed to record expiry reminder for verification %d: %v", v.VerificationId, err)
+		}
+	}
+	return nil
+}
+
+// reminderDue reports whether a reminder threshold has been crossed since the last reminder
+func reminderDue(v users.UserVerification, now time.Time) bool {
+	if v.ExpiresAt == nil {
+		return false
+	}
+	for _, lead := range expiryReminderLeads {
+		threshold := v.ExpiresAt.Add(-lead)
+		if !now.Before(threshold) {
+			return v.ReminderSentAt == nil || v.ReminderSentAt.Before(threshold)
+		}
+	}
+	return false
+}
+
+func notifyVerification(db *gorm.DB, v users.UserVerification, title string, body string) {
+	if err := websocketclient.Notify(db, websocketclient.Notification{
+		Category: users.NotificationCategoryAccount,
+		Type:     "Account Verification",
+		ToUser:   v.UserId,
+		Title:    title,
+		Body:     body,
+		Data: map[string]string{
+			"verification_id": strconv.Itoa(int(v.VerificationId)),
+		},
+	}); err != nil {
+		log.Printf("Failed to notify user %d about verification %d: %v", v.UserId, v.VerificationId, err)
+	}
+}
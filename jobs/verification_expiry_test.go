@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"fixify_backend/model/users"
+)
+
+func TestReminderDue(t *testing.T) {
+	expires := time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	at := func(daysBefore int) *time.Time {
+		t := expires.Add(-time.Duration(daysBefore) * day)
+		return &t
+	}
+
+	tests := []struct {
+		name         string
+		expiresAt    *time.Time
+		reminderSent *time.Time
+		now          time.Time
+		want         bool
+	}{
+		{"no expiry date", nil, nil, expires, false},
+		{"long before expiry", &expires, nil, *at(45), false},
+		{"30 days left, never reminded", &expires, nil, *at(30), true},
+		{"20 days left, reminded at 30", &expires, at(30), *at(20), false},
+		{"7 days left, reminded at 30", &expires, at(30), *at(7), true},
+		{"5 days left, reminded at 7", &expires, at(7), *at(5), false},
+		{"5 days left, reminded long ago", &expires, at(60), *at(5), true},
+		{"already expired, reminded at 7", &expires, at(7), expires.Add(day), false},
+	}
+	for _, tt := range tests {
+		v := users.UserVerification{ExpiresAt: tt.expiresAt, ReminderSentAt: tt.reminderSent}
+		if got := reminderDue(v, tt.now); got != tt.want {
+			t.Errorf("%s: reminderDue = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
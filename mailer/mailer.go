@@ -0,0 +1,204 @@
+package mailer
+
+import (
+	"fixify_backend/middleware"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/gomail.v2"
+)
+
+// Mail providers selectable with MAIL_PROVIDER
+const (
+	ProviderSMTP   = "smtp"
+	ProviderFile   = "file"
+	ProviderMemory = "memory"
+)
+
+// Message is a rendered email ready to send
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Mailer delivers rendered emails. SMTP is used in production; the file and memory mailers let
+// the server run without a mail account and let tests inspect what was sent.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// SMTPMailer sends through an SMTP account (Gmail app password by default)
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// NewSMTPMailerFromEnv reads FROM, APPASS, SMTPHOST and SMTPPORT
+func NewSMTPMailerFromEnv() (*SMTPMailer, error) {
+	port, err := strconv.Atoi(middleware.GetEnv("SMTPPORT"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTPPORT %q: %v", middleware.GetEnv("SMTPPORT"), err)
+	}
+	if middleware.GetEnv("SMTPHOST") == "" || middleware.GetEnv("FROM") == "" {
+		return nil, fmt.Errorf("SMTPHOST and FROM must be set")
+	}
+
+	return &SMTPMailer{
+		Host:     middleware.GetEnv("SMTPHOST"),
+		Port:     port,
+		Username: middleware.GetEnv("FROM"),
+		Password: middleware.GetEnv("APPASS"),
+		From:     middleware.GetEnv("FROM"),
+	}, nil
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	message := gomail.NewMessage()
+	message.SetHeader("From", m.From)
+	message.SetHeader("To", msg.To)
+	message.SetHeader("Subject", msg.Subject)
+	message.SetBody("text/plain", msg.Text)
+	if msg.HTML != "" {
+		message.AddAlternative("text/html", msg.HTML)
+	}
+
+	dialer := gomail.NewDialer(m.Host, m.Port, m.Username, m.Password)
+	return dialer.DialAndSend(message)
+}
+
+// FileMailer writes each email to a file in Dir instead of sending it, for local development
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s_%s.eml", time.Now().Format("20060102T150405.000000000"), sanitizeFileName(msg.To))
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n\n--- HTML ---\n%s\n", msg.To, msg.Subject, msg.Text, msg.HTML)
+	return os.WriteFile(filepath.Join(m.Dir, name), []byte(content), 0o644)
+}
+
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == ':' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// MemoryMailer keeps every email in memory so tests can assert on what was sent
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
+
+// Reset forgets sent messages
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	m.messages = nil
+	m.mu.Unlock()
+}
+
+// unavailableMailer fails every send so emails stay in the outbox until the config is fixed
+type unavailableMailer struct {
+	err error
+}
+
+func (m unavailableMailer) Send(msg Message) error {
+	return m.err
+}
+
+var (
+	current     Mailer
+	currentOnce sync.Once
+	currentMu   sync.RWMutex
+)
+
+// Default returns the mailer selected by MAIL_PROVIDER (smtp, file or memory). Without it, SMTP
+// is used when SMTPHOST is set and emails are written to MAIL_DIR (tmp/mail) otherwise.
+// A broken SMTP configuration is logged and every send fails, which leaves emails queued.
+func Default() Mailer {
+	currentOnce.Do(func() {
+		currentMu.Lock()
+		defer currentMu.Unlock()
+		if current == nil {
+			current = fromEnv()
+		}
+	})
+
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// SetDefault replaces the mailer used by the outbox, e.g. with a MemoryMailer in tests
+func SetDefault(m Mailer) {
+	currentMu.Lock()
+	current = m
+	currentMu.Unlock()
+}
+
+func fromEnv() Mailer {
+	provider := middleware.GetEnv("MAIL_PROVIDER")
+	if provider == "" {
+		provider = ProviderFile
+		if middleware.GetEnv("SMTPHOST") != "" {
+			provider = ProviderSMTP
+		}
+	}
+
+	switch provider {
+	case ProviderSMTP:
+		m, err := NewSMTPMailerFromEnv()
+		if err != nil {
+			log.Printf("Mailer: SMTP not configured, emails will stay queued: %v", err)
+			return unavailableMailer{err: err}
+		}
+		return m
+	case ProviderMemory:
+		return NewMemoryMailer()
+	case ProviderFile:
+		dir := middleware.GetEnv("MAIL_DIR")
+		if dir == "" {
+			dir = filepath.Join("tmp", "mail")
+		}
+		return &FileMailer{Dir: dir}
+	}
+
+	err := fmt.Errorf("unknown MAIL_PROVIDER %q", provider)
+	log.Printf("Mailer: %v", err)
+	return unavailableMailer{err: err}
+}
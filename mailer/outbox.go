@@ -0,0 +1,149 @@
+package mailer
+
+import (
+	"fixify_backend/model/users"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxAttempts   = 5
+	claimDuration = 2 * time.Minute // How long a worker owns a row while delivering it
+	outboxBatch   = 50
+
+	// Sent and failed rows are kept this long for troubleshooting, without their bodies
+	outboxRetention = 30 * 24 * time.Hour
+)
+
+// SendTemplate renders a template and queues it for delivery. Delivery is attempted right away in
+// the background; failures are retried by the outbox worker, so callers only see errors for bad
+// templates or a failing database.
+func SendTemplate(db *gorm.DB, to string, name string, data interface{}) error {
+	msg, err := Render(name, to, data)
+	if err != nil {
+		return err
+	}
+	return Enqueue(db, name, msg)
+}
+
+// SendUnstored renders a template and delivers it right away without writing it to the outbox, for
+// mail whose recipient must not be kept, like the goodbye email to a deleted account. It is not
+// retried.
+func SendUnstored(to string, name string, data interface{}) error {
+	msg, err := Render(name, to, data)
+	if err != nil {
+		return err
+	}
+	return Default().Send(msg)
+}
+
+// Enqueue stores a rendered message in the outbox and starts delivering it
+func Enqueue(db *gorm.DB, templateName string, msg Message) error {
+	entry := users.EmailOutbox{
+		Recipient: msg.To,
+		Subject:   msg.Subject,
+		Template:  templateName,
+		HTMLBody:  msg.HTML,
+		TextBody:  msg.Text,
+		Status:    users.EmailStatusPending,
+		// Keep the worker away while the first attempt below is in flight
+		NextAttemptAt: time.Now().Add(claimDuration),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		return err
+	}
+
+	go deliver(db, entry)
+	return nil
+}
+
+// StartOutboxWorker retries queued emails every interval until the process exits
+func StartOutboxWorker(db *gorm.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ProcessOutbox(db)
+		}
+	}()
+}
+
+// ProcessOutbox delivers every pending email whose retry time has come and purges old ones
+func ProcessOutbox(db *gorm.DB) {
+	purgeOutbox(db, time.Now())
+
+	var due []users.EmailOutbox
+	if err := db.Where("status = ? AND next_attempt_at <= ?", users.EmailStatusPending, time.Now()).
+		Order("next_attempt_at ASC").
+		Limit(outboxBatch).
+		Find(&due).Error; err != nil {
+		log.Printf("Mailer: failed to load outbox: %v", err)
+		return
+	}
+
+	for _, entry := range due {
+		// Claim the row so another instance (or a slow previous tick) doesn't send it twice
+		claim := db.Model(&users.EmailOutbox{}).
+			Where("outbox_id = ? AND status = ? AND next_attempt_at = ?", entry.OutboxId, users.EmailStatusPending, entry.NextAttemptAt).
+			Update("next_attempt_at", time.Now().Add(claimDuration))
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			continue
+		}
+		deliver(db, entry)
+	}
+}
+
+func deliver(db *gorm.DB, entry users.EmailOutbox) {
+	err := Default().Send(Message{
+		To:      entry.Recipient,
+		Subject: entry.Subject,
+		HTML:    entry.HTMLBody,
+		Text:    entry.TextBody,
+	})
+
+	attempts := entry.Attempts + 1
+	updates := map[string]interface{}{"attempts": attempts}
+
+	if err == nil {
+		now := time.Now()
+		updates["status"] = users.EmailStatusSent
+		updates["sent_at"] = &now
+		updates["last_error"] = ""
+		clearBodies(updates)
+	} else {
+		log.Printf("Mailer: delivery of email %d to %s failed (attempt %d): %v", entry.OutboxId, entry.Recipient, attempts, err)
+		updates["last_error"] = err.Error()
+		if attempts >= maxAttempts {
+			updates["status"] = users.EmailStatusFailed
+			clearBodies(updates)
+		} else {
+			// 1, 4, 9, 16 minutes
+			updates["next_attempt_at"] = time.Now().Add(time.Duration(attempts*attempts) * time.Minute)
+		}
+	}
+
+	if err := db.Model(&users.EmailOutbox{}).Where("outbox_id = ?", entry.OutboxId).Updates(updates).Error; err != nil {
+		log.Printf("Mailer: failed to update outbox entry %d: %v", entry.OutboxId, err)
+	}
+}
+
+// clearBodies blanks the rendered message once it won't be sent again. Bodies carry one-time codes,
+// reset links and invite tokens, which must not outlive delivery in the database.
+func clearBodies(updates map[string]interface{}) {
+	updates["html_body"] = ""
+	updates["text_body"] = ""
+}
+
+// purgeOutbox deletes sent and failed emails older than outboxRetention
+func purgeOutbox(db *gorm.DB, now time.Time) {
+	result := db.Where("status IN ? AND created_at < ?", []string{users.EmailStatusSent, users.EmailStatusFailed}, now.Add(-outboxRetention)).
+		Delete(&users.EmailOutbox{})
+	if result.Error != nil {
+		log.Printf("Mailer: failed to purge outbox: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Mailer: purged %d old outbox entries", result.RowsAffected)
+	}
+}
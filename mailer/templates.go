@@ -0,0 +1,108 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fixify_backend/middleware"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+)
+
+//go:embed templates/*
+var templateFS embed.FS
+
+// Template names. Each has templates/<name>.html (rendered inside layout.html) and
+// templates/<name>.txt; both define a "subject" block.
+const (
+	TemplateVerification    = "verification"
+	TemplatePasswordReset   = "password_reset"
+	TemplateRequestAccepted = "request_accepted"
+	TemplateReceipt         = "receipt"
+	TemplateNotification    = "notification"
+	TemplateAdminInvite     = "admin_invite"
+)
+
+type VerificationData struct {
+	Code             string
+	ExpiresInMinutes int
+}
+
+// PasswordResetData carries a code, a link, or both
+type PasswordResetData struct {
+	Code             string
+	Link             string
+	ExpiresInMinutes int
+}
+
+type RequestAcceptedData struct {
+	ClientName    string
+	RepairmanName string
+	RequestId     int
+	Category      string
+}
+
+type ReceiptData struct {
+	Reference string
+	Amount    float64
+	Currency  string
+	Method    string
+	Date      time.Time
+	Status    string
+}
+
+// AdminInviteData carries the invite token, and a link when ADMIN_INVITE_URL is set
+type AdminInviteData struct {
+	InvitedBy     string
+	Role          string
+	Token         string
+	Link          string
+	ExpiresInDays int
+}
+
+// NotificationData is used for generic notification emails sent by the notification dispatcher
+type NotificationData struct {
+	Title string
+	Body  string
+}
+
+var templateFuncs = map[string]interface{}{
+	"appName": func() string {
+		if name := middleware.GetEnv("PROJ_NAME"); name != "" {
+			return name
+		}
+		return "Fixify"
+	},
+}
+
+// Render builds the subject, HTML and text bodies of a template for one recipient
+func Render(name string, to string, data interface{}) (Message, error) {
+	text, err := template.New(name+".txt").Funcs(templateFuncs).ParseFS(templateFS, "templates/"+name+".txt")
+	if err != nil {
+		return Message{}, fmt.Errorf("unknown email template %q: %v", name, err)
+	}
+	html, err := htmltemplate.New("layout.html").Funcs(templateFuncs).ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html")
+	if err != nil {
+		return Message{}, fmt.Errorf("unknown email template %q: %v", name, err)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := text.Execute(&textBody, data); err != nil {
+		return Message{}, err
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(textBody.String()) + "\n",
+		HTML:    htmlBody.String(),
+	}, nil
+}
@@ -1,13 +1,19 @@
 package main
 
 import (
+	"fixify_backend/jobs"
+	"fixify_backend/mailer"
 	"fixify_backend/middleware"
+	"fixify_backend/migrations"
 	"fixify_backend/routes"
+	"fixify_backend/vault"
 	"fixify_backend/websocketclient" // Make sure this is the correct package
 	"context"
 	"encoding/json"
 	"fmt"
 	"log" // For error logging
+	"strings"
+	"time"
 
 	"firebase.google.com/go/v4"
 	"github.com/gofiber/fiber/v2"
@@ -27,10 +33,27 @@ func init() {
 		fmt.Println("DB CONNECTION SUCCESSFUL!")
 	}
 
-	// Initialize FCM using credentials from .env
-	fmt.Println("INITIALIZING FCM...")
-	db := middleware.GetDB()
+	// Bring the schema up to date before anything queries it
+	if err := migrations.Run(middleware.GetDB()); err != nil {
+		log.Fatalf("Database migration failed: %v", err)
+	}
+
+	// ID documents are encrypted at rest, so refuse to start without keys
+	if _, err := vault.Default(); err != nil {
+		log.Fatalf("Document encryption is not configured (DOCUMENT_KEYS): %v", err)
+	}
+
+	// Push notifications: PUSH_PROVIDER is fcm, noop or recording. Without it, FCM is used
+	// when Firebase credentials are configured and pushes are dropped otherwise.
+	sender, err := websocketclient.ConfigurePush(middleware.GetDB(), middleware.GetEnv("PUSH_PROVIDER"), newFirebaseApp)
+	if err != nil {
+		log.Fatalf("Failed to initialize push notifications: %v", err)
+	}
+	log.Printf("Push notifications ready (%T)", sender)
+}
 
+// newFirebaseApp builds the Firebase app from the FIREBASE_* credentials in .env
+func newFirebaseApp() (*firebase.App, error) {
 	// Construct Firebase credentials from environment variables
 	cred := &struct {
 		Type                    string `json:"type"`
@@ -61,7 +84,7 @@ func init() {
 	// Marshal the credentials to JSON to properly escape the private key
 	credJSON, err := json.Marshal(cred)
 	if err != nil {
-		log.Fatalf("Failed to marshal Firebase credentials: %v", err)
+		return nil, fmt.Errorf("failed to marshal Firebase credentials: %v", err)
 	}
 
 	// Initialize Firebase app with credentials
@@ -70,24 +93,36 @@ func init() {
 		ProjectID: cred.ProjectID,
 	}
 	opt := option.WithCredentialsJSON(credJSON)
-	app, err := firebase.NewApp(ctx, firebaseConfig, opt)
-	if err != nil {
-		log.Fatalf("Failed to initialize Firebase app: %v", err)
-	}
-
-	// Pass the Firebase app to InitializeFCM
-	err = websocketclient.InitializeFCM(db, app)
-	if err != nil {
-		log.Fatalf("Failed to initialize FCM: %v", err)
-	}
-
-	fmt.Println("FCM INITIALIZED SUCCESSFULLY!")
+	return firebase.NewApp(ctx, firebaseConfig, opt)
 }
 
 func main() {
-	app := fiber.New(fiber.Config{
-		AppName: middleware.GetEnv("PROJ_NAME"),
-	})
+	// Retry emails that could not be delivered right away
+	mailer.StartOutboxWorker(middleware.GetDB(), time.Minute)
+	// Expire lapsed ID documents and remind repairmen before theirs lapse
+	jobs.StartVerificationExpiryJob(middleware.GetDB(), time.Hour)
+	// Reactivate accounts whose suspension has ended
+	jobs.StartSuspensionExpiryJob(middleware.GetDB(), 10*time.Minute)
+	// Flag support tickets that missed their SLA and alert the support team
+	jobs.StartSupportSLAJob(middleware.GetDB(), 5*time.Minute)
+
+	config := fiber.Config{
+		AppName:   middleware.GetEnv("PROJ_NAME"),
+		BodyLimit: 12 * 1024 * 1024, // Room for ID documents and chat attachments
+	}
+	// Behind a reverse proxy every request comes from the proxy's address, which would put all
+	// users in one login throttling bucket. TRUSTED_PROXIES lists the proxies (IPs or CIDRs, comma
+	// separated); only requests from them have the client address read from PROXY_HEADER.
+	if proxies := trustedProxies(); len(proxies) > 0 {
+		config.EnableTrustedProxyCheck = true
+		config.TrustedProxies = proxies
+		config.ProxyHeader = middleware.GetEnv("PROXY_HEADER")
+		if config.ProxyHeader == "" {
+			config.ProxyHeader = "X-Real-IP"
+		}
+		config.EnableIPValidation = true
+	}
+	app := fiber.New(config)
 
 	// CORS CONFIG (before setting routes)
 	app.Use(cors.New(cors.Config{
@@ -112,4 +147,15 @@ func main() {
 	if err != nil {
 		log.Fatal("Error starting server: ", err)
 	}
-}
\ No newline at end of file
+}
+
+// trustedProxies parses TRUSTED_PROXIES
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(middleware.GetEnv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -4,19 +4,23 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
+	"unicode"
 
 	"github.com/joho/godotenv"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func GetEnv(key string) string {
-	err := godotenv.Load(".env")
+var loadEnvOnce sync.Once
 
-	if err != nil {
-		fmt.Println("Error loading .env file")
-		log.Fatalf("Error loading .env file")
-		return err.Error()
-	}
+// GetEnv reads a setting from the environment. The .env file is loaded on first use; when it is
+// missing (containers, CI) the process environment is used as is.
+func GetEnv(key string) string {
+	loadEnvOnce.Do(func() {
+		if err := godotenv.Load(".env"); err != nil {
+			log.Println("No .env file loaded, using process environment")
+		}
+	})
 	return os.Getenv(key)
 }
 
@@ -28,3 +32,31 @@ func HashPassword(password string) (string, error) {
 	}
 	return string(hashedPassword), nil
 }
+
+// ValidatePasswordStrength enforces the password rules for new passwords: 8 to 72 characters
+// (bcrypt ignores anything longer) with at least one lowercase letter, one uppercase letter and
+// one digit.
+func ValidatePasswordStrength(password string) error {
+	if len(password) < 8 {
+		return fmt.Errorf("password must be at least 8 characters long")
+	}
+	if len(password) > 72 {
+		return fmt.Errorf("password must be at most 72 characters long")
+	}
+
+	var hasLower, hasUpper, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLower || !hasUpper || !hasDigit {
+		return fmt.Errorf("password must contain an uppercase letter, a lowercase letter and a number")
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package migrations
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Schema changes live in sql/ as numbered files. Each one runs once, inside a transaction, in
+// name order, and is recorded in schema_migrations. Applied files must never be edited; add a
+// new one instead.
+
+//go:embed sql/*.sql
+var files embed.FS
+
+// Run applies the migrations that have not been applied to the database yet
+func Run(db *gorm.DB) error {
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    varchar(255) PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`).Error; err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	var applied []string
+	if err := db.Table("schema_migrations").Pluck("version", &applied).Error; err != nil {
+		return fmt.Errorf("reading schema_migrations: %w", err)
+	}
+	done := make(map[string]bool, len(applied))
+	for _, version := range applied {
+		done[version] = true
+	}
+
+	names, err := Versions()
+	if err != nil {
+		return err
+	}
+	for _, version := range names {
+		if done[version] {
+			continue
+		}
+		script, err := files.ReadFile("sql/" + version + ".sql")
+		if err != nil {
+			return err
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(string(script)).Error; err != nil {
+				return err
+			}
+			return tx.Exec("INSERT INTO schema_migrations (version) VALUES (?)", version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", version, err)
+		}
+		log.Printf("Applied migration %s", version)
+	}
+	return nil
+}
+
+// Versions lists the migrations shipped with this build, oldest first
+func Versions() ([]string, error) {
+	names, err := fs.Glob(files, "sql/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]string, 0, len(names))
+	for _, name := range names {
+		versions = append(versions, strings.TrimSuffix(path.Base(name), ".sql"))
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
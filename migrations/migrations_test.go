@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestVersionsAreNumberedAndUnique(t *testing.T) {
+	versions, err := Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	named := regexp.MustCompile(`^(\d{4})_[a-z0-9_]+$`)
+	numbers := map[string]string{}
+	for _, version := range versions {
+		match := named.FindStringSubmatch(version)
+		if match == nil {
+			t.Errorf("migration %q must be named NNNN_description.sql", version)
+			continue
+		}
+		if other, ok := numbers[match[1]]; ok {
+			t.Errorf("migrations %q and %q share a number", other, version)
+		}
+		numbers[match[1]] = version
+
+		script, err := files.ReadFile("sql/" + version + ".sql")
+		if err != nil || strings.TrimSpace(string(script)) == "" {
+			t.Errorf("migration %q is empty or unreadable: %v", version, err)
+		}
+		if strings.Contains(string(script), "?") {
+			t.Errorf("migration %q contains '?', which gorm would treat as a placeholder", version)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+-- Tables and columns added on top of the original schema (users, admins, service_requests,
+-- client_repairman_messages, ...), which is expected to exist already. Every statement can run
+-- against a database that was partly updated by hand.
+
+-- Idempotency key the app sends with each chat message
+ALTER TABLE client_repairman_messages ADD COLUMN IF NOT EXISTS client_message_id varchar(64);
+CREATE INDEX IF NOT EXISTS idx_client_repairman_messages_client_message_id
+    ON client_repairman_messages (client_message_id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS chat_attachments (
+    attachment_id bigserial PRIMARY KEY,
+    uploader_id   bigint NOT NULL,
+    file_name     text,
+    content_type  text,
+    size          bigint,
+    data          bytea NOT NULL,
+    created_at    timestamptz
+);
+
+ALTER TABLE client_repairman_messages
+    ADD COLUMN IF NOT EXISTS message_type varchar(20) DEFAULT 'text',
+    ADD COLUMN IF NOT EXISTS attachment_id bigint,
+    ADD COLUMN IF NOT EXISTS latitude double precision,
+    ADD COLUMN IF NOT EXISTS longitude double precision;
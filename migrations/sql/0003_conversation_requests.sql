@@ -0,0 +1,6 @@
+-- Conversations belong to a service request and turn read-only once it is finished
+ALTER TABLE client_repairman_conversations
+    ADD COLUMN IF NOT EXISTS request_id bigint,
+    ADD COLUMN IF NOT EXISTS is_read_only boolean NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS idx_client_repairman_conversations_request_id
+    ON client_repairman_conversations (request_id);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS user_blocks (
+    block_id   bigserial PRIMARY KEY,
+    blocker_id bigint NOT NULL,
+    blocked_id bigint NOT NULL,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_block_pair ON user_blocks (blocker_id, blocked_id);
+
+CREATE TABLE IF NOT EXISTS message_reports (
+    report_id   bigserial PRIMARY KEY,
+    message_id  bigint NOT NULL,
+    reporter_id bigint NOT NULL,
+    reason      text,
+    status      varchar(20) DEFAULT 'pending',
+    reviewed_by bigint,
+    reviewed_at timestamptz,
+    admin_note  text,
+    created_at  timestamptz
+);
@@ -0,0 +1,14 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS quiet_hours_start varchar(5),
+    ADD COLUMN IF NOT EXISTS quiet_hours_end varchar(5);
+
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    preference_id bigserial PRIMARY KEY,
+    user_id       bigint NOT NULL,
+    category      varchar(20) NOT NULL,
+    muted         boolean,
+    push_enabled  boolean,
+    email_enabled boolean,
+    updated_at    timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notification_preference ON notification_preferences (user_id, category);
@@ -0,0 +1 @@
+ALTER TABLE user_notifications ADD COLUMN IF NOT EXISTS is_archived boolean DEFAULT false;
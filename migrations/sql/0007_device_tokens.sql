@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS device_tokens (
+    device_id    bigserial PRIMARY KEY,
+    owner_type   varchar(10) NOT NULL,
+    owner_id     bigint NOT NULL,
+    token        varchar(255) NOT NULL,
+    platform     varchar(20),
+    app_version  varchar(32),
+    last_seen_at timestamptz,
+    created_at   timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_device_owner ON device_tokens (owner_type, owner_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_device_tokens_token ON device_tokens (token);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS email_outbox (
+    outbox_id       bigserial PRIMARY KEY,
+    recipient       varchar(255) NOT NULL,
+    subject         varchar(255),
+    template        varchar(50),
+    html_body       text,
+    text_body       text,
+    status          varchar(10) NOT NULL DEFAULT 'pending',
+    attempts        bigint DEFAULT 0,
+    last_error      text,
+    next_attempt_at timestamptz,
+    sent_at         timestamptz,
+    created_at      timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_email_outbox_due ON email_outbox (status, next_attempt_at);
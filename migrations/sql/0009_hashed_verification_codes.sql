@@ -0,0 +1,12 @@
+-- Verification codes are stored as HMACs. Codes from before that can't be checked any more and
+-- the plaintext must not be kept.
+ALTER TABLE emailver
+    ADD COLUMN IF NOT EXISTS code_hash varchar(64),
+    ADD COLUMN IF NOT EXISTS purpose varchar(20) DEFAULT 'signup',
+    ADD COLUMN IF NOT EXISTS expires_at timestamptz,
+    ADD COLUMN IF NOT EXISTS attempts bigint DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS last_sent_at timestamptz,
+    ADD COLUMN IF NOT EXISTS locked_until timestamptz;
+DELETE FROM emailver WHERE code_hash IS NULL OR code_hash = '';
+ALTER TABLE emailver DROP COLUMN IF EXISTS code;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_emailver_target ON emailver (email, purpose);
@@ -0,0 +1,3 @@
+-- Tokens issued before sessions_valid_after are rejected
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sessions_valid_after timestamptz;
+ALTER TABLE admins ADD COLUMN IF NOT EXISTS sessions_valid_after timestamptz;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS login_attempts (
+    attempt_id      bigserial PRIMARY KEY,
+    scope           varchar(10) NOT NULL,
+    login_key       varchar(255) NOT NULL,
+    failures        bigint DEFAULT 0,
+    last_failure_at timestamptz,
+    last_ip         varchar(64),
+    locked_until    timestamptz
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_login_attempt_key ON login_attempts (scope, login_key);
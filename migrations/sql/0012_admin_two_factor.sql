@@ -0,0 +1,14 @@
+ALTER TABLE admins
+    ADD COLUMN IF NOT EXISTS totp_secret varchar(64),
+    ADD COLUMN IF NOT EXISTS totp_enabled boolean DEFAULT false,
+    ADD COLUMN IF NOT EXISTS totp_enabled_at timestamptz,
+    ADD COLUMN IF NOT EXISTS totp_last_step bigint DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS admin_recovery_codes (
+    recovery_code_id bigserial PRIMARY KEY,
+    admin_id         bigint NOT NULL,
+    code_hash        varchar(64) NOT NULL,
+    used_at          timestamptz,
+    created_at       timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_admin_recovery_codes_admin_id ON admin_recovery_codes (admin_id);
@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS phone_verified_at timestamptz;
+
+CREATE TABLE IF NOT EXISTS phonever (
+    phone        varchar(20),
+    code_hash    varchar(64),
+    purpose      varchar(20),
+    expires_at   timestamptz,
+    attempts     bigint DEFAULT 0,
+    last_sent_at timestamptz,
+    locked_until timestamptz,
+    createdat    time
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_phonever_target ON phonever (phone, purpose);
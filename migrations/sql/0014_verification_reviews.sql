@@ -0,0 +1,15 @@
+-- Every upload is kept as its own row, so submissions get an ID
+ALTER TABLE user_verifications
+    ADD COLUMN IF NOT EXISTS verification_id bigserial,
+    ADD COLUMN IF NOT EXISTS rejection_reason text,
+    ADD COLUMN IF NOT EXISTS reviewed_by bigint;
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conrelid = 'user_verifications'::regclass AND contype = 'p') THEN
+        ALTER TABLE user_verifications ADD PRIMARY KEY (verification_id);
+    END IF;
+END $$;
+-- Unreviewed rows used to carry a zero reviewed_at instead of none
+ALTER TABLE user_verifications ALTER COLUMN reviewed_at DROP NOT NULL;
+UPDATE user_verifications SET reviewed_at = NULL WHERE reviewed_at < '1900-01-01';
+CREATE INDEX IF NOT EXISTS idx_user_verifications_user_id ON user_verifications (user_id);
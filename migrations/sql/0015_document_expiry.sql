@@ -0,0 +1,4 @@
+ALTER TABLE user_verifications
+    ADD COLUMN IF NOT EXISTS document_type varchar(30),
+    ADD COLUMN IF NOT EXISTS expires_at date,
+    ADD COLUMN IF NOT EXISTS reminder_sent_at timestamptz;
@@ -0,0 +1,14 @@
+-- Key the document images are sealed with; empty for rows stored before encryption
+ALTER TABLE user_verifications ADD COLUMN IF NOT EXISTS key_id varchar(32);
+
+CREATE TABLE IF NOT EXISTS document_access_logs (
+    access_id       bigserial PRIMARY KEY,
+    verification_id bigint NOT NULL,
+    owner_id        bigint NOT NULL,
+    viewer_id       bigint NOT NULL,
+    viewer_role     varchar(10) NOT NULL,
+    ip              varchar(64),
+    user_agent      varchar(255),
+    accessed_at     timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_document_access_logs_verification_id ON document_access_logs (verification_id);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS audit_logs (
+    audit_id    bigserial PRIMARY KEY,
+    actor_id    bigint,
+    actor_role  varchar(10),
+    action      varchar(64) NOT NULL,
+    target_type varchar(40),
+    target_id   varchar(64),
+    changes     jsonb,
+    ip          varchar(64),
+    user_agent  varchar(255),
+    created_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_audit_actor ON audit_logs (actor_id, actor_role);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_action ON audit_logs (action);
+CREATE INDEX IF NOT EXISTS idx_audit_target ON audit_logs (target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS account_status varchar(20) DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS suspended_until timestamptz,
+    ADD COLUMN IF NOT EXISTS status_reason text;
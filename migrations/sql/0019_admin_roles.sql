@@ -0,0 +1,19 @@
+-- Admins that exist before roles do keep full access
+ALTER TABLE admins
+    ADD COLUMN IF NOT EXISTS admin_role varchar(20) DEFAULT 'super_admin',
+    ADD COLUMN IF NOT EXISTS disabled_at timestamptz;
+
+CREATE TABLE IF NOT EXISTS admin_invites (
+    invite_id   bigserial PRIMARY KEY,
+    email       text NOT NULL,
+    admin_role  varchar(20) NOT NULL,
+    token_hash  varchar(64) NOT NULL,
+    invited_by  bigint,
+    expires_at  timestamptz NOT NULL,
+    accepted_at timestamptz,
+    admin_id    bigint,
+    revoked_at  timestamptz,
+    created_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_admin_invites_email ON admin_invites (email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_admin_invites_token_hash ON admin_invites (token_hash);
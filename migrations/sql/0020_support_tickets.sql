@@ -0,0 +1,37 @@
+CREATE TABLE IF NOT EXISTS support_tickets (
+    ticket_id               bigserial PRIMARY KEY,
+    user_id                 bigint NOT NULL,
+    subject                 varchar(200) NOT NULL,
+    category                varchar(20),
+    status                  varchar(20) DEFAULT 'open',
+    priority                varchar(10) DEFAULT 'normal',
+    request_id              bigint,
+    payment_id              bigint,
+    assigned_to             bigint,
+    message_count           bigint DEFAULT 0,
+    last_sender_role        varchar(10),
+    first_response_due_at   timestamptz,
+    resolution_due_at       timestamptz,
+    first_responded_at      timestamptz,
+    resolved_at             timestamptz,
+    closed_at               timestamptz,
+    first_response_breached boolean DEFAULT false,
+    resolution_breached     boolean DEFAULT false,
+    last_message_at         timestamptz,
+    created_at              timestamptz,
+    updated_at              timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_support_tickets_user_id ON support_tickets (user_id);
+CREATE INDEX IF NOT EXISTS idx_support_tickets_status ON support_tickets (status);
+CREATE INDEX IF NOT EXISTS idx_support_tickets_assigned_to ON support_tickets (assigned_to);
+
+CREATE TABLE IF NOT EXISTS support_ticket_messages (
+    message_id  bigserial PRIMARY KEY,
+    ticket_id   bigint NOT NULL,
+    sender_id   bigint NOT NULL,
+    sender_role varchar(10) NOT NULL,
+    body        text NOT NULL,
+    internal    boolean DEFAULT false,
+    created_at  timestamptz
+);
+CREATE INDEX IF NOT EXISTS idx_support_ticket_messages_ticket_id ON support_ticket_messages (ticket_id);
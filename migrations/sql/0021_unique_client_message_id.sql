@@ -0,0 +1,16 @@
+-- A client_message_id identifies one message per sender, so concurrent retries can't both be
+-- stored. Duplicates saved before this keep their row but lose the key.
+UPDATE client_repairman_messages m
+SET client_message_id = ''
+WHERE m.client_message_id <> ''
+  AND EXISTS (
+      SELECT 1 FROM client_repairman_messages o
+      WHERE o.sender_id = m.sender_id
+        AND o.client_message_id = m.client_message_id
+        AND o.message_id < m.message_id
+  );
+
+DROP INDEX IF EXISTS idx_client_repairman_messages_client_message_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_client_id
+    ON client_repairman_messages (sender_id, client_message_id)
+    WHERE client_message_id <> '';
@@ -0,0 +1,4 @@
+-- Charges start pending and are settled by the Xendit callback, which sends the receipt.
+ALTER TABLE gcash_payments ADD COLUMN IF NOT EXISTS reference_id varchar(64) NOT NULL DEFAULT '';
+ALTER TABLE gcash_payments ADD COLUMN IF NOT EXISTS status varchar(20) NOT NULL DEFAULT 'pending';
+ALTER TABLE gcash_payments ADD COLUMN IF NOT EXISTS confirmed_at timestamptz;
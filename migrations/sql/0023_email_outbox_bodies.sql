@@ -0,0 +1,7 @@
+-- Rendered emails carry codes, reset links and invite tokens. Keep them only until delivery ends.
+UPDATE email_outbox
+SET html_body = '', text_body = ''
+WHERE status <> 'pending'
+  AND (html_body <> '' OR text_body <> '');
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_created_at ON email_outbox (created_at);
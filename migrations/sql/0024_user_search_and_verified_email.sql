@@ -0,0 +1,14 @@
+-- The address a user last proved they own. Admin edits of the email don't change it, so password
+-- resets can't be redirected to an address an admin typed in. Existing accounts signed up with
+-- their current address.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verified_email varchar(255) NOT NULL DEFAULT '';
+UPDATE users SET verified_email = LOWER(email) WHERE verified_email = '';
+
+-- Admin user search matches words anywhere in the name, email or phone. A trigram index lets
+-- those ILIKE patterns use an index instead of scanning every user. The expression must stay in
+-- sync with userSearchDocument in controller/fetchings/user_management.go.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_users_search_trgm ON users USING gin (
+    (COALESCE(first_name, '') || ' ' || COALESCE(last_name, '') || ' ' || COALESCE(email, '') || ' ' || COALESCE(phone, ''))
+    gin_trgm_ops
+);
@@ -0,0 +1,7 @@
+-- admin_role defaulted to super_admin, so an admin inserted without a role got full access.
+-- Admins that existed when roles were added were given super_admin by that default and keep it.
+-- A row left without a role gets an empty one, which grants nothing until a super admin sets it.
+UPDATE admins SET admin_role = '' WHERE admin_role IS NULL;
+ALTER TABLE admins
+    ALTER COLUMN admin_role DROP DEFAULT,
+    ALTER COLUMN admin_role SET NOT NULL;
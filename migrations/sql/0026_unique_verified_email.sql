@@ -0,0 +1,11 @@
+-- Password resets are looked up by verified_email, so no two accounts may share one. Where some
+-- already do, the account still logging in with that address keeps it (the oldest if several)
+-- and the others lose it until they verify an address again.
+UPDATE users SET verified_email = ''
+WHERE verified_email <> '' AND user_id NOT IN (
+    SELECT DISTINCT ON (LOWER(verified_email)) user_id
+    FROM users
+    WHERE verified_email <> ''
+    ORDER BY LOWER(verified_email), (LOWER(email) = LOWER(verified_email)) DESC, user_id
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_verified_email ON users (LOWER(verified_email)) WHERE verified_email <> '';
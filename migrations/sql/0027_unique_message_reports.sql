@@ -0,0 +1,11 @@
+-- A participant reports a message once, so concurrent reports can't both be stored. Duplicates
+-- saved before this are removed, keeping the first report.
+DELETE FROM message_reports r
+WHERE EXISTS (
+    SELECT 1 FROM message_reports o
+    WHERE o.message_id = r.message_id
+      AND o.reporter_id = r.reporter_id
+      AND o.report_id < r.report_id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_report_pair ON message_reports (message_id, reporter_id);
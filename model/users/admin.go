@@ -6,10 +6,24 @@ type Admin struct {
 	AdminId   int       `gorm:"primaryKey;column:admin_id" json:"admin_id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password"`
+	Password  string    `json:"-"`
 	CreatedAt time.Time `json:"createdat" gorm:"column:createdat;autoCreateTime"`
 	UpdatedAt time.Time `json:"updatedat" gorm:"column:updatedat;autoUpdateTime"`
 	FCMToken  string    `gorm:"size:255" json:"fcm_token"`
+
+	SessionsValidAfter *time.Time `gorm:"column:sessions_valid_after" json:"-"` // Tokens issued before this are rejected
+
+	// Two-factor authentication (TOTP). The secret is stored when enrolment starts but is only
+	// asked for at login once TOTPEnabled is set by confirming a first code.
+	TOTPSecret    string     `gorm:"column:totp_secret;type:varchar(64)" json:"-"`
+	TOTPEnabled   bool       `gorm:"column:totp_enabled;default:false" json:"totp_enabled"`
+	TOTPEnabledAt *time.Time `gorm:"column:totp_enabled_at" json:"totp_enabled_at"`
+	TOTPLastStep  int64      `gorm:"column:totp_last_step;default:0" json:"-"` // Time step of the last accepted code, so a code can't be replayed
+
+	// One of AdminRole*, deciding which admin routes the account may use. There is no default, so
+	// every way of creating an admin has to pick the role.
+	AdminRole  string     `gorm:"column:admin_role;type:varchar(20);not null" json:"admin_role"`
+	DisabledAt *time.Time `gorm:"column:disabled_at" json:"disabled_at"` // Set by a super admin; disabled admins can't log in
 }
 
 // Explicitly map to the correct table
@@ -17,6 +31,83 @@ func (Admin) TableName() string {
 	return "admins"
 }
 
+// Admin roles
+const (
+	AdminRoleSuper    = "super_admin" // Everything, including managing the admin team
+	AdminRoleVerifier = "verifier"
+	AdminRoleSupport  = "support"
+	AdminRoleFinance  = "finance"
+)
+
+// Admin permissions, each guarding a group of admin routes
+const (
+	PermissionVerifications = "verifications" // Review ID submissions and view the documents
+	PermissionUsers         = "users"         // Search, edit, suspend and unlock user accounts
+	PermissionModeration    = "moderation"    // Message reports and conversation monitoring
+	PermissionServices      = "services"      // Service categories
+	PermissionFinance       = "finance"       // Payments
+	PermissionAudit         = "audit"         // Audit log and document access log
+	PermissionSecurity      = "security"      // Document key rotation
+	PermissionAdmins        = "admins"        // Invite admins, change their roles, disable them
+	PermissionSupport       = "support"       // Support tickets
+)
+
+// AdminRolePermissions lists what each role may do
+var AdminRolePermissions = map[string][]string{
+	AdminRoleSuper: {
+		PermissionVerifications, PermissionUsers, PermissionModeration, PermissionServices,
+		PermissionFinance, PermissionAudit, PermissionSecurity, PermissionAdmins, PermissionSupport,
+	},
+	AdminRoleVerifier: {PermissionVerifications},
+	AdminRoleSupport:  {PermissionUsers, PermissionModeration, PermissionSupport},
+	AdminRoleFinance:  {PermissionFinance},
+}
+
+// AdminRoleHas reports whether the role grants the permission. Unknown roles grant nothing.
+func AdminRoleHas(role string, permission string) bool {
+	for _, granted := range AdminRolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// AdminInvite lets one person create an admin account with the given role. Only a hash of the
+// emailed token is stored; an invite is used once.
+type AdminInvite struct {
+	InviteId   uint       `gorm:"primaryKey;column:invite_id" json:"invite_id"`
+	Email      string     `gorm:"column:email;not null;index" json:"email"`
+	AdminRole  string     `gorm:"column:admin_role;type:varchar(20);not null" json:"admin_role"`
+	TokenHash  string     `gorm:"column:token_hash;type:varchar(64);not null;uniqueIndex" json:"-"`
+	InvitedBy  int        `gorm:"column:invited_by" json:"invited_by"`
+	ExpiresAt  time.Time  `gorm:"column:expires_at;not null" json:"expires_at"`
+	AcceptedAt *time.Time `gorm:"column:accepted_at" json:"accepted_at"`
+	AdminId    *int       `gorm:"column:admin_id" json:"admin_id"` // Account created with the invite
+	RevokedAt  *time.Time `gorm:"column:revoked_at" json:"revoked_at"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Inviter Admin `gorm:"foreignKey:InvitedBy;references:AdminId" json:"inviter"`
+}
+
+func (AdminInvite) TableName() string {
+	return "admin_invites"
+}
+
+// AdminRecoveryCode is a single-use code that replaces a TOTP code when the admin has lost
+// their authenticator. Only a hash is stored.
+type AdminRecoveryCode struct {
+	RecoveryCodeId uint       `gorm:"primaryKey;column:recovery_code_id" json:"recovery_code_id"`
+	AdminId        int        `gorm:"column:admin_id;not null;index" json:"admin_id"`
+	CodeHash       string     `gorm:"column:code_hash;type:varchar(64);not null" json:"-"`
+	UsedAt         *time.Time `gorm:"column:used_at" json:"used_at"`
+	CreatedAt      time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+func (AdminRecoveryCode) TableName() string {
+	return "admin_recovery_codes"
+}
+
 type Conversation struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	Admin1ID  uint      `json:"admin1_id"`
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -153,6 +154,37 @@ type User struct {
 	Created_at      time.Time `gorm:"column:createdat;autoCreateTime" json:"created_at"`
 	Updated_at      time.Time `gorm:"column:updatedat;autoUpdateTime" json:"updated_at"`
 	FCMToken        string    `gorm:"size:255" json:"fcm_token"`
+	QuietHoursStart string    `gorm:"column:quiet_hours_start;type:varchar(5)" json:"quiet_hours_start"` // "HH:MM", no pushes from here...
+	QuietHoursEnd   string    `gorm:"column:quiet_hours_end;type:varchar(5)" json:"quiet_hours_end"`     // ...until here (may wrap past midnight)
+
+	SessionsValidAfter *time.Time `gorm:"column:sessions_valid_after" json:"-"`                                 // Tokens issued before this are rejected
+	PhoneVerifiedAt    *time.Time `gorm:"column:phone_verified_at" json:"phone_verified_at"`                    // Set by an SMS code; cleared when the phone changes
+	VerifiedEmail      string     `gorm:"column:verified_email;type:varchar(255);not null;default:''" json:"-"` // Last address the user proved they own (unique); password resets go here
+
+	// Moderation state set by admins, one of AccountStatus*. A suspension ends by itself at
+	// SuspendedUntil; StatusReason is shown to the user.
+	AccountStatus  string     `gorm:"column:account_status;type:varchar(20);default:'active'" json:"account_status"`
+	SuspendedUntil *time.Time `gorm:"column:suspended_until" json:"suspended_until"`
+	StatusReason   string     `gorm:"column:status_reason;type:text" json:"status_reason,omitempty"`
+}
+
+// Account states
+const (
+	AccountStatusActive    = "active"
+	AccountStatusSuspended = "suspended"
+	AccountStatusBanned    = "banned"
+	AccountStatusDeleted   = "deleted" // Closed by the user and anonymised
+)
+
+// AccountRestricted reports whether an account in this state is locked out at the given time
+func AccountRestricted(status string, suspendedUntil *time.Time, now time.Time) bool {
+	switch status {
+	case AccountStatusBanned, AccountStatusDeleted:
+		return true
+	case AccountStatusSuspended:
+		return suspendedUntil == nil || now.Before(*suspendedUntil)
+	}
+	return false
 }
 
 // Repairman model remains the same
@@ -173,15 +205,41 @@ type Repairman struct {
 	Created_at          time.Time `gorm:"column:createdat;autoCreateTime" json:"created_at"`
 	Updated_at          time.Time `gorm:"column:updatedat;autoUpdateTime" json:"updated_at"`
 	CategoryId          int       `gorm:"column:category_id" json:"category_id"`
+	IsVerified          bool      `gorm:"-" json:"is_verified"` // Has an approved ID; filled in by the handlers that list repairmen
+
+	AccountStatus  string     `gorm:"column:account_status;type:varchar(20);default:'active'" json:"account_status"` // See User.AccountStatus
+	SuspendedUntil *time.Time `gorm:"column:suspended_until" json:"suspended_until"`
+	StatusReason   string     `gorm:"column:status_reason;type:text" json:"status_reason,omitempty"`
+	VerifiedEmail  string     `gorm:"column:verified_email;type:varchar(255);not null;default:''" json:"-"` // See User.VerifiedEmail
 
 	ServiceCategory ServiceCategory `gorm:"foreignKey:CategoryId;references:category_id" json:"service_category"`
 }
 
 // Updated EmailVer with TimeWithoutTimezone
+// EmailVer holds the pending verification code for an email address. Only an HMAC of the code is
+// stored; Code is the plain value sent by the client and is never persisted.
 type EmailVer struct {
-	Email     string              `gorm:"column:email" json:"email"`
-	Code      string              `gorm:"column:code" json:"code"`
-	Createdat TimeWithoutTimezone `gorm:"column:createdat;autoCreateTime" json:"createdat"`
+	Email       string              `gorm:"column:email;uniqueIndex:idx_emailver_target" json:"email"`
+	Code        string              `gorm:"-" json:"code,omitempty"`
+	CodeHash    string              `gorm:"column:code_hash;type:varchar(64)" json:"-"`
+	Purpose     string              `gorm:"column:purpose;type:varchar(20);default:signup;uniqueIndex:idx_emailver_target" json:"-"`
+	ExpiresAt   *time.Time          `gorm:"column:expires_at" json:"-"`
+	Attempts    int                 `gorm:"column:attempts;default:0" json:"-"`
+	LastSentAt  *time.Time          `gorm:"column:last_sent_at" json:"-"`
+	LockedUntil *time.Time          `gorm:"column:locked_until" json:"-"`
+	Createdat   TimeWithoutTimezone `gorm:"column:createdat;autoCreateTime" json:"createdat"`
+}
+
+// PhoneVer holds SMS codes, with the same rules as EmailVer
+type PhoneVer struct {
+	Phone       string              `gorm:"column:phone;type:varchar(20);uniqueIndex:idx_phonever_target" json:"phone"`
+	CodeHash    string              `gorm:"column:code_hash;type:varchar(64)" json:"-"`
+	Purpose     string              `gorm:"column:purpose;type:varchar(20);uniqueIndex:idx_phonever_target" json:"-"`
+	ExpiresAt   *time.Time          `gorm:"column:expires_at" json:"-"`
+	Attempts    int                 `gorm:"column:attempts;default:0" json:"-"`
+	LastSentAt  *time.Time          `gorm:"column:last_sent_at" json:"-"`
+	LockedUntil *time.Time          `gorm:"column:locked_until" json:"-"`
+	Createdat   TimeWithoutTimezone `gorm:"column:createdat;autoCreateTime" json:"createdat"`
 }
 
 // ServiceCategory remains the same
@@ -224,16 +282,68 @@ type Review struct {
 	Request   *ServiceRequest `gorm:"foreignKey:RequestId;references:RequestId" json:"request"` // 🛠 Make it a pointer
 }
 
+// ID verification states. Every upload is a new UserVerification row so the review history is
+// kept; a pending upload replaced by a newer one becomes superseded.
+const (
+	VerificationStatusPending    = "pending"
+	VerificationStatusApproved   = "approved"
+	VerificationStatusRejected   = "rejected"
+	VerificationStatusSuperseded = "superseded"
+	VerificationStatusExpired    = "expired" // Was approved, but the document has lapsed
+)
+
+// Accepted identity documents
+const (
+	DocumentPassport       = "passport"
+	DocumentDriversLicense = "drivers_license"
+	DocumentNationalID     = "national_id" // PhilSys
+	DocumentUMID           = "umid"
+	DocumentPRC            = "prc_id"
+	DocumentPostalID       = "postal_id"
+	DocumentVotersID       = "voters_id"
+)
+
+// DocumentTypes lists the accepted documents and whether each one carries an expiry date
+var DocumentTypes = map[string]bool{
+	DocumentPassport:       true,
+	DocumentDriversLicense: true,
+	DocumentNationalID:     false,
+	DocumentUMID:           false,
+	DocumentPRC:            true,
+	DocumentPostalID:       true,
+	DocumentVotersID:       false,
+}
+
 type UserVerification struct {
-	UserId      uint      `gorm:"not null;column:user_id" json:"user_id"`
-	ValidId     []byte    `gorm:"type:bytea;not null" json:"valid_id"`
-	Selfie      []byte    `gorm:"type:bytea;not null" json:"selfie_id"`
-	BackId      []byte    `gorm:"type:bytea;not null" json:"back_id"`
-	Status      string    `gorm:"type:varchar(20);default:'pending'" json:"status"`
-	SubmittedAt time.Time `gorm:"autoCreateTime" json:"submitted_at"`
-	ReviewedAt  time.Time `json:"reviewed_at"`
+	VerificationId  uint       `gorm:"primaryKey;column:verification_id" json:"verification_id"`
+	UserId          uint       `gorm:"not null;column:user_id;index" json:"user_id"`
+	ValidId         []byte     `gorm:"type:bytea;not null" json:"valid_id,omitempty"`
+	Selfie          []byte     `gorm:"type:bytea;not null" json:"selfie_id,omitempty"`
+	BackId          []byte     `gorm:"type:bytea;not null" json:"back_id,omitempty"`
+	Status          string     `gorm:"type:varchar(20);default:'pending'" json:"status"`
+	DocumentType    string     `gorm:"column:document_type;type:varchar(30)" json:"document_type"`
+	ExpiresAt       *time.Time `gorm:"column:expires_at;type:date" json:"expires_at"` // Last day the document is valid; nil for documents without expiry
+	RejectionReason string     `gorm:"column:rejection_reason;type:text" json:"rejection_reason,omitempty"`
+	SubmittedAt     time.Time  `gorm:"autoCreateTime" json:"submitted_at"`
+	ReviewedAt      *time.Time `gorm:"column:reviewed_at" json:"reviewed_at"`
+	ReviewedBy      *int       `gorm:"column:reviewed_by" json:"reviewed_by"`   // Admin who approved or rejected
+	ReminderSentAt  *time.Time `gorm:"column:reminder_sent_at" json:"-"`        // Last expiry reminder, see jobs.RunVerificationExpiry
+	KeyId           string     `gorm:"column:key_id;type:varchar(32)" json:"-"` // Key the images are sealed with, see the vault package; empty for plaintext rows
+
+	User     User   `gorm:"foreignKey:UserId;references:UserId" json:"user"`
+	Reviewer *Admin `gorm:"foreignKey:ReviewedBy;references:AdminId" json:"reviewer,omitempty"`
+}
 
-	User User `gorm:"foreignKey:UserId;references:UserId" json:"user"`
+// DocumentAccessLog records every time the images of an ID submission are viewed
+type DocumentAccessLog struct {
+	AccessId       uint      `gorm:"primaryKey;column:access_id" json:"access_id"`
+	VerificationId uint      `gorm:"column:verification_id;not null;index" json:"verification_id"`
+	OwnerId        uint      `gorm:"column:owner_id;not null" json:"owner_id"`   // User the documents belong to
+	ViewerId       uint      `gorm:"column:viewer_id;not null" json:"viewer_id"` // Admin or user ID, depending on ViewerRole
+	ViewerRole     string    `gorm:"column:viewer_role;type:varchar(10);not null" json:"viewer_role"`
+	IP             string    `gorm:"column:ip;type:varchar(64)" json:"ip"`
+	UserAgent      string    `gorm:"column:user_agent;type:varchar(255)" json:"user_agent"`
+	AccessedAt     time.Time `gorm:"column:accessed_at;autoCreateTime" json:"accessed_at"`
 }
 
 // Updated UserNotification with TimeWithDate
@@ -245,9 +355,66 @@ type UserNotification struct {
 	ToUser         int          `gorm:"column:to_user" json:"to_user"`
 	Description    string       `gorm:"column:description" json:"description"`
 	IsRead         bool         `gorm:"column:is_read" json:"is_read"`
+	IsArchived     bool         `gorm:"column:is_archived;default:false" json:"is_archived"`
 	CreatedAt      TimeWithDate `gorm:"column:createdat" json:"createdat"`
 }
 
+// Notification categories used for per-user preferences
+const (
+	NotificationCategoryChat    = "chat"
+	NotificationCategoryRequest = "request"
+	NotificationCategoryReview  = "review"
+	NotificationCategoryPayment = "payment"
+	NotificationCategoryAccount = "account"
+	NotificationCategorySupport = "support"
+)
+
+// NotificationCategories lists every category a user can set preferences for
+var NotificationCategories = []string{
+	NotificationCategoryChat,
+	NotificationCategoryRequest,
+	NotificationCategoryReview,
+	NotificationCategoryPayment,
+	NotificationCategoryAccount,
+	NotificationCategorySupport,
+}
+
+// NotificationPreference controls how one category of notifications reaches a user.
+// Muted categories are still written to the inbox but never pushed or emailed.
+type NotificationPreference struct {
+	PreferenceId uint      `gorm:"primaryKey;column:preference_id" json:"-"`
+	UserId       uint      `gorm:"column:user_id;not null;uniqueIndex:idx_notification_preference" json:"-"`
+	Category     string    `gorm:"column:category;type:varchar(20);not null;uniqueIndex:idx_notification_preference" json:"category"`
+	Muted        bool      `gorm:"column:muted" json:"muted"`
+	PushEnabled  bool      `gorm:"column:push_enabled" json:"push_enabled"`
+	EmailEnabled bool      `gorm:"column:email_enabled" json:"email_enabled"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+// DefaultNotificationPreference is used for categories the user never configured:
+// everything is pushed, and payments and support replies are also emailed.
+func DefaultNotificationPreference(userID uint, category string) NotificationPreference {
+	return NotificationPreference{
+		UserId:       userID,
+		Category:     category,
+		PushEnabled:  true,
+		EmailEnabled: category == NotificationCategoryPayment || category == NotificationCategorySupport,
+	}
+}
+
+// DeviceToken is one FCM registration token. Users and admins can have several devices; tokens
+// that FCM reports as unregistered are deleted on the next send.
+type DeviceToken struct {
+	DeviceId   uint      `gorm:"primaryKey;column:device_id" json:"device_id"`
+	OwnerType  string    `gorm:"column:owner_type;type:varchar(10);not null;index:idx_device_owner" json:"owner_type"` // RoleUser or RoleAdmin
+	OwnerId    uint      `gorm:"column:owner_id;not null;index:idx_device_owner" json:"owner_id"`
+	Token      string    `gorm:"column:token;type:varchar(255);not null;uniqueIndex" json:"-"`
+	Platform   string    `gorm:"column:platform;type:varchar(20)" json:"platform"`
+	AppVersion string    `gorm:"column:app_version;type:varchar(32)" json:"app_version"`
+	LastSeenAt time.Time `gorm:"column:last_seen_at" json:"last_seen_at"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
 // Updated UserNotification with TimeWithDate
 type ChatNotification struct {
 	NotificationId int          `gorm:"primaryKey;column:notification_id" json:"notification_id"`
@@ -259,44 +426,241 @@ type ChatNotification struct {
 	CreatedAt      TimeWithDate `gorm:"column:createdat" json:"createdat"`
 }
 
+// Token roles carried in Claims.Role
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // Claims structure remains the same
 type Claims struct {
-	UserId uint `json:"user_id"`
+	UserId uint   `json:"user_id"`
+	Role   string `json:"role"` // RoleUser or RoleAdmin; tokens without one are rejected
 	jwt.StandardClaims
 }
 
+// IsAdmin reports whether the token was issued to an admin account
+func (c *Claims) IsAdmin() bool {
+	return c.Role == RoleAdmin
+}
+
 type ClientRepairmanConversation struct {
 	ConversationId uint      `gorm:"primaryKey" json:"conversation_id"`
 	ClientId       uint      `gorm:"not null" json:"client_id"`
 	RepairmanId    uint      `gorm:"not null" json:"repairman_id"`
+	RequestId      *int      `gorm:"column:request_id;index" json:"request_id"` // Service request this chat belongs to (nil for legacy chats)
+	IsReadOnly     bool      `gorm:"column:is_read_only" json:"is_read_only"`   // Set once the request is completed or canceled
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"` // Add this line
 
-	Client    User `gorm:"foreignKey:ClientId;references:UserId"`
-	Repairman User `gorm:"foreignKey:RepairmanId;references:UserId"`
+	Client    User            `gorm:"foreignKey:ClientId;references:UserId"`
+	Repairman User            `gorm:"foreignKey:RepairmanId;references:UserId"`
+	Request   *ServiceRequest `gorm:"foreignKey:RequestId;references:RequestId" json:"request,omitempty"`
 }
 
+// HasParticipant reports whether the user is the client or the repairman of the conversation
+func (c ClientRepairmanConversation) HasParticipant(userID uint) bool {
+	return userID != 0 && (c.ClientId == userID || c.RepairmanId == userID)
+}
+
+// OtherParticipant returns the ID of the party the given user is talking to
+func (c ClientRepairmanConversation) OtherParticipant(userID uint) uint {
+	if c.ClientId == userID {
+		return c.RepairmanId
+	}
+	return c.ClientId
+}
+
+// Message kinds for ClientRepairmanMessage.MessageType
+const (
+	MessageTypeText     = "text"
+	MessageTypeImage    = "image"
+	MessageTypeFile     = "file"
+	MessageTypeLocation = "location"
+)
+
 type ClientRepairmanMessage struct {
 	MessageId      uint      `gorm:"primaryKey" json:"message_id"`
 	ConversationId uint      `gorm:"not null" json:"conversation_id"`
-	SenderId       uint      `gorm:"not null" json:"sender_id"`
-	Message        string    `gorm:"type:text;not null" json:"message"`
+	SenderId       uint      `gorm:"not null;uniqueIndex:idx_message_client_id,where:client_message_id <> ''" json:"sender_id"`
+	Message        string    `gorm:"type:text;not null" json:"message"` // Text body, or caption for attachments
+	MessageType    string    `gorm:"column:message_type;type:varchar(20);default:'text'" json:"message_type"`
+	AttachmentId   *uint     `gorm:"column:attachment_id" json:"attachment_id,omitempty"`
+	Latitude       *float64  `gorm:"column:latitude" json:"latitude,omitempty"`
+	Longitude      *float64  `gorm:"column:longitude" json:"longitude,omitempty"`
+	ClientMsgId    string    `gorm:"column:client_message_id;type:varchar(64);uniqueIndex:idx_message_client_id" json:"client_message_id,omitempty"` // Idempotency key supplied by the app, unique per sender
 	CreatedAt      time.Time `json:"created_at"`
 
 	Conversation ClientRepairmanConversation `gorm:"foreignKey:ConversationId;references:ConversationId"`
 	Sender       User                        `gorm:"foreignKey:SenderId;references:UserId"`
+	Attachment   *ChatAttachment             `gorm:"foreignKey:AttachmentId;references:AttachmentId" json:"attachment,omitempty"`
+}
+
+// ChatAttachment is a photo or document uploaded for use in a chat message.
+// The binary is only served through the attachment download endpoint.
+type ChatAttachment struct {
+	AttachmentId uint      `gorm:"primaryKey;column:attachment_id" json:"attachment_id"`
+	UploaderId   uint      `gorm:"column:uploader_id;not null" json:"uploader_id"`
+	FileName     string    `gorm:"column:file_name" json:"file_name"`
+	ContentType  string    `gorm:"column:content_type" json:"content_type"`
+	Size         int64     `gorm:"column:size" json:"size"`
+	Data         []byte    `gorm:"column:data;type:bytea;not null" json:"-"`
+	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+}
+
+// UserBlock stops the blocked user from messaging or sending requests to the blocker (and vice versa)
+type UserBlock struct {
+	BlockId   uint      `gorm:"primaryKey;column:block_id" json:"block_id"`
+	BlockerId uint      `gorm:"column:blocker_id;not null;uniqueIndex:idx_user_block_pair" json:"blocker_id"`
+	BlockedId uint      `gorm:"column:blocked_id;not null;uniqueIndex:idx_user_block_pair" json:"blocked_id"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Blocked User `gorm:"foreignKey:BlockedId;references:UserId" json:"blocked"`
+}
+
+// Report statuses for MessageReport.Status
+const (
+	ReportStatusPending   = "pending"
+	ReportStatusDismissed = "dismissed"
+	ReportStatusActioned  = "actioned"
+)
+
+// MessageReport is a chat message flagged by a participant for admin moderation
+type MessageReport struct {
+	ReportId   uint       `gorm:"primaryKey;column:report_id" json:"report_id"`
+	MessageId  uint       `gorm:"column:message_id;not null;uniqueIndex:idx_message_report_pair" json:"message_id"`
+	ReporterId uint       `gorm:"column:reporter_id;not null;uniqueIndex:idx_message_report_pair" json:"reporter_id"`
+	Reason     string     `gorm:"column:reason;type:text" json:"reason"`
+	Status     string     `gorm:"column:status;type:varchar(20);default:'pending'" json:"status"`
+	ReviewedBy *int       `gorm:"column:reviewed_by" json:"reviewed_by"`
+	ReviewedAt *time.Time `gorm:"column:reviewed_at" json:"reviewed_at"`
+	AdminNote  string     `gorm:"column:admin_note;type:text" json:"admin_note"`
+	CreatedAt  time.Time  `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+
+	Message  ClientRepairmanMessage `gorm:"foreignKey:MessageId;references:MessageId" json:"message"`
+	Reporter User                   `gorm:"foreignKey:ReporterId;references:UserId" json:"reporter"`
+}
+
+// Support ticket states. Open tickets wait for an admin, pending ones for the user.
+const (
+	TicketStatusOpen     = "open"
+	TicketStatusPending  = "pending"
+	TicketStatusResolved = "resolved"
+	TicketStatusClosed   = "closed" // No more messages
+)
+
+// Support ticket priorities
+const (
+	TicketPriorityLow    = "low"
+	TicketPriorityNormal = "normal"
+	TicketPriorityHigh   = "high"
+	TicketPriorityUrgent = "urgent"
+)
+
+// TicketSLA is how long the support team has to first reply to and to resolve a ticket
+type TicketSLA struct {
+	FirstResponse time.Duration
+	Resolution    time.Duration
+}
+
+// TicketSLAs holds the targets for each priority
+var TicketSLAs = map[string]TicketSLA{
+	TicketPriorityLow:    {FirstResponse: 24 * time.Hour, Resolution: 7 * 24 * time.Hour},
+	TicketPriorityNormal: {FirstResponse: 8 * time.Hour, Resolution: 3 * 24 * time.Hour},
+	TicketPriorityHigh:   {FirstResponse: 4 * time.Hour, Resolution: 24 * time.Hour},
+	TicketPriorityUrgent: {FirstResponse: time.Hour, Resolution: 8 * time.Hour},
+}
+
+// SupportTicket is a question or problem a client or repairman raises with the admins, optionally
+// about one of their service requests or payments. The due times come from TicketSLAs and are
+// measured from CreatedAt; the breach flags are set once by the SLA job.
+type SupportTicket struct {
+	TicketId       uint   `gorm:"primaryKey;column:ticket_id" json:"ticket_id"`
+	UserId         uint   `gorm:"column:user_id;not null;index" json:"user_id"`
+	Subject        string `gorm:"column:subject;type:varchar(200);not null" json:"subject"`
+	Category       string `gorm:"column:category;type:varchar(20)" json:"category"` // general, request, payment or account
+	Status         string `gorm:"column:status;type:varchar(20);default:'open';index" json:"status"`
+	Priority       string `gorm:"column:priority;type:varchar(10);default:'normal'" json:"priority"`
+	RequestId      *int   `gorm:"column:request_id" json:"request_id"`
+	PaymentId      *uint  `gorm:"column:payment_id" json:"payment_id"`
+	AssignedTo     *int   `gorm:"column:assigned_to;index" json:"assigned_to"` // Admin ID
+	MessageCount   int    `gorm:"column:message_count;default:0" json:"message_count"`
+	LastSenderRole string `gorm:"column:last_sender_role;type:varchar(10)" json:"last_sender_role"`
+
+	FirstResponseDueAt    time.Time  `gorm:"column:first_response_due_at" json:"first_response_due_at"`
+	ResolutionDueAt       time.Time  `gorm:"column:resolution_due_at" json:"resolution_due_at"`
+	FirstRespondedAt      *time.Time `gorm:"column:first_responded_at" json:"first_responded_at"`
+	ResolvedAt            *time.Time `gorm:"column:resolved_at" json:"resolved_at"`
+	ClosedAt              *time.Time `gorm:"column:closed_at" json:"closed_at"`
+	FirstResponseBreached bool       `gorm:"column:first_response_breached;default:false" json:"first_response_breached"`
+	ResolutionBreached    bool       `gorm:"column:resolution_breached;default:false" json:"resolution_breached"`
+
+	LastMessageAt time.Time `gorm:"column:last_message_at" json:"last_message_at"`
+	CreatedAt     time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+
+	User     User                   `gorm:"foreignKey:UserId;references:UserId" json:"user"`
+	Assignee *Admin                 `gorm:"foreignKey:AssignedTo;references:AdminId" json:"assignee,omitempty"`
+	Messages []SupportTicketMessage `gorm:"foreignKey:TicketId;references:TicketId" json:"messages,omitempty"`
+}
+
+// SetDueTimes computes the SLA due times from the priority and CreatedAt
+func (t *SupportTicket) SetDueTimes() {
+	sla, ok := TicketSLAs[t.Priority]
+	if !ok {
+		sla = TicketSLAs[TicketPriorityNormal]
+	}
+	t.FirstResponseDueAt = t.CreatedAt.Add(sla.FirstResponse)
+	t.ResolutionDueAt = t.CreatedAt.Add(sla.Resolution)
+}
+
+// SLABreaches reports whether the first reply and the resolution came (or, while still
+// missing, are) later than their due times
+func (t *SupportTicket) SLABreaches(now time.Time) (firstResponse bool, resolution bool) {
+	responded, resolved := now, now
+	if t.FirstRespondedAt != nil {
+		responded = *t.FirstRespondedAt
+	}
+	if t.ResolvedAt != nil {
+		resolved = *t.ResolvedAt
+	} else if t.ClosedAt != nil {
+		resolved = *t.ClosedAt
+	}
+	return responded.After(t.FirstResponseDueAt), resolved.After(t.ResolutionDueAt)
+}
+
+// SupportTicketMessage is one message in a ticket's thread. Internal notes are between admins and
+// never shown to the user.
+type SupportTicketMessage struct {
+	MessageId  uint      `gorm:"primaryKey;column:message_id" json:"message_id"`
+	TicketId   uint      `gorm:"column:ticket_id;not null;index" json:"ticket_id"`
+	SenderId   uint      `gorm:"column:sender_id;not null" json:"sender_id"`
+	SenderRole string    `gorm:"column:sender_role;type:varchar(10);not null" json:"sender_role"` // RoleUser or RoleAdmin
+	Body       string    `gorm:"column:body;type:text;not null" json:"body"`
+	Internal   bool      `gorm:"column:internal;default:false" json:"internal"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime" json:"created_at"`
 }
 
 type GCashPayment struct {
-	PaymentID     uint      `gorm:"primaryKey"`
-	PaymentFrom   int       `gorm:"column:payment_from; not null"`
-	PaymentTo     int       `gorm:"column:payment_to; not null"`
-	TransactionId string    `gorm:"column:transaction_id; unique; not null"`
-	Amount        float64   `gorm:"column:amount; not null"`
-	GcashID       uint      `gorm:"column:gcash_id; not null"` // Foreign key to Gcash table
-	PaymentDate   time.Time `gorm:"column:payment_date; not null"`
+	PaymentID     uint       `gorm:"primaryKey"`
+	PaymentFrom   int        `gorm:"column:payment_from; not null"`
+	PaymentTo     int        `gorm:"column:payment_to; not null"`
+	TransactionId string     `gorm:"column:transaction_id; unique; not null"`
+	Amount        float64    `gorm:"column:amount; not null"`
+	GcashID       uint       `gorm:"column:gcash_id; not null"` // Foreign key to Gcash table
+	PaymentDate   time.Time  `gorm:"column:payment_date; not null"`
+	ReferenceId   string     `gorm:"column:reference_id;type:varchar(64);not null;default:''"`
+	Status        string     `gorm:"column:status;type:varchar(20);not null;default:'pending'"` // One of PaymentStatus*
+	ConfirmedAt   *time.Time `gorm:"column:confirmed_at"`
 }
 
+// Payment statuses. Charges are pending until the Xendit callback settles them.
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusSucceeded = "succeeded"
+	PaymentStatusFailed    = "failed"
+)
+
 type Gcash struct {
 	GcashID     uint   `gorm:"primaryKey"`
 	UserID      uint   `gorm:"not null;unique"` // One GCash info per user
@@ -306,16 +670,151 @@ type Gcash struct {
 	Updatedat   time.Time
 }
 
+// Login attempt counter scopes
+const (
+	LoginScopeAccount = "account" // Key is "<role>:<email or username>"
+	LoginScopeIP      = "ip"      // Key is the client IP
+)
+
+// LoginAttempt counts recent failed logins for an account identifier or an IP address.
+// Rows are reset on a successful login or by an admin unlock.
+type LoginAttempt struct {
+	AttemptId     uint       `gorm:"primaryKey;column:attempt_id" json:"attempt_id"`
+	Scope         string     `gorm:"column:scope;type:varchar(10);not null;uniqueIndex:idx_login_attempt_key" json:"scope"`
+	Key           string     `gorm:"column:login_key;type:varchar(255);not null;uniqueIndex:idx_login_attempt_key" json:"key"`
+	Failures      int        `gorm:"column:failures;default:0" json:"failures"`
+	LastFailureAt time.Time  `gorm:"column:last_failure_at" json:"last_failure_at"`
+	LastIP        string     `gorm:"column:last_ip;type:varchar(64)" json:"last_ip"`
+	LockedUntil   *time.Time `gorm:"column:locked_until" json:"locked_until"`
+}
+
+// Audit log actions
+const (
+	AuditServiceCategoryCreate  = "service_category.create"
+	AuditServiceCategoryUpdate  = "service_category.update"
+	AuditServiceCategoryDisable = "service_category.disable"
+	AuditServiceCategoryDelete  = "service_category.delete"
+	AuditVerificationReview     = "verification.review"
+	AuditAccountUpdate          = "account.update"
+	AuditAccountPasswordChange  = "account.password_change"
+	AuditAccountPasswordReset   = "account.password_reset"
+	AuditAdminTwoFactorDisable  = "admin.2fa_disable"
+	AuditAccountStatusChange    = "account.status_change"
+	AuditAccountDataExport      = "account.data_export"
+	AuditAccountDelete          = "account.delete"
+	AuditAccountForceReset      = "account.force_password_reset"
+	AuditAccountTypeChange      = "account.type_change"
+	AuditLoginUnlock            = "login.unlock"
+	AuditDocumentKeysRotate     = "documents.rotate_keys"
+	AuditAdminInviteCreate      = "admin.invite_create"
+	AuditAdminInviteRevoke      = "admin.invite_revoke"
+	AuditAdminInviteAccept      = "admin.invite_accept"
+	AuditAdminRoleChange        = "admin.role_change"
+	AuditAdminStatusChange      = "admin.status_change"
+	AuditSupportTicketUpdate    = "support_ticket.update"
+)
+
+// AuditLog is an append-only record of an administrative or security-sensitive change. Rows are
+// only ever inserted, through controller.RecordAudit.
+type AuditLog struct {
+	AuditId    uint         `gorm:"primaryKey;column:audit_id" json:"audit_id"`
+	ActorId    uint         `gorm:"column:actor_id;index:idx_audit_actor" json:"actor_id"`
+	ActorRole  string       `gorm:"column:actor_role;type:varchar(10);index:idx_audit_actor" json:"actor_role"` // RoleUser or RoleAdmin
+	Action     string       `gorm:"column:action;type:varchar(64);not null;index" json:"action"`
+	TargetType string       `gorm:"column:target_type;type:varchar(40);index:idx_audit_target" json:"target_type"`
+	TargetId   string       `gorm:"column:target_id;type:varchar(64);index:idx_audit_target" json:"target_id"`
+	Changes    AuditChanges `gorm:"column:changes;type:jsonb" json:"changes"` // Only the fields that changed
+	IP         string       `gorm:"column:ip;type:varchar(64)" json:"ip"`
+	UserAgent  string       `gorm:"column:user_agent;type:varchar(255)" json:"user_agent"`
+	CreatedAt  time.Time    `gorm:"column:created_at;autoCreateTime;index" json:"created_at"`
+}
+
+// AuditChange is the value of one field before and after a change
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditChanges maps field names to their change, stored as JSON
+type AuditChanges map[string]AuditChange
+
+func (a AuditChanges) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(a)
+	return string(data), err
+}
+
+func (a *AuditChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	}
+	return fmt.Errorf("unsupported audit changes format %T", value)
+}
+
+// Email outbox states
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed" // Gave up after the maximum number of attempts
+)
+
+// EmailOutbox holds every transactional email until it is delivered, so a mail server outage
+// delays emails instead of failing the request that triggered them. The bodies are blanked once
+// the email is sent or given up on, and those rows are purged after 30 days.
+type EmailOutbox struct {
+	OutboxId      uint       `gorm:"primaryKey;column:outbox_id" json:"outbox_id"`
+	Recipient     string     `gorm:"column:recipient;type:varchar(255);not null" json:"recipient"`
+	Subject       string     `gorm:"column:subject;type:varchar(255)" json:"subject"`
+	Template      string     `gorm:"column:template;type:varchar(50)" json:"template"`
+	HTMLBody      string     `gorm:"column:html_body;type:text" json:"-"`
+	TextBody      string     `gorm:"column:text_body;type:text" json:"-"`
+	Status        string     `gorm:"column:status;type:varchar(10);not null;default:pending;index:idx_email_outbox_due" json:"status"`
+	Attempts      int        `gorm:"column:attempts;default:0" json:"attempts"`
+	LastError     string     `gorm:"column:last_error;type:text" json:"last_error"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at;index:idx_email_outbox_due" json:"next_attempt_at"`
+	SentAt        *time.Time `gorm:"column:sent_at" json:"sent_at"`
+	CreatedAt     time.Time  `gorm:"column:created_at;autoCreateTime;index:idx_email_outbox_created_at" json:"created_at"`
+}
+
+// ResetEmail is where password resets for the user are sent: the last address they verified, or
+// the account email for rows from before verified addresses were tracked
+func (u User) ResetEmail() string {
+	if u.VerifiedEmail != "" {
+		return u.VerifiedEmail
+	}
+	return strings.ToLower(u.Email)
+}
+
 // TableName methods remain the same
 func (User) TableName() string                   { return "users" }
 func (Repairman) TableName() string              { return "users" }
 func (EmailVer) TableName() string               { return "emailver" }
+func (PhoneVer) TableName() string               { return "phonever" }
 func (ServiceCategory) TableName() string        { return "service_categories" }
 func (ServiceRequest) TableName() string         { return "service_requests" }
 func (Review) TableName() string                 { return "reviews" }
 func (UserVerification) TableName() string       { return "user_verifications" }
 func (UserNotification) TableName() string       { return "user_notifications" }
 func (ChatNotification) TableName() string       { return "chat_notifications" }
+func (NotificationPreference) TableName() string { return "notification_preferences" }
+func (DeviceToken) TableName() string            { return "device_tokens" }
 func (ClientRepairmanMessage) TableName() string { return "client_repairman_messages" }
+func (ChatAttachment) TableName() string         { return "chat_attachments" }
+func (UserBlock) TableName() string              { return "user_blocks" }
+func (MessageReport) TableName() string          { return "message_reports" }
 func (GCashPayment) TableName() string           { return "gcash_payments" }
 func (Gcash) TableName() string                  { return "gcash" }
+func (EmailOutbox) TableName() string            { return "email_outbox" }
+func (LoginAttempt) TableName() string           { return "login_attempts" }
+func (DocumentAccessLog) TableName() string      { return "document_access_logs" }
+func (AuditLog) TableName() string               { return "audit_logs" }
+func (SupportTicket) TableName() string          { return "support_tickets" }
+func (SupportTicketMessage) TableName() string   { return "support_ticket_messages" }
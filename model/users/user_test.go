@@ -0,0 +1,101 @@
+package users
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccountRestricted(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	tests := []struct {
+		name           string
+		status         string
+		suspendedUntil *time.Time
+		want           bool
+	}{
+		{"active", AccountStatusActive, nil, false},
+		{"no status on old rows", "", nil, false},
+		{"banned", AccountStatusBanned, nil, true},
+		{"deleted", AccountStatusDeleted, nil, true},
+		{"suspended indefinitely", AccountStatusSuspended, nil, true},
+		{"suspension still running", AccountStatusSuspended, &later, true},
+		{"suspension over", AccountStatusSuspended, &earlier, false},
+		{"suspension ends right now", AccountStatusSuspended, &now, false},
+		{"end date ignored when not suspended", AccountStatusActive, &later, false},
+	}
+	for _, tt := range tests {
+		if got := AccountRestricted(tt.status, tt.suspendedUntil, now); got != tt.want {
+			t.Errorf("%s: AccountRestricted = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSupportTicketSLA(t *testing.T) {
+	opened := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	ticket := SupportTicket{Priority: TicketPriorityHigh, CreatedAt: opened}
+	ticket.SetDueTimes()
+	if want := opened.Add(4 * time.Hour); !ticket.FirstResponseDueAt.Equal(want) {
+		t.Errorf("first response due %v, want %v", ticket.FirstResponseDueAt, want)
+	}
+	if want := opened.Add(24 * time.Hour); !ticket.ResolutionDueAt.Equal(want) {
+		t.Errorf("resolution due %v, want %v", ticket.ResolutionDueAt, want)
+	}
+
+	if first, resolution := ticket.SLABreaches(opened.Add(3 * time.Hour)); first || resolution {
+		t.Errorf("within both targets: got breaches %v, %v", first, resolution)
+	}
+	if first, resolution := ticket.SLABreaches(opened.Add(5 * time.Hour)); !first || resolution {
+		t.Errorf("unanswered after 5h: got breaches %v, %v; want true, false", first, resolution)
+	}
+
+	// Answered and resolved in time stays within the SLA however late it is checked
+	responded, resolved := opened.Add(time.Hour), opened.Add(20*time.Hour)
+	ticket.FirstRespondedAt, ticket.ResolvedAt = &responded, &resolved
+	if first, resolution := ticket.SLABreaches(opened.Add(72 * time.Hour)); first || resolution {
+		t.Errorf("handled in time: got breaches %v, %v", first, resolution)
+	}
+
+	// A closed ticket counts as resolved when it was closed
+	closed := opened.Add(30 * time.Hour)
+	ticket.ResolvedAt, ticket.ClosedAt = nil, &closed
+	if _, resolution := ticket.SLABreaches(opened.Add(31 * time.Hour)); !resolution {
+		t.Error("closed after the resolution target should be a breach")
+	}
+
+	// Unknown priorities fall back to normal
+	unknown := SupportTicket{Priority: "whenever", CreatedAt: opened}
+	unknown.SetDueTimes()
+	if want := opened.Add(TicketSLAs[TicketPriorityNormal].FirstResponse); !unknown.FirstResponseDueAt.Equal(want) {
+		t.Errorf("unknown priority first response due %v, want %v", unknown.FirstResponseDueAt, want)
+	}
+}
+
+func TestAdminRoleHas(t *testing.T) {
+	if !AdminRoleHas(AdminRoleSuper, PermissionAdmins) {
+		t.Error("super admins must be able to manage the admin team")
+	}
+	if AdminRoleHas(AdminRoleFinance, PermissionVerifications) {
+		t.Error("finance must not review ID documents")
+	}
+	if !AdminRoleHas(AdminRoleSupport, PermissionSupport) {
+		t.Error("support must be able to answer tickets")
+	}
+	if AdminRoleHas("", PermissionUsers) || AdminRoleHas("owner", PermissionUsers) {
+		t.Error("unknown roles must have no permissions")
+	}
+}
+
+func TestResetEmailPrefersVerifiedAddress(t *testing.T) {
+	changed := User{Email: "attacker@example.com", VerifiedEmail: "ana@example.com"}
+	if got := changed.ResetEmail(); got != "ana@example.com" {
+		t.Errorf("ResetEmail = %q, want the verified address", got)
+	}
+
+	legacy := User{Email: "Ana@Example.com"}
+	if got := legacy.ResetEmail(); got != "ana@example.com" {
+		t.Errorf("ResetEmail without a verified address = %q", got)
+	}
+}
@@ -7,8 +7,8 @@ import (
 	"fixify_backend/controller/repairmanfeatures"
 	"fixify_backend/controller/signuplogin"
 	"fixify_backend/controller/userfeatures"
+	"fixify_backend/model/users"
 	"fixify_backend/websocketclient"
-	"log"
 
 	"fixify_backend/websocket"
 
@@ -30,14 +30,34 @@ func AppRoutes(app *fiber.App) {
 	//  AUTH ROUTES
 	// -----------------------------
 
-	app.Post("/signup/admin", signuplogin.AdminSignup)
+	app.Post("/signup/admin", signuplogin.AdminSignup) // Needs an invite_token, except for the very first admin
 	app.Post("/signup/user", signuplogin.UserSignup)
 	app.Post("/signup/repairman", signuplogin.RepairmanSignup)
 
 	app.Post("/login/admin", signuplogin.AdminLogin)
 	app.Post("/login/user", signuplogin.UserLogin)
-
-	app.Post("/login/password/:id", signuplogin.UserLogin) // Still might rename this one
+	app.Post("/login/phone/send", signuplogin.PhoneLoginSend)
+	app.Post("/login/phone", signuplogin.PhoneLogin)
+
+	// Admin two-factor login: POST /login/admin answers with a challenge token when a code is needed
+	app.Post("/login/admin/2fa", signuplogin.AdminTwoFactorLogin)
+	app.Post("/login/admin/2fa/enroll", signuplogin.AdminTwoFactorEnrollStart)
+	app.Post("/login/admin/2fa/enroll/confirm", signuplogin.AdminTwoFactorEnrollConfirm)
+
+	// Admin two-factor settings
+	token.Get("/admin/2fa", signuplogin.AdminOnly, signuplogin.AdminTwoFactorStatus)
+	token.Post("/admin/2fa/enroll", signuplogin.AdminOnly, signuplogin.AdminTwoFactorEnroll)
+	token.Post("/admin/2fa/confirm", signuplogin.AdminOnly, signuplogin.AdminTwoFactorConfirm)
+	token.Post("/admin/2fa/recovery-codes", signuplogin.AdminOnly, signuplogin.AdminTwoFactorRegenerateRecoveryCodes)
+	token.Delete("/admin/2fa", signuplogin.AdminOnly, signuplogin.AdminTwoFactorDisable)
+
+	// Forgot password: send code -> verify code (or open emailed link) -> set new password
+	app.Post("/password/forgot", signuplogin.ForgotPassword)
+	app.Post("/password/verify", signuplogin.VerifyPasswordResetCode)
+	app.Post("/password/reset", signuplogin.ResetPassword)
+	app.Post("/admin/password/forgot", signuplogin.AdminForgotPassword)
+	app.Post("/admin/password/verify", signuplogin.AdminVerifyPasswordResetCode)
+	app.Post("/admin/password/reset", signuplogin.AdminResetPassword)
 
 	token.Post("/logout", signuplogin.JWTLogout)
 
@@ -48,14 +68,28 @@ func AppRoutes(app *fiber.App) {
 	// Email Verification
 	app.Post("/verify/email/send", signuplogin.EmailVer)
 	app.Post("/verify/email/code", signuplogin.EmailVerCode)
-	// app.Post("/verify/email/resend", signuplogin.ResendVerificationCode)
+	app.Post("/verify/email/resend", signuplogin.EmailVer) // Same as send, limited to once a minute
+
+	// Phone Verification (SMS)
+	token.Post("/verify/phone/send", signuplogin.UserOnly, signuplogin.SendPhoneVerification)
+	token.Post("/verify/phone/code", signuplogin.UserOnly, signuplogin.VerifyPhoneCode)
 
-	// Account Verification
-	app.Patch("/verify/account/:id", adminfeatures.VerifyUser)
+	// Account Verification (admin review of ID submissions)
+	app.Patch("/verify/account/:id", signuplogin.JWTMiddleware, signuplogin.RequirePermission(users.PermissionVerifications), adminfeatures.VerifyUser) // By user ID, reviews the pending submission
+	token.Get("/admin/verifications", signuplogin.RequirePermission(users.PermissionVerifications), fetchings.FetchVerificationQueue)
+	token.Get("/admin/verifications/expiring", signuplogin.RequirePermission(users.PermissionVerifications), fetchings.FetchExpiringVerifications)
+	token.Get("/admin/verifications/:id", signuplogin.RequirePermission(users.PermissionVerifications), fetchings.FetchVerification)
+	token.Patch("/admin/verifications/:id", signuplogin.RequirePermission(users.PermissionVerifications), adminfeatures.ReviewVerification)
+	token.Get("/admin/users/:id/verifications", signuplogin.RequirePermission(users.PermissionVerifications), fetchings.FetchUserVerificationHistory)
+
+	// Identity document encryption and access log
+	token.Get("/admin/document-access", signuplogin.RequirePermission(users.PermissionAudit), fetchings.FetchDocumentAccessLog)
+	token.Post("/admin/documents/rotate-keys", signuplogin.RequirePermission(users.PermissionSecurity), adminfeatures.RotateDocumentKeys)
 
 	// Valid ID
-	app.Get("/validIDs", fetchings.FetchAllId)
-	token.Get("/validID", fetchings.FetchValid)
+	app.Get("/validIDs", signuplogin.JWTMiddleware, signuplogin.RequirePermission(users.PermissionVerifications), fetchings.FetchAllId) // Metadata only, images via /admin/verifications/:id
+	token.Get("/validID", signuplogin.UserOnly, fetchings.FetchValid)
+	token.Get("/validID/history", signuplogin.UserOnly, fetchings.FetchValidHistory)
 
 	// -----------------------------
 	//  USERS
@@ -70,7 +104,7 @@ func AppRoutes(app *fiber.App) {
 	// Fetch all repairmen (protected)
 	app.Get("/repairmen", fetchings.FetchAllRepairmen)
 	//review request
-	token.Post("/requests/review/:id", userfeatures.ReviewRequest)
+	token.Post("/requests/review/:id", signuplogin.UserOnly, userfeatures.ReviewRequest)
 
 	//PRIVATE
 	// Fetch all users
@@ -82,7 +116,7 @@ func AppRoutes(app *fiber.App) {
 	// Fetch all repairmen (protected)
 	token.Get("/repairmen", fetchings.FetchAllRepairmen)
 	// Fetch all admin
-	token.Get("/admins", fetchings.FetchAllAdmin)
+	token.Get("/admins", signuplogin.AdminOnly, fetchings.FetchAllAdmin)
 
 	// -----------------------------
 	//  SERVICE REQUESTS
@@ -92,12 +126,12 @@ func AppRoutes(app *fiber.App) {
 	token.Get("/requests", fetchings.FetchAllRequest)
 	app.Get("/requests", fetchings.FetchAllRequest)
 	// Send request (protected)
-	token.Post("/requests/:id", userfeatures.ServiceRequest)
+	token.Post("/requests/:id", signuplogin.UserOnly, userfeatures.ServiceRequest)
 	// Fetch by status
 	token.Get("/requests/completed", fetchings.FetchCompletedRequest)
 	token.Get("/requests/canceled", fetchings.FetchCanceledRequest)
-	// Update request
-	app.Patch("/requests/:id", repairmanfeatures.RequestUpdate)
+	// Update request (assigned repairman, or the client to cancel)
+	token.Patch("/requests/:id", signuplogin.UserOnly, repairmanfeatures.RequestUpdate)
 	// -----------------------------
 	// PERCENTAGE
 	// -----------------------------
@@ -132,14 +166,16 @@ func AppRoutes(app *fiber.App) {
 	// -----------------------------
 
 	// Update account (protected)
-	token.Patch("/account/:id", userfeatures.UpdateAccount)
-	token.Patch("/account/password/:id", userfeatures.UpdateAPassword)
+	token.Patch("/account/:id", signuplogin.UserOnly, userfeatures.UpdateAccount)
+	token.Patch("/account/password/:id", signuplogin.UserOnly, userfeatures.UpdateAPassword)
+	token.Get("/account/export", signuplogin.UserOnly, userfeatures.ExportAccountData) // ?format=zip (default) or json
+	token.Delete("/account", signuplogin.UserOnly, userfeatures.DeleteAccount)
 
 	//Profile
 	// Upload profile picture
-	token.Post("/account/profile-picture", controller.UpdateProfilePicture)
+	token.Post("/account/profile-picture", signuplogin.UserOnly, controller.UpdateProfilePicture)
 
-	token.Delete("/user/verification/delete-if-rejected", userfeatures.DeleteVerificationIfRejected)
+	token.Delete("/user/verification/delete-if-rejected", signuplogin.UserOnly, userfeatures.DeleteVerificationIfRejected)
 
 	// -----------------------------
 	//  SERVICE CATEGORIES
@@ -149,23 +185,30 @@ func AppRoutes(app *fiber.App) {
 	token.Get("/services", fetchings.FetchServices)
 	app.Get("/services", fetchings.FetchServices)
 	//delete service
-	app.Delete("/services/:id", fetchings.DeleteServiceCategory)
+	app.Delete("/services/:id", signuplogin.JWTMiddleware, signuplogin.RequirePermission(users.PermissionServices), fetchings.DeleteServiceCategory)
 	//Disable service
-	app.Patch("/service/disable/:id", fetchings.DisableServiceCategory)
+	app.Patch("/service/disable/:id", signuplogin.JWTMiddleware, signuplogin.RequirePermission(users.PermissionServices), fetchings.DisableServiceCategory)
 	//Service to offer of repairman
-	token.Post("/repairman/services", repairmanfeatures.UpdateRepairmanCategories)
+	token.Post("/repairman/services", signuplogin.UserOnly, repairmanfeatures.UpdateRepairmanCategories)
 	//admin can add service categories
-	token.Post("/admin/services", adminfeatures.AddServiceCategory)
+	token.Post("/admin/services", signuplogin.RequirePermission(users.PermissionServices), adminfeatures.AddServiceCategory)
 	//admin can update service categories
-	app.Patch("/admin/services/:id", adminfeatures.UpdateService)
+	app.Patch("/admin/services/:id", signuplogin.JWTMiddleware, signuplogin.RequirePermission(users.PermissionServices), adminfeatures.UpdateService)
 
 	// -----------------------------
 	// NOTIFICATIONS
 	// -----------------------------
 
 	// Fetch all notifications
-	app.Get("/notifications", fetchings.FetchAllUserNotifications)
-	token.Get("/notifications/user", fetchings.ParamsNotification)
+	app.Get("/notifications", signuplogin.JWTMiddleware, signuplogin.RequirePermission(users.PermissionUsers), fetchings.FetchAllUserNotifications)
+	token.Get("/notifications/user", signuplogin.UserOnly, fetchings.ParamsNotification) // ?limit=&page= to page, ?archived=, ?unread=
+	token.Get("/notifications/unread-count", signuplogin.UserOnly, fetchings.FetchUnreadNotificationCount)
+	token.Patch("/notifications/read-all", signuplogin.UserOnly, userfeatures.MarkAllNotificationsRead)
+	token.Patch("/notifications/:id/read", signuplogin.UserOnly, userfeatures.MarkNotificationRead)
+	token.Patch("/notifications/:id/archive", signuplogin.UserOnly, userfeatures.ArchiveNotification)
+	token.Delete("/notifications/:id", signuplogin.UserOnly, userfeatures.DeleteNotification)
+	token.Get("/notifications/preferences", signuplogin.UserOnly, fetchings.FetchNotificationPreferences)
+	token.Put("/notifications/preferences", signuplogin.UserOnly, userfeatures.UpdateNotificationPreferences)
 
 	// -----------------------------
 	// REVIEWS
@@ -180,27 +223,85 @@ func AppRoutes(app *fiber.App) {
 	// MESSAGES
 	// -----------------------------
 
-	app.Get("/messagesclirep", fetchings.FetchClientRepairmanMessages)
-	app.Get("/conversationsclirep", fetchings.FetchClientRepairmanConversations)
-
-	app.Get("/conversations", fetchings.Conversations)
+	// Participants only; IDs come from the token, not the query string
+	token.Get("/messagesclirep", signuplogin.UserOnly, fetchings.FetchClientRepairmanMessages)
+	// Resume after reconnecting: messages newer than since_id / since
+	token.Get("/messagesclirep/sync", signuplogin.UserOnly, fetchings.SyncClientRepairmanMessages)
+	// Chat attachments (photos and PDFs)
+	token.Post("/messagesclirep/attachments", signuplogin.UserOnly, userfeatures.UploadChatAttachment)
+	token.Get("/messagesclirep/attachments/:id", signuplogin.UserOnly, fetchings.FetchChatAttachment)
+	token.Get("/conversationsclirep", signuplogin.UserOnly, fetchings.FetchClientRepairmanConversations)
+
+	// All client-repairman conversations (admin monitoring)
+	token.Get("/conversations", signuplogin.RequirePermission(users.PermissionModeration), fetchings.Conversations)
+
+	// Blocking and reporting
+	token.Get("/blocks", signuplogin.UserOnly, fetchings.FetchBlockedUsers)
+	token.Post("/users/:id/block", signuplogin.UserOnly, userfeatures.BlockUser)
+	token.Delete("/users/:id/block", signuplogin.UserOnly, userfeatures.UnblockUser)
+	token.Post("/messagesclirep/:id/report", signuplogin.UserOnly, userfeatures.ReportMessage)
+
+	// Moderation queue (admin)
+	token.Get("/admin/reports", signuplogin.RequirePermission(users.PermissionModeration), fetchings.FetchMessageReports)
+	token.Patch("/admin/reports/:id", signuplogin.RequirePermission(users.PermissionModeration), adminfeatures.ReviewMessageReport)
+
+	// Login lockouts
+	token.Get("/admin/login-locks", signuplogin.RequirePermission(users.PermissionUsers), fetchings.FetchLoginLocks)
+	token.Delete("/admin/login-locks/:id", signuplogin.RequirePermission(users.PermissionUsers), adminfeatures.UnlockLogin)
+
+	// Account suspension and bans
+	token.Get("/admin/users/restricted", signuplogin.RequirePermission(users.PermissionUsers), fetchings.FetchRestrictedUsers)
+	token.Patch("/admin/users/:id/status", signuplogin.RequirePermission(users.PermissionUsers), adminfeatures.SetAccountStatus)
+
+	// User management console
+	token.Get("/admin/users", signuplogin.RequirePermission(users.PermissionUsers), fetchings.SearchUsers)           // ?q=&type=&status=&limit=&offset=
+	token.Get("/admin/users/:id", signuplogin.RequirePermission(users.PermissionUsers), fetchings.FetchUserOverview) // Profile, requests, reviews and payments
+	token.Patch("/admin/users/:id", signuplogin.RequirePermission(users.PermissionUsers), adminfeatures.AdminUpdateUser)
+	token.Post("/admin/users/:id/password-reset", signuplogin.RequirePermission(users.PermissionUsers), adminfeatures.ForceUserPasswordReset)
+	token.Patch("/admin/users/:id/type", signuplogin.RequirePermission(users.PermissionUsers), adminfeatures.ChangeUserType)
+
+	// Admin team: invites, roles and disabling (super admin)
+	token.Get("/admin/team", signuplogin.RequirePermission(users.PermissionAdmins), fetchings.FetchAdminTeam)
+	token.Patch("/admin/team/:id", signuplogin.RequirePermission(users.PermissionAdmins), adminfeatures.UpdateAdminMember)
+	token.Get("/admin/invites", signuplogin.RequirePermission(users.PermissionAdmins), fetchings.FetchAdminInvites) // ?status=all for used and expired ones too
+	token.Post("/admin/invites", signuplogin.RequirePermission(users.PermissionAdmins), adminfeatures.CreateAdminInvite)
+	token.Delete("/admin/invites/:id", signuplogin.RequirePermission(users.PermissionAdmins), adminfeatures.RevokeAdminInvite)
+
+	// Payments (finance)
+	token.Get("/admin/payments", signuplogin.RequirePermission(users.PermissionFinance), fetchings.FetchPayments)
+
+	// Support tickets
+	token.Post("/support/tickets", signuplogin.UserOnly, userfeatures.CreateSupportTicket)
+	token.Get("/support/tickets", signuplogin.UserOnly, fetchings.FetchMySupportTickets)
+	token.Get("/support/tickets/:id", signuplogin.UserOnly, fetchings.FetchMySupportTicket)
+	token.Post("/support/tickets/:id/messages", signuplogin.UserOnly, userfeatures.ReplySupportTicket)
+	token.Post("/support/tickets/:id/close", signuplogin.UserOnly, userfeatures.CloseSupportTicket)
+	token.Get("/admin/support/tickets", signuplogin.RequirePermission(users.PermissionSupport), fetchings.FetchSupportTickets) // Open and pending by next SLA deadline
+	token.Get("/admin/support/tickets/:id", signuplogin.RequirePermission(users.PermissionSupport), fetchings.FetchSupportTicket)
+	token.Post("/admin/support/tickets/:id/messages", signuplogin.RequirePermission(users.PermissionSupport), adminfeatures.AnswerSupportTicket)
+	token.Patch("/admin/support/tickets/:id", signuplogin.RequirePermission(users.PermissionSupport), adminfeatures.UpdateSupportTicket)
+
+	// Audit log
+	token.Get("/admin/audit-logs", signuplogin.RequirePermission(users.PermissionAudit), fetchings.FetchAuditLog)
+	token.Get("/admin/audit-logs/export", signuplogin.RequirePermission(users.PermissionAudit), fetchings.ExportAuditLog) // CSV, same filters
 
 	// Add conversation
-	token.Get("/conversations/available", adminfeatures.FetchAvailableAdminsForConversation)
+	token.Get("/conversations/available", signuplogin.AdminOnly, adminfeatures.FetchAvailableAdminsForConversation)
 
 	// -----------------------------
 	// Upload
 	// -----------------------------
 
-	token.Patch("/users/:user_id/profile-picture", userfeatures.PatchProfilePicture)
-	token.Post("/user/document/:id", userfeatures.UploadIDCardAndSelfie)
-	token.Get("/user/profile-picture", userfeatures.GetProfilePicture)
+	token.Patch("/users/:user_id/profile-picture", signuplogin.UserOnly, userfeatures.PatchProfilePicture)
+	token.Post("/user/document/:id", signuplogin.UserOnly, userfeatures.UploadIDCardAndSelfie)
+	token.Get("/user/profile-picture", signuplogin.UserOnly, userfeatures.GetProfilePicture)
 
 	// -----------------------------
 	// GCASH
 	// -----------------------------
-	token.Post("/gcash/pay", controller.InitiateXenditGCash)
-	token.Post("/gcash/save", controller.SaveGCashInfo)
+	token.Post("/gcash/pay", signuplogin.UserOnly, userfeatures.InitiateXenditGCash)
+	app.Post("/gcash/callback", userfeatures.XenditGCashCallback) // Xendit e-wallet charge updates, checked against XENDIT_CALLBACK_TOKEN
+	token.Post("/gcash/save", signuplogin.UserOnly, controller.SaveGCashInfo)
 
 	// 🧠 Initialize the WebSocket Hub
 	go websocket.HubInstance.Run()
@@ -215,61 +316,11 @@ func AppRoutes(app *fiber.App) {
 	app.Use("/ws/client", websocketclient.WebSocketHandler)
 	app.Get("/ws/client", fiberws.New(websocketclient.WebSocketUpgrade))
 
-	app.Post("/api/register-fcm-token", func(c *fiber.Ctx) error {
-		type request struct {
-			UserID   uint   `json:"user_id"`
-			FCMToken string `json:"fcm_token"`
-		}
-
-		var req request
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Invalid request",
-			})
-		}
-
-		if err := websocket.FCMInstance.RegisterToken(req.UserID, req.FCMToken); err != nil {
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to register token",
-			})
-		}
-
-		return c.JSON(fiber.Map{
-			"success": true,
-		})
-	})
-
-	app.Post("/register_fcm_token", func(c *fiber.Ctx) error {
-		if websocketclient.FCMInstance == nil {
-			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
-				"error": "FCM service not initialized. Please contact support.",
-			})
-		}
-
-		type request struct {
-			UserID   uint   `json:"user_id"`
-			FCMToken string `json:"fcm_token"`
-		}
-
-		var req request
-		if err := c.BodyParser(&req); err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "Invalid request body",
-			})
-		}
-
-		if err := websocketclient.FCMInstance.RegisterToken(req.UserID, req.FCMToken); err != nil {
-			log.Printf("Failed to register FCM token: %v", err)
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to register token",
-			})
-		}
-
-		return c.JSON(fiber.Map{
-			"success": true,
-			"message": "FCM token registered successfully",
-		})
-	})
+	// Device tokens for push notifications. The two legacy paths are kept for older app builds.
+	token.Post("/devices", userfeatures.RegisterDevice)
+	token.Delete("/devices", userfeatures.UnregisterDevice)
+	app.Post("/api/register-fcm-token", signuplogin.JWTMiddleware, userfeatures.RegisterDevice)
+	app.Post("/register_fcm_token", signuplogin.JWTMiddleware, userfeatures.RegisterDevice)
 	// Add this to your routes
 
 }
@@ -0,0 +1,54 @@
+package sms
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// NormalizePhone converts the ways Philippine mobile numbers are usually typed (09171234567,
+// 639171234567, +63 917 123 4567) to E.164 (+639171234567). Other countries must already be in
+// international format.
+func NormalizePhone(phone string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+
+	international := strings.HasPrefix(digits, "+")
+	digits = strings.TrimPrefix(digits, "+")
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", ErrInvalidPhone
+		}
+	}
+
+	switch {
+	case !international && len(digits) == 11 && strings.HasPrefix(digits, "09"):
+		return "+63" + digits[1:], nil
+	case !international && len(digits) == 10 && strings.HasPrefix(digits, "9"):
+		return "+63" + digits, nil
+	case len(digits) == 12 && strings.HasPrefix(digits, "639"):
+		return "+" + digits, nil
+	case international && !strings.HasPrefix(digits, "63") && len(digits) >= 8 && len(digits) <= 15:
+		return "+" + digits, nil
+	}
+	return "", ErrInvalidPhone
+}
+
+// PhoneVariants lists the forms a normalized number may have been stored in before numbers were
+// normalized, for looking up existing accounts
+func PhoneVariants(normalized string) []string {
+	variants := []string{normalized}
+	if strings.HasPrefix(normalized, "+63") && len(normalized) == 13 {
+		local := normalized[3:]
+		variants = append(variants, "0"+local, "63"+local, local)
+	}
+	return variants
+}
@@ -0,0 +1,194 @@
+package sms
+
+import (
+	"bytes"
+	"encoding/json"
+	"fixify_backend/middleware"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// SMS providers selectable with SMS_PROVIDER
+const (
+	ProviderSemaphore = "semaphore"
+	ProviderConsole   = "console"
+	ProviderFake      = "fake"
+)
+
+// Message is a text message ready to send. To is an E.164 number (see NormalizePhone).
+type Message struct {
+	To   string
+	Body string
+}
+
+// SMSSender delivers text messages. Semaphore is used in production; the console and fake
+// senders let the server run without an SMS account and let tests read the codes that were sent.
+type SMSSender interface {
+	Send(msg Message) error
+}
+
+// SemaphoreSender sends through the Semaphore SMS gateway (semaphore.co)
+type SemaphoreSender struct {
+	APIKey     string
+	SenderName string
+	Endpoint   string
+	Client     *http.Client
+}
+
+// NewSemaphoreSenderFromEnv reads SEMAPHORE_API_KEY and the optional SMS_SENDER_NAME
+func NewSemaphoreSenderFromEnv() (*SemaphoreSender, error) {
+	apiKey := middleware.GetEnv("SEMAPHORE_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("SEMAPHORE_API_KEY must be set")
+	}
+
+	return &SemaphoreSender{
+		APIKey:     apiKey,
+		SenderName: middleware.GetEnv("SMS_SENDER_NAME"),
+		Endpoint:   "https://api.semaphore.co/api/v4/messages",
+		Client:     &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (s *SemaphoreSender) Send(msg Message) error {
+	form := url.Values{}
+	form.Set("apikey", s.APIKey)
+	form.Set("number", msg.To)
+	form.Set("message", msg.Body)
+	if s.SenderName != "" {
+		form.Set("sendername", s.SenderName)
+	}
+
+	resp, err := s.Client.PostForm(s.Endpoint, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var body bytes.Buffer
+		body.ReadFrom(resp.Body)
+		return fmt.Errorf("semaphore returned %s: %s", resp.Status, body.String())
+	}
+
+	// Semaphore answers 200 with a list of queued messages, or an object describing the error
+	var queued []json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil {
+		return fmt.Errorf("semaphore rejected the message: %v", err)
+	}
+	return nil
+}
+
+// ConsoleSender writes each message to the log instead of sending it, for local development
+type ConsoleSender struct{}
+
+func (ConsoleSender) Send(msg Message) error {
+	log.Printf("SMS to %s: %s", msg.To, msg.Body)
+	return nil
+}
+
+// FakeSender keeps every message in memory so tests can read the codes that were sent
+type FakeSender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewFakeSender() *FakeSender {
+	return &FakeSender{}
+}
+
+func (s *FakeSender) Send(msg Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far
+func (s *FakeSender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// Reset forgets sent messages
+func (s *FakeSender) Reset() {
+	s.mu.Lock()
+	s.messages = nil
+	s.mu.Unlock()
+}
+
+// unavailableSender fails every send until the config is fixed
+type unavailableSender struct {
+	err error
+}
+
+func (s unavailableSender) Send(msg Message) error {
+	return s.err
+}
+
+var (
+	current     SMSSender
+	currentOnce sync.Once
+	currentMu   sync.RWMutex
+)
+
+// Default returns the sender selected by SMS_PROVIDER (semaphore, console or fake). Without it,
+// Semaphore is used when SEMAPHORE_API_KEY is set and messages are logged otherwise.
+func Default() SMSSender {
+	currentOnce.Do(func() {
+		currentMu.Lock()
+		defer currentMu.Unlock()
+		if current == nil {
+			current = fromEnv()
+		}
+	})
+
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// SetDefault replaces the sender, e.g. with a FakeSender in tests
+func SetDefault(s SMSSender) {
+	currentMu.Lock()
+	current = s
+	currentMu.Unlock()
+}
+
+// Send delivers a message with the default sender
+func Send(to string, body string) error {
+	return Default().Send(Message{To: to, Body: body})
+}
+
+func fromEnv() SMSSender {
+	provider := middleware.GetEnv("SMS_PROVIDER")
+	if provider == "" {
+		provider = ProviderConsole
+		if middleware.GetEnv("SEMAPHORE_API_KEY") != "" {
+			provider = ProviderSemaphore
+		}
+	}
+
+	switch provider {
+	case ProviderSemaphore:
+		s, err := NewSemaphoreSenderFromEnv()
+		if err != nil {
+			log.Printf("SMS: Semaphore not configured, text messages will fail: %v", err)
+			return unavailableSender{err: err}
+		}
+		return s
+	case ProviderConsole:
+		return ConsoleSender{}
+	case ProviderFake:
+		return NewFakeSender()
+	}
+
+	err := fmt.Errorf("unknown SMS_PROVIDER %q", provider)
+	log.Printf("SMS: %v", err)
+	return unavailableSender{err: err}
+}
@@ -0,0 +1,318 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fixify_backend/middleware"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// Envelope encryption for identity documents. Every document is encrypted with its own random
+// data key (AES-256-GCM), and the data key is encrypted ("wrapped") with a key-encryption key
+// from DOCUMENT_KEYS. Rotating keys only re-wraps the small data keys, not the documents.
+//
+// Sealed blob layout:
+//
+//	magic "FXV1" | key id length (1) | key id | wrapped key length (2) | wrapped key | nonce | ciphertext
+//
+// Data without the magic prefix is treated as a document stored before encryption was enabled.
+
+var magic = []byte("FXV1")
+
+var (
+	ErrNoKeys     = errors.New("no document encryption keys configured")
+	ErrUnknownKey = errors.New("document was sealed with an unknown key")
+	ErrCorrupt    = errors.New("sealed document is corrupt")
+)
+
+// Keyring holds the key-encryption keys by ID and the one used for new documents
+type Keyring struct {
+	keys   map[string][]byte
+	active string
+}
+
+// ParseKeyring reads "id:base64key,id2:base64key" (32-byte keys) and the active key ID
+func ParseKeyring(spec string, active string) (*Keyring, error) {
+	ring := &Keyring{keys: map[string][]byte{}, active: strings.TrimSpace(active)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || len(id) > 32 {
+			return nil, fmt.Errorf("invalid DOCUMENT_KEYS entry %q", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("document key %q must be 32 bytes, base64 encoded", id)
+		}
+		ring.keys[id] = key
+	}
+
+	if len(ring.keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	if ring.active == "" && len(ring.keys) == 1 {
+		for id := range ring.keys {
+			ring.active = id
+		}
+	}
+	if _, ok := ring.keys[ring.active]; !ok {
+		return nil, fmt.Errorf("DOCUMENT_ACTIVE_KEY %q is not in DOCUMENT_KEYS", ring.active)
+	}
+	return ring, nil
+}
+
+// ActiveKeyID is the key new documents are sealed with
+func (r *Keyring) ActiveKeyID() string {
+	return r.active
+}
+
+// Seal encrypts a document with a fresh data key wrapped by the active key
+func (r *Keyring) Seal(plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := gcmSeal(r.keys[r.active], dataKey)
+	if err != nil {
+		return nil, err
+	}
+	body, err := gcmSeal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	return assemble(r.active, wrapped, body), nil
+}
+
+// Open decrypts a sealed document. Data stored before encryption is returned unchanged.
+func (r *Keyring) Open(data []byte) ([]byte, error) {
+	if !IsSealed(data) {
+		return data, nil
+	}
+
+	keyID, wrapped, body, err := split(data)
+	if err != nil {
+		return nil, err
+	}
+	kek, ok := r.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	dataKey, err := gcmOpen(kek, wrapped)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	plaintext, err := gcmOpen(dataKey, body)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plaintext, nil
+}
+
+// Rewrap moves a document onto the active key. Plaintext documents are sealed. It reports false
+// when the document already uses the active key.
+func (r *Keyring) Rewrap(data []byte) ([]byte, bool, error) {
+	if len(data) == 0 {
+		return data, false, nil
+	}
+	if !IsSealed(data) {
+		sealed, err := r.Seal(data)
+		return sealed, err == nil, err
+	}
+
+	keyID, wrapped, body, err := split(data)
+	if err != nil {
+		return nil, false, err
+	}
+	if keyID == r.active {
+		return data, false, nil
+	}
+	kek, ok := r.keys[keyID]
+	if !ok {
+		return nil, false, ErrUnknownKey
+	}
+
+	dataKey, err := gcmOpen(kek, wrapped)
+	if err != nil {
+		return nil, false, ErrCorrupt
+	}
+	rewrapped, err := gcmSeal(r.keys[r.active], dataKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return assemble(r.active, rewrapped, body), true, nil
+}
+
+// IsSealed reports whether data was produced by Seal
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// KeyID returns the ID of the key a sealed document is wrapped with, or "" for plaintext
+func KeyID(data []byte) string {
+	if !IsSealed(data) {
+		return ""
+	}
+	keyID, _, _, err := split(data)
+	if err != nil {
+		return ""
+	}
+	return keyID
+}
+
+func assemble(keyID string, wrapped []byte, body []byte) []byte {
+	var out bytes.Buffer
+	out.Write(magic)
+	out.WriteByte(byte(len(keyID)))
+	out.WriteString(keyID)
+	binary.Write(&out, binary.BigEndian, uint16(len(wrapped)))
+	out.Write(wrapped)
+	out.Write(body)
+	return out.Bytes()
+}
+
+func split(data []byte) (string, []byte, []byte, error) {
+	rest := data[len(magic):]
+	if len(rest) < 1 {
+		return "", nil, nil, ErrCorrupt
+	}
+	idLen := int(rest[0])
+	rest = rest[1:]
+	if len(rest) < idLen+2 {
+		return "", nil, nil, ErrCorrupt
+	}
+	keyID := string(rest[:idLen])
+	rest = rest[idLen:]
+
+	wrappedLen := int(binary.BigEndian.Uint16(rest[:2]))
+	rest = rest[2:]
+	if len(rest) < wrappedLen {
+		return "", nil, nil, ErrCorrupt
+	}
+	return keyID, rest[:wrappedLen], rest[wrappedLen:], nil
+}
+
+// gcmSeal returns nonce | ciphertext
+func gcmSeal(key []byte, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func gcmOpen(key []byte, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+var (
+	defaultRing *Keyring
+	defaultErr  error
+	defaultOnce sync.Once
+)
+
+// DerivedKeyID is the ID of the development key derived from JWT_SECRET_KEY
+const DerivedKeyID = "derived"
+
+// Default returns the keyring configured with DOCUMENT_KEYS and DOCUMENT_ACTIVE_KEY. It fails
+// without DOCUMENT_KEYS unless DOCUMENT_KEYS_ALLOW_DERIVED=true, a development switch that adds a
+// key derived from JWT_SECRET_KEY.
+func Default() (*Keyring, error) {
+	defaultOnce.Do(func() {
+		spec, err := keyringSpec(
+			middleware.GetEnv("DOCUMENT_KEYS"),
+			middleware.GetEnv("DOCUMENT_KEYS_ALLOW_DERIVED") == "true",
+			middleware.GetEnv("JWT_SECRET_KEY"),
+		)
+		if err != nil {
+			defaultErr = err
+		} else {
+			defaultRing, defaultErr = ParseKeyring(spec, middleware.GetEnv("DOCUMENT_ACTIVE_KEY"))
+		}
+		if defaultErr != nil {
+			log.Printf("Vault: %v", defaultErr)
+		}
+	})
+	return defaultRing, defaultErr
+}
+
+// keyringSpec adds the key derived from the JWT secret to the configured keys when allowed. Next
+// to configured keys it only opens documents sealed during development, so they can be rotated
+// onto a real key before the switch is turned off.
+func keyringSpec(spec string, allowDerived bool, jwtSecret string) (string, error) {
+	if !allowDerived {
+		if strings.TrimSpace(spec) == "" {
+			return "", ErrNoKeys
+		}
+		return spec, nil
+	}
+
+	if jwtSecret == "" {
+		return "", fmt.Errorf("DOCUMENT_KEYS_ALLOW_DERIVED needs JWT_SECRET_KEY")
+	}
+	log.Println("Vault: DOCUMENT_KEYS_ALLOW_DERIVED is set, documents can use a key derived from JWT_SECRET_KEY; do not use this in production")
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("document-encryption"))
+	derived := DerivedKeyID + ":" + base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if strings.TrimSpace(spec) == "" {
+		return derived, nil
+	}
+	return spec + "," + derived, nil
+}
+
+// Seal encrypts with the default keyring
+func Seal(plaintext []byte) ([]byte, error) {
+	ring, err := Default()
+	if err != nil {
+		return nil, err
+	}
+	return ring.Seal(plaintext)
+}
+
+// Open decrypts with the default keyring
+func Open(data []byte) ([]byte, error) {
+	ring, err := Default()
+	if err != nil {
+		if !IsSealed(data) {
+			return data, nil
+		}
+		return nil, err
+	}
+	return ring.Open(data)
+}
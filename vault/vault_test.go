@@ -0,0 +1,180 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func mustKeyring(t *testing.T, spec, active string) *Keyring {
+	t.Helper()
+	ring, err := ParseKeyring(spec, active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ring
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	ring := mustKeyring(t, "k1:"+newKey(t), "")
+	document := []byte("front of an ID card")
+
+	sealed, err := ring.Seal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || KeyID(sealed) != "k1" {
+		t.Fatalf("sealed blob has key %q", KeyID(sealed))
+	}
+	if bytes.Contains(sealed, document) {
+		t.Fatal("sealed blob contains the plaintext")
+	}
+
+	opened, err := ring.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened, document) {
+		t.Fatalf("opened %q, want %q", opened, document)
+	}
+}
+
+func TestOpenPassesThroughUnsealedData(t *testing.T) {
+	ring := mustKeyring(t, "k1:"+newKey(t), "")
+	legacy := []byte("\xff\xd8\xff stored before encryption")
+
+	opened, err := ring.Open(legacy)
+	if err != nil || !bytes.Equal(opened, legacy) {
+		t.Fatalf("Open(legacy) = %q, %v", opened, err)
+	}
+}
+
+func TestOpenRejectsTamperedBlobs(t *testing.T) {
+	ring := mustKeyring(t, "k1:"+newKey(t), "")
+	sealed, err := ring.Seal([]byte("selfie"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit in the wrapped key, and in the last byte of the document ciphertext
+	wrappedByte := len(magic) + 1 + len("k1") + 2
+	for _, i := range []int{wrappedByte, len(sealed) - 1} {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 0x01
+		if _, err := ring.Open(tampered); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("byte %d tampered: err = %v, want ErrCorrupt", i, err)
+		}
+	}
+
+	if _, err := ring.Open(sealed[:len(magic)+3]); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("truncated blob: err = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestOpenRejectsUnknownKey(t *testing.T) {
+	sealed, err := mustKeyring(t, "old:"+newKey(t), "").Seal([]byte("back of an ID card"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := mustKeyring(t, "new:"+newKey(t), "")
+	if _, err := other.Open(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v, want ErrUnknownKey", err)
+	}
+	if _, _, err := other.Rewrap(sealed); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Rewrap err = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestRewrapMovesDocumentsToActiveKey(t *testing.T) {
+	oldKey, newKeyValue := newKey(t), newKey(t)
+	document := []byte("ID document")
+
+	sealed, err := mustKeyring(t, "k1:"+oldKey, "").Seal(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := mustKeyring(t, "k1:"+oldKey+",k2:"+newKeyValue, "k2")
+	rewrapped, changed, err := rotated.Rewrap(sealed)
+	if err != nil || !changed {
+		t.Fatalf("Rewrap = changed %v, err %v", changed, err)
+	}
+	if KeyID(rewrapped) != "k2" {
+		t.Fatalf("rewrapped with %q, want k2", KeyID(rewrapped))
+	}
+
+	// Once the old key is retired the document still opens
+	opened, err := mustKeyring(t, "k2:"+newKeyValue, "").Open(rewrapped)
+	if err != nil || !bytes.Equal(opened, document) {
+		t.Fatalf("Open after rotation = %q, %v", opened, err)
+	}
+
+	// Already on the active key: nothing to do
+	again, changed, err := rotated.Rewrap(rewrapped)
+	if err != nil || changed || !bytes.Equal(again, rewrapped) {
+		t.Fatalf("second Rewrap = changed %v, err %v", changed, err)
+	}
+
+	// Plaintext from before encryption gets sealed
+	legacy, changed, err := rotated.Rewrap([]byte("legacy"))
+	if err != nil || !changed || KeyID(legacy) != "k2" {
+		t.Fatalf("Rewrap(plaintext) = key %q, changed %v, err %v", KeyID(legacy), changed, err)
+	}
+}
+
+func TestParseKeyringValidation(t *testing.T) {
+	key := newKey(t)
+	tests := []struct {
+		name   string
+		spec   string
+		active string
+	}{
+		{"no keys", "", ""},
+		{"missing id", ":" + key, ""},
+		{"short key", "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), ""},
+		{"active key not configured", "k1:" + key, "k2"},
+		{"several keys without an active one", "k1:" + key + ",k2:" + newKey(t), ""},
+	}
+	for _, tt := range tests {
+		if _, err := ParseKeyring(tt.spec, tt.active); err == nil {
+			t.Errorf("%s: accepted", tt.name)
+		}
+	}
+}
+
+func TestKeyringSpecFailsClosed(t *testing.T) {
+	if _, err := keyringSpec("", false, "jwt-secret"); !errors.Is(err, ErrNoKeys) {
+		t.Fatalf("without DOCUMENT_KEYS: err = %v, want ErrNoKeys", err)
+	}
+	if _, err := keyringSpec("", true, ""); err == nil {
+		t.Fatal("derived key allowed without a JWT secret")
+	}
+
+	configured := "k1:" + newKey(t)
+	spec, err := keyringSpec(configured, false, "jwt-secret")
+	if err != nil || spec != configured {
+		t.Fatalf("configured keys changed to %q, %v", spec, err)
+	}
+
+	// The development switch adds the derived key next to configured ones
+	spec, err = keyringSpec(configured, true, "jwt-secret")
+	if err != nil || !strings.HasPrefix(spec, configured+","+DerivedKeyID+":") {
+		t.Fatalf("spec = %q, %v", spec, err)
+	}
+	if _, err := ParseKeyring(spec, "k1"); err != nil {
+		t.Fatal(err)
+	}
+}
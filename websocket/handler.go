@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"fixify_backend/controller/signuplogin"
 	"fixify_backend/model/users"
+	"fixify_backend/websocketclient"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
@@ -42,6 +44,11 @@ func WebSocketHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusUnauthorized).SendString("Invalid token")
 	}
 
+	// Admin chat is only open to admin tokens
+	if !claims.IsAdmin() {
+		return c.Status(fiber.StatusForbidden).SendString("Admin access required")
+	}
+
 	c.Locals("admin_id", claims.UserId)
 	return c.Next()
 }
@@ -144,6 +151,20 @@ func WebSocketUpgrade(c *websocket.Conn) {
 				ToAdminID:   toAdminID,
 				Content:     string(msg),
 			}
+
+			// Push to admins that are not connected
+			if !HubInstance.IsOnline(toAdminID) {
+				go func(toAdminID uint, content string) {
+					data := map[string]string{
+						"type":            "admin_message",
+						"conversation_id": strconv.FormatUint(uint64(conversation.ID), 10),
+						"sender_id":       strconv.FormatUint(uint64(adminID), 10),
+					}
+					if err := websocketclient.SendAdminPushNotification(toAdminID, "New Message", content, data); err != nil {
+						log.Printf("FCM failed to admin %d: %v", toAdminID, err)
+					}
+				}(toAdminID, incomingMsg.Content)
+			}
 		}
 	}()
 
@@ -59,6 +59,15 @@ func (h *Hub) Run() {
 		}
 	}
 }
+
+// IsOnline reports whether the admin currently has an open chat connection
+func (h *Hub) IsOnline(adminID uint) bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	_, ok := h.clients[adminID]
+	return ok
+}
+
 func (Message) TableName() string {
 	return "messages" // Explicitly define the table name here
 }
@@ -10,7 +10,12 @@ import (
 	"gorm.io/gorm"
 )
 
-func EnsureClientRepairmanConversation(clientID uint, repairmanID uint) (uint, error) {
+const welcomeMessageText = "Hello! I've accepted your service request. Let's discuss the details."
+
+// EnsureClientRepairmanConversation returns the conversation for a service request, creating it
+// (with the repairman's welcome message) the first time the request is accepted. Each request gets
+// its own conversation so history for different jobs is not mixed together.
+func EnsureClientRepairmanConversation(clientID uint, repairmanID uint, requestID int) (uint, error) {
 	db := middleware.DBConn
 
 	if db == nil {
@@ -26,109 +31,51 @@ func EnsureClientRepairmanConversation(clientID uint, repairmanID uint) (uint, e
 		}
 	}()
 
-	var conversationID uint
 	var existingConvo users.ClientRepairmanConversation
-	err := tx.Where("client_id = ? AND repairman_id = ?", clientID, repairmanID).
-		First(&existingConvo).Error
+	err := tx.Where("request_id = ?", requestID).First(&existingConvo).Error
 
 	if err == nil {
-		// Conversation exists - always create welcome message
-		conversationID = existingConvo.ConversationId
-		log.Printf("Conversation found - ID: %d, Client: %d, Repairman: %d",
-			conversationID, clientID, repairmanID)
-
-		// Create welcome message regardless of existing messages
-		welcomeMessage := users.ClientRepairmanMessage{
-			ConversationId: conversationID,
-			SenderId:       repairmanID,
-			Message:        "Hello! I've accepted your service request. Let's discuss the details.",
-			CreatedAt:      time.Now(),
-		}
-
-		if err := tx.Create(&welcomeMessage).Error; err != nil {
-			tx.Rollback()
-			log.Printf("Failed to create welcome message: %v", err)
-			return 0, fmt.Errorf("failed to create welcome message: %v", err)
-		}
-
-		// Send notification to client
-		go func() {
-			notificationTitle := "New message from repairman"
-			notificationBody := welcomeMessage.Message
-			data := map[string]string{
-				"conversation_id": fmt.Sprintf("%d", conversationID),
-				"type":            "new_message",
-				"sender_id":       fmt.Sprintf("%d", repairmanID),
-			}
-
-			if err := SendPushNotification(
-				clientID,
-				notificationTitle,
-				notificationBody,
-				data,
-			); err != nil {
-				log.Printf("Failed to send notification: %v", err)
-			}
-		}()
-
-		log.Printf("Created welcome message for conversation %d", conversationID)
-	} else if err == gorm.ErrRecordNotFound {
-		// Create new conversation
-		newConvo := users.ClientRepairmanConversation{
-			ClientId:    clientID,
-			RepairmanId: repairmanID,
-			CreatedAt:   time.Now(),
-		}
-
-		if err := tx.Create(&newConvo).Error; err != nil {
-			tx.Rollback()
-			log.Printf("Failed to create conversation: %v", err)
-			return 0, fmt.Errorf("failed to create conversation: %v", err)
-		}
+		// Request was accepted before - reuse the conversation without repeating the welcome message
+		tx.Rollback()
+		log.Printf("Conversation found - ID: %d, Request: %d", existingConvo.ConversationId, requestID)
+		return existingConvo.ConversationId, nil
+	} else if err != gorm.ErrRecordNotFound {
+		tx.Rollback()
+		log.Printf("Error checking conversation: %v", err)
+		return 0, fmt.Errorf("error checking conversation: %v", err)
+	}
 
-		conversationID = newConvo.ConversationId
-		log.Printf("Created new conversation - ID: %d, Client: %d, Repairman: %d",
-			conversationID, clientID, repairmanID)
+	// Create new conversation
+	newConvo := users.ClientRepairmanConversation{
+		ClientId:    clientID,
+		RepairmanId: repairmanID,
+		RequestId:   &requestID,
+		CreatedAt:   time.Now(),
+	}
 
-		// Create welcome message
-		welcomeMessage := users.ClientRepairmanMessage{
-			ConversationId: conversationID,
-			SenderId:       repairmanID,
-			Message:        "Hello! I've accepted your service request. Let's discuss the details.",
-			CreatedAt:      time.Now(),
-		}
+	if err := tx.Create(&newConvo).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Failed to create conversation: %v", err)
+		return 0, fmt.Errorf("failed to create conversation: %v", err)
+	}
 
-		if err := tx.Create(&welcomeMessage).Error; err != nil {
-			tx.Rollback()
-			log.Printf("Failed to create welcome message: %v", err)
-			return 0, fmt.Errorf("failed to create welcome message: %v", err)
-		}
+	conversationID := newConvo.ConversationId
+	log.Printf("Created new conversation - ID: %d, Client: %d, Repairman: %d, Request: %d",
+		conversationID, clientID, repairmanID, requestID)
+
+	// Create welcome message
+	welcomeMessage := users.ClientRepairmanMessage{
+		ConversationId: conversationID,
+		SenderId:       repairmanID,
+		Message:        welcomeMessageText,
+		MessageType:    users.MessageTypeText,
+		CreatedAt:      time.Now(),
+	}
 
-		// Send notification to client
-		go func() {
-			notificationTitle := "New message from repairman"
-			notificationBody := welcomeMessage.Message
-			data := map[string]string{
-				"conversation_id": fmt.Sprintf("%d", conversationID),
-				"type":            "new_message",
-				"sender_id":       fmt.Sprintf("%d", repairmanID),
-			}
-
-			if err := SendPushNotification(
-				clientID,
-				notificationTitle,
-				notificationBody,
-				data,
-			); err != nil {
-				log.Printf("Failed to send notification: %v", err)
-			}
-		}()
-
-		log.Printf("Created welcome message for new conversation %d", conversationID)
-	} else {
+	if err := tx.Create(&welcomeMessage).Error; err != nil {
 		tx.Rollback()
-		log.Printf("Error checking conversation: %v", err)
-		return 0, fmt.Errorf("error checking conversation: %v", err)
+		log.Printf("Failed to create welcome message: %v", err)
+		return 0, fmt.Errorf("failed to create welcome message: %v", err)
 	}
 
 	if err := tx.Commit().Error; err != nil {
@@ -136,6 +83,29 @@ func EnsureClientRepairmanConversation(clientID uint, repairmanID uint) (uint, e
 		return 0, fmt.Errorf("failed to commit transaction: %v", err)
 	}
 
-	log.Printf("Successfully processed conversation for client %d and repairman %d", clientID, repairmanID)
+	// Send notification to client
+	if err := Notify(db, Notification{
+		Category:  users.NotificationCategoryChat,
+		Type:      "new_message",
+		RequestId: requestID,
+		FromUser:  repairmanID,
+		ToUser:    clientID,
+		Title:     "New message from repairman",
+		Body:      welcomeMessage.Message,
+		Data:      chatNotificationData(welcomeMessage, welcomeMessage.Message),
+	}); err != nil {
+		log.Printf("Failed to send notification: %v", err)
+	}
+
+	log.Printf("Successfully processed conversation for request %d", requestID)
 	return conversationID, nil
 }
+
+// LockRequestConversation makes the conversation of a finished request read-only
+func LockRequestConversation(requestID int) error {
+	db := middleware.DBConn
+
+	return db.Model(&users.ClientRepairmanConversation{}).
+		Where("request_id = ?", requestID).
+		Update("is_read_only", true).Error
+}
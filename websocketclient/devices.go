@@ -0,0 +1,51 @@
+package websocketclient
+
+import (
+	"fixify_backend/model/users"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RegisterDevice stores or refreshes an FCM token for a user or admin. A token already registered
+// to another account (someone else logged in on the same phone) is moved to the new owner.
+func RegisterDevice(db *gorm.DB, ownerType string, ownerID uint, token, platform, appVersion string) (users.DeviceToken, error) {
+	device := users.DeviceToken{
+		OwnerType:  ownerType,
+		OwnerId:    ownerID,
+		Token:      token,
+		Platform:   platform,
+		AppVersion: appVersion,
+		LastSeenAt: time.Now(),
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"owner_type", "owner_id", "platform", "app_version", "last_seen_at"}),
+	}).Create(&device).Error
+	return device, err
+}
+
+// UnregisterDevice removes a token owned by the caller, e.g. on logout
+func UnregisterDevice(db *gorm.DB, ownerType string, ownerID uint, token string) (bool, error) {
+	result := db.Where("owner_type = ? AND owner_id = ? AND token = ?", ownerType, ownerID, token).
+		Delete(&users.DeviceToken{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// pruneDeviceToken drops a token FCM no longer accepts, including the legacy copy on the account
+func pruneDeviceToken(db *gorm.DB, token string) {
+	log.Printf("FCM: pruning unregistered token %.12s...", token)
+
+	if err := db.Where("token = ?", token).Delete(&users.DeviceToken{}).Error; err != nil {
+		log.Printf("FCM Error: Failed to delete device token: %v", err)
+	}
+	if err := db.Model(&users.User{}).Where("fcm_token = ?", token).Update("fcm_token", "").Error; err != nil {
+		log.Printf("FCM Error: Failed to clear user token: %v", err)
+	}
+	if err := db.Model(&users.Admin{}).Where("fcm_token = ?", token).Update("fcm_token", "").Error; err != nil {
+		log.Printf("FCM Error: Failed to clear admin token: %v", err)
+	}
+}
@@ -3,25 +3,26 @@ package websocketclient
 import (
 	"context"
 	"errors"
+	"fixify_backend/middleware"
 	"fixify_backend/model/users"
+	"fmt"
 	"log"
 	"sync"
 
 	firebase "firebase.google.com/go/v4"
-	"firebase.google.com/go/v4/messaging"
 	"gorm.io/gorm"
 )
 
-type FCMService struct {
-	client *messaging.Client
+type PushService struct {
+	sender PushSender
 	db     *gorm.DB
 	mu     sync.Mutex
 }
 
 var (
-	FCMInstance *FCMService
-	initOnce    sync.Once
-	initErr     error
+	PushInstance *PushService
+	initOnce     sync.Once
+	initErr      error
 )
 
 // InitializeFCM should be called once at application startup
@@ -33,8 +34,8 @@ func InitializeFCM(db *gorm.DB, app *firebase.App) error {
 			return
 		}
 
-		FCMInstance = &FCMService{
-			client: client,
+		PushInstance = &PushService{
+			sender: NewFCMSender(client),
 			db:     db,
 		}
 		log.Println("FCM service successfully initialized")
@@ -42,70 +43,186 @@ func InitializeFCM(db *gorm.DB, app *firebase.App) error {
 	return initErr
 }
 
-func (f *FCMService) RegisterToken(userID uint, token string) error {
-	if f == nil {
-		return errors.New("FCM service not initialized")
+// InitializePush installs a push sender directly, e.g. the no-op or recording sender when
+// Firebase is not configured. It replaces any sender installed before.
+func InitializePush(db *gorm.DB, sender PushSender) {
+	PushInstance = &PushService{
+		sender: sender,
+		db:     db,
 	}
+}
 
-	f.mu.Lock()
-	defer f.mu.Unlock()
+// ConfigurePush installs the sender for a PUSH_PROVIDER value (fcm, noop or recording) and returns
+// it. Without a provider, FCM is used when Firebase credentials are configured and pushes are
+// dropped otherwise. newFirebaseApp is only called for FCM.
+func ConfigurePush(db *gorm.DB, provider string, newFirebaseApp func() (*firebase.App, error)) (PushSender, error) {
+	if provider == "" {
+		provider = PushProviderNoop
+		if middleware.GetEnv("FIREBASE_PROJECT_ID") != "" {
+			provider = PushProviderFCM
+		}
+	}
 
-	var user users.User
-	if err := f.db.First(&user, userID).Error; err != nil {
+	switch provider {
+	case PushProviderFCM:
+		app, err := newFirebaseApp()
+		if err != nil {
+			return nil, fmt.Errorf("initialize Firebase app: %w", err)
+		}
+		if err := InitializeFCM(db, app); err != nil {
+			return nil, fmt.Errorf("initialize FCM: %w", err)
+		}
+	case PushProviderRecording:
+		InitializePush(db, NewRecordingSender())
+	case PushProviderNoop:
+		InitializePush(db, NoopSender{})
+	default:
+		return nil, fmt.Errorf("unknown PUSH_PROVIDER %q", provider)
+	}
+	return CurrentPushSender(), nil
+}
+
+// CurrentPushSender returns the installed sender, or nil before initialization
+func CurrentPushSender() PushSender {
+	if PushInstance == nil {
+		return nil
+	}
+	return PushInstance.sender
+}
+
+// SendAdminPushNotification pushes to every registered device of an admin
+func SendAdminPushNotification(toAdminID uint, title, body string, data map[string]string) error {
+	if PushInstance == nil {
+		log.Println("Push Error: Service not initialized")
+		return errors.New("push service not initialized")
+	}
+
+	PushInstance.mu.Lock()
+	defer PushInstance.mu.Unlock()
+
+	var admin users.Admin
+	if err := PushInstance.db.Select("admin_id, fcm_token").First(&admin, "admin_id = ?", toAdminID).Error; err != nil {
+		log.Printf("Push Error: Admin lookup failed for %d: %v", toAdminID, err)
 		return err
 	}
 
-	user.FCMToken = token
-	return f.db.Save(&user).Error
+	tokens := PushInstance.ownerTokens(users.RoleAdmin, toAdminID, admin.FCMToken)
+	if len(tokens) == 0 {
+		return nil // No token registered
+	}
+
+	return PushInstance.send(PushMessage{
+		Tokens: tokens,
+		Title:  title,
+		Body:   body,
+		Data:   data,
+	})
+}
+
+// MessagePreview renders the short text shown in notifications for a chat message,
+// so attachments and location pins don't arrive as empty pushes.
+func MessagePreview(msg users.ClientRepairmanMessage) string {
+	switch msg.MessageType {
+	case users.MessageTypeImage:
+		if msg.Message != "" {
+			return "📷 " + msg.Message
+		}
+		return "📷 Photo"
+	case users.MessageTypeFile:
+		if msg.Attachment != nil && msg.Attachment.FileName != "" {
+			return "📎 " + msg.Attachment.FileName
+		}
+		return "📎 File"
+	case users.MessageTypeLocation:
+		if msg.Message != "" {
+			return "📍 " + msg.Message
+		}
+		return "📍 Shared a location"
+	}
+	return msg.Message
 }
 
 func SendPushNotification(toUserID uint, title, body string, data map[string]string) error {
-	if FCMInstance == nil {
-		log.Println("FCM Error: Service not initialized")
-		return errors.New("FCM service not initialized")
+	if PushInstance == nil {
+		log.Println("Push Error: Service not initialized")
+		return errors.New("push service not initialized")
 	}
 
-	FCMInstance.mu.Lock()
-	defer FCMInstance.mu.Unlock()
+	PushInstance.mu.Lock()
+	defer PushInstance.mu.Unlock()
 
-	log.Printf("Looking up FCM token for user %d", toUserID)
+	log.Printf("Looking up device tokens for user %d", toUserID)
 
 	var user users.User
-	if err := FCMInstance.db.First(&user, toUserID).Error; err != nil {
-		log.Printf("FCM Error: User lookup failed for %d: %v", toUserID, err)
+	if err := PushInstance.db.Select("user_id, fcm_token").First(&user, toUserID).Error; err != nil {
+		log.Printf("Push Error: User lookup failed for %d: %v", toUserID, err)
 		return err
 	}
 
-	if user.FCMToken == "" {
-		log.Printf("FCM Warning: No token for user %d (might need to register)", toUserID)
+	tokens := PushInstance.ownerTokens(users.RoleUser, toUserID, user.FCMToken)
+	if len(tokens) == 0 {
+		log.Printf("Push Warning: No token for user %d (might need to register)", toUserID)
 		return nil
 	}
 
-	log.Printf("Sending FCM to user %d with token: %s", toUserID, user.FCMToken)
+	log.Printf("Sending push to user %d on %d device(s)", toUserID, len(tokens))
 
-	message := &messaging.Message{
-		Token: user.FCMToken,
-		Notification: &messaging.Notification{
-			Title: title,
-			Body:  body,
-		},
-		Data: data,
-		Android: &messaging.AndroidConfig{
-			Priority: "high", // Important for wakeup
-		},
-		APNS: &messaging.APNSConfig{
-			Headers: map[string]string{
-				"apns-priority": "10", // iOS high priority
-			},
-		},
+	message := PushMessage{
+		Tokens:       tokens,
+		Title:        title,
+		Body:         body,
+		Data:         data,
+		HighPriority: true,
 	}
 
-	response, err := FCMInstance.client.Send(context.Background(), message)
+	if err := PushInstance.send(message); err != nil {
+		log.Printf("Push Error: Send failed to %d: %v", toUserID, err)
+		return err
+	}
+
+	log.Printf("Push Success: sent to %d", toUserID)
+	return nil
+}
+
+// ownerTokens returns the registered device tokens of a user or admin. The single token stored on
+// the account by older app versions is used only when no device has been registered yet.
+func (f *PushService) ownerTokens(ownerType string, ownerID uint, legacyToken string) []string {
+	var tokens []string
+	if err := f.db.Model(&users.DeviceToken{}).
+		Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Pluck("token", &tokens).Error; err != nil {
+		log.Printf("Push Error: Device lookup failed for %s %d: %v", ownerType, ownerID, err)
+	}
+
+	if len(tokens) == 0 && legacyToken != "" {
+		tokens = append(tokens, legacyToken)
+	}
+	return tokens
+}
+
+// send delivers to every token and deletes the ones reported as unregistered
+// (app uninstalled or token rotated). It only fails when no device accepted the message.
+func (f *PushService) send(message PushMessage) error {
+	results, err := f.sender.Send(context.Background(), message)
 	if err != nil {
-		log.Printf("FCM Error: Send failed to %d: %v", toUserID, err)
 		return err
 	}
 
-	log.Printf("FCM Success: Message ID %s sent to %d", response, toUserID)
+	delivered := 0
+	var lastErr error
+	for _, result := range results {
+		if result.Err == nil {
+			delivered++
+			continue
+		}
+		lastErr = result.Err
+		if result.Unregistered {
+			pruneDeviceToken(f.db, result.Token)
+		}
+	}
+
+	if delivered == 0 && lastErr != nil {
+		return lastErr
+	}
 	return nil
-}
\ No newline at end of file
+}
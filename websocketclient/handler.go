@@ -2,8 +2,8 @@ package websocketclient
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
-	"strconv"
 	"strings"
 	"time"
 
@@ -15,11 +15,101 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/websocket/v2"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type IncomingMessage struct {
-	To      uint   `json:"to"`
-	Content string `json:"content"`
+	To             uint     `json:"to"`
+	ConversationId uint     `json:"conversation_id"`   // Target conversation; falls back to the latest open one with "to"
+	Content        string   `json:"content"`           // Text body, or caption for attachments
+	MessageType    string   `json:"message_type"`      // text (default), image, file or location
+	AttachmentId   *uint    `json:"attachment_id"`     // From POST /messagesclirep/attachments
+	Latitude       *float64 `json:"latitude"`          // Location pins only
+	Longitude      *float64 `json:"longitude"`         // Location pins only
+	ClientMsgId    string   `json:"client_message_id"` // Optional idempotency key generated by the app
+}
+
+// validateIncomingMessage checks the message kind against its payload and returns the
+// referenced attachment, if any. Attachments can only be sent by the user who uploaded them.
+func validateIncomingMessage(db *gorm.DB, senderID uint, in *IncomingMessage) (*users.ChatAttachment, error) {
+	if in.MessageType == "" {
+		in.MessageType = users.MessageTypeText
+	}
+
+	switch in.MessageType {
+	case users.MessageTypeText:
+		if strings.TrimSpace(in.Content) == "" {
+			return nil, fmt.Errorf("content is required")
+		}
+		return nil, nil
+
+	case users.MessageTypeImage, users.MessageTypeFile:
+		if in.AttachmentId == nil {
+			return nil, fmt.Errorf("attachment_id is required for %s messages", in.MessageType)
+		}
+		var attachment users.ChatAttachment
+		if err := db.Select("attachment_id, uploader_id, file_name, content_type, size, created_at").
+			First(&attachment, "attachment_id = ? AND uploader_id = ?", *in.AttachmentId, senderID).Error; err != nil {
+			return nil, fmt.Errorf("attachment not found")
+		}
+		isImage := strings.HasPrefix(attachment.ContentType, "image/")
+		if isImage != (in.MessageType == users.MessageTypeImage) {
+			return nil, fmt.Errorf("attachment does not match message_type %s", in.MessageType)
+		}
+		return &attachment, nil
+
+	case users.MessageTypeLocation:
+		if in.Latitude == nil || in.Longitude == nil {
+			return nil, fmt.Errorf("latitude and longitude are required for location messages")
+		}
+		if *in.Latitude < -90 || *in.Latitude > 90 || *in.Longitude < -180 || *in.Longitude > 180 {
+			return nil, fmt.Errorf("invalid coordinates")
+		}
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unsupported message_type %q", in.MessageType)
+}
+
+// sendError reports a rejected message back to its sender
+func sendError(client *Client, clientMsgId string, reason string) {
+	frame, err := json.Marshal(map[string]interface{}{
+		"type":              "error",
+		"client_message_id": clientMsgId,
+		"error":             reason,
+	})
+	if err != nil {
+		log.Printf("Error marshaling error frame: %v", err)
+		return
+	}
+	if !client.queue(frame) {
+		log.Printf("Dropped error frame for user_id %d: connection is not keeping up", client.UserID)
+	}
+}
+
+// maxClientMsgIdLength matches the client_message_id column size
+const maxClientMsgIdLength = 64
+
+// sendAck tells the sender that a message was stored, echoing the client-generated ID
+// so the app can reconcile its local outbox. isDuplicate is true when a retry hit an
+// already stored message.
+func sendAck(client *Client, msg users.ClientRepairmanMessage, isDuplicate bool) {
+	ack, err := json.Marshal(map[string]interface{}{
+		"type":              "ack",
+		"client_message_id": msg.ClientMsgId,
+		"message_id":        msg.MessageId,
+		"conversation_id":   msg.ConversationId,
+		"content":           msg.Message, // As stored, after any chat filtering
+		"created_at":        msg.CreatedAt.Format(time.RFC3339),
+		"is_duplicate":      isDuplicate,
+	})
+	if err != nil {
+		log.Printf("Error marshaling ack: %v", err)
+		return
+	}
+	if !client.queue(ack) {
+		log.Printf("Dropped ack for user_id %d: connection is not keeping up", client.UserID)
+	}
 }
 
 // WebSocketHandler - Upgraded WebSocket handler for Repairman and Client communication
@@ -39,10 +129,18 @@ func WebSocketHandler(c *fiber.Ctx) error {
 
 	claims := &users.Claims{}
 	_, err := signuplogin.ParseJWTClaims(token, claims)
+	if restricted, ok := err.(*signuplogin.AccountRestrictedError); ok {
+		return c.Status(fiber.StatusForbidden).SendString(restricted.Error())
+	}
 	if err != nil {
 		return c.Status(fiber.StatusUnauthorized).SendString("Invalid token")
 	}
 
+	// Admin IDs live in a separate table and must not be mistaken for client/repairman IDs
+	if claims.IsAdmin() {
+		return c.Status(fiber.StatusForbidden).SendString("Client or repairman token required")
+	}
+
 	c.Locals("user_id", claims.UserId)
 	return c.Next()
 }
@@ -56,7 +154,7 @@ func WebSocketUpgrade(c *websocket.Conn) {
 	client := &Client{
 		UserID: userID,
 		Conn:   c,
-		Send:   make(chan []byte),
+		Send:   make(chan []byte, sendBufferSize),
 	}
 
 	log.Printf("WebSocket connection established for user_id: %d", userID)
@@ -65,8 +163,14 @@ func WebSocketUpgrade(c *websocket.Conn) {
 	HubInstance.register <- client
 	log.Printf("Client registered with user_id: %d", userID)
 
-	// Read messages from the WebSocket connection
+	// Initial badge count; async because the writer below is not running yet
+	go PushUnreadCount(db, userID)
+
+	// Read messages from the WebSocket connection. The connection is over when reading fails,
+	// including when it is closed by Hub.Disconnect.
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		log.Printf("Starting to read messages for user_id: %d", userID)
 		for {
 			_, msg, err := c.ReadMessage()
@@ -83,70 +187,149 @@ func WebSocketUpgrade(c *websocket.Conn) {
 				continue
 			}
 
-			toUserID := incomingMsg.To
+			if len(incomingMsg.ClientMsgId) > maxClientMsgIdLength {
+				log.Printf("client_message_id too long from user_id %d", userID)
+				continue
+			}
 
-			// Check if the recipient user exists
-			var recipient users.User
-			err = db.First(&recipient, toUserID).Error
-			if err != nil {
-				if err == gorm.ErrRecordNotFound {
-					log.Printf("Receiver user with ID %d not found", toUserID)
+			// A retry of an already stored message is acknowledged again but not re-saved
+			if incomingMsg.ClientMsgId != "" {
+				var existing users.ClientRepairmanMessage
+				err := db.Where("sender_id = ? AND client_message_id = ?", userID, incomingMsg.ClientMsgId).
+					First(&existing).Error
+				if err == nil {
+					log.Printf("Duplicate message %s from user_id %d (message ID %d)", incomingMsg.ClientMsgId, userID, existing.MessageId)
+					sendAck(client, existing, true)
+					continue
+				} else if err != gorm.ErrRecordNotFound {
+					log.Printf("Error checking for duplicate message: %v", err)
 					continue
 				}
-				log.Printf("Error checking recipient existence: %v", err)
+			}
+
+			attachment, err := validateIncomingMessage(db, userID, &incomingMsg)
+			if err != nil {
+				log.Printf("Rejected message from user_id %d: %v", userID, err)
+				sendError(client, incomingMsg.ClientMsgId, err.Error())
 				continue
 			}
 
-			// Check for or create conversation
 			var conversation users.ClientRepairmanConversation
-			err = db.Where("(client_id = ? AND repairman_id = ?) OR (client_id = ? AND repairman_id = ?)",
-				userID, toUserID, toUserID, userID).First(&conversation).Error
+			var toUserID uint
 
-			if err != nil {
-				if err == gorm.ErrRecordNotFound {
-					conversation = users.ClientRepairmanConversation{
-						ClientId:    userID,
-						RepairmanId: toUserID,
-					}
+			if incomingMsg.ConversationId != 0 {
+				// Message for a specific (request-scoped) conversation
+				err = db.First(&conversation, "conversation_id = ?", incomingMsg.ConversationId).Error
+				if err != nil {
+					log.Printf("Conversation %d not found for user_id %d: %v", incomingMsg.ConversationId, userID, err)
+					sendError(client, incomingMsg.ClientMsgId, "conversation not found")
+					continue
+				}
+				toUserID = conversation.OtherParticipant(userID)
+			} else {
+				toUserID = incomingMsg.To
 
-					if err := db.Create(&conversation).Error; err != nil {
-						log.Printf("Failed to create conversation: %v", err)
+				// Check if the recipient user exists
+				var recipient users.User
+				err = db.First(&recipient, toUserID).Error
+				if err != nil {
+					if err == gorm.ErrRecordNotFound {
+						log.Printf("Receiver user with ID %d not found", toUserID)
 						continue
 					}
-					log.Printf("Created new conversation with ID: %d", conversation.ConversationId)
-				} else {
-					log.Printf("Error checking for existing conversation: %v", err)
+					log.Printf("Error checking recipient existence: %v", err)
+					continue
+				}
+
+				// Without a conversation_id, use the most recently active open conversation of the pair.
+				// Conversations are only opened by accepting a service request, never from here.
+				err = db.Where("(client_id = ? AND repairman_id = ?) OR (client_id = ? AND repairman_id = ?)",
+					userID, toUserID, toUserID, userID).
+					Where("is_read_only = ?", false).
+					Order("updated_at DESC").
+					First(&conversation).Error
+
+				if err != nil {
+					if err == gorm.ErrRecordNotFound {
+						log.Printf("No open conversation between user_id %d and %d", userID, toUserID)
+						sendError(client, incomingMsg.ClientMsgId, "conversation not found")
+					} else {
+						log.Printf("Error checking for existing conversation: %v", err)
+					}
 					continue
 				}
-			} else {
 				log.Printf("Found existing conversation with ID: %d", conversation.ConversationId)
 			}
 
+			// Only the two parties of a conversation may post in it
+			if !conversation.HasParticipant(userID) {
+				log.Printf("User_id %d is not a participant of conversation %d", userID, conversation.ConversationId)
+				sendError(client, incomingMsg.ClientMsgId, "conversation not found")
+				continue
+			}
+
+			// Chats of completed or canceled requests are kept for reference only
+			if conversation.IsReadOnly {
+				sendError(client, incomingMsg.ClientMsgId, "conversation is closed")
+				continue
+			}
+
+			blocked, err := controller.IsBlocked(db, userID, toUserID)
+			if err != nil {
+				log.Printf("Error checking block list: %v", err)
+				continue
+			}
+			if blocked {
+				sendError(client, incomingMsg.ClientMsgId, "you can no longer message this user")
+				continue
+			}
+
+			// Optional profanity / contact-info filtering (see controller.FilterChatMessage)
+			incomingMsg.Content, _ = controller.FilterChatMessage(incomingMsg.Content)
+
 			// Save the message
 			newMessage := users.ClientRepairmanMessage{
 				ConversationId: conversation.ConversationId,
 				SenderId:       userID,
 				Message:        incomingMsg.Content,
+				MessageType:    incomingMsg.MessageType,
+				AttachmentId:   incomingMsg.AttachmentId,
+				Latitude:       incomingMsg.Latitude,
+				Longitude:      incomingMsg.Longitude,
+				ClientMsgId:    incomingMsg.ClientMsgId,
 				CreatedAt:      time.Now(),
 			}
 
-			if err := db.Create(&newMessage).Error; err != nil {
-				log.Printf("Failed to insert message into DB. Error: %v, Message: %+v", err, newMessage)
+			// The unique index on (sender_id, client_message_id) settles retries racing each other,
+			// e.g. from two devices; the one that loses gets the stored message acknowledged
+			insert := db
+			if newMessage.ClientMsgId != "" {
+				insert = db.Clauses(clause.OnConflict{
+					Columns:     []clause.Column{{Name: "sender_id"}, {Name: "client_message_id"}},
+					TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "client_message_id <> ''"}}},
+					DoNothing:   true,
+				})
+			}
+			result := insert.Create(&newMessage)
+			if result.Error != nil {
+				log.Printf("Failed to insert message into DB. Error: %v, Message: %+v", result.Error, newMessage)
 				continue
 			}
-			log.Printf("Successfully saved message ID %d", newMessage.MessageId)
-
-			// Trigger a user notification
-			err = controller.CreateChatNotification(
-				db,
-				"new_message",
-				int(userID),
-				int(toUserID),
-				incomingMsg.Content,
-			)
-			if err != nil {
-				log.Printf("Failed to create user notification: %v", err)
+			if result.RowsAffected == 0 {
+				var existing users.ClientRepairmanMessage
+				if err := db.Where("sender_id = ? AND client_message_id = ?", userID, newMessage.ClientMsgId).
+					First(&existing).Error; err != nil {
+					log.Printf("Error loading duplicate message: %v", err)
+					continue
+				}
+				log.Printf("Duplicate message %s from user_id %d (message ID %d)", newMessage.ClientMsgId, userID, existing.MessageId)
+				sendAck(client, existing, true)
+				continue
 			}
+			log.Printf("Successfully saved message ID %d", newMessage.MessageId)
+			sendAck(client, newMessage, false)
+			newMessage.Attachment = attachment
+			preview := MessagePreview(newMessage)
 
 			// Update conversation timestamp
 			if err := db.Model(&conversation).Update("updated_at", time.Now()).Error; err != nil {
@@ -155,13 +338,18 @@ func WebSocketUpgrade(c *websocket.Conn) {
 
 			// Broadcast message
 			messageData := map[string]interface{}{
-				"message_id":      newMessage.MessageId,
-				"conversation_id": conversation.ConversationId,
-				"sender_id":       userID,
-				"content":         incomingMsg.Content,
-				"created_at":      newMessage.CreatedAt.Format(time.RFC3339),
-				"type":            "new_message",
-				"is_duplicate":    false,
+				"message_id":        newMessage.MessageId,
+				"conversation_id":   conversation.ConversationId,
+				"request_id":        conversation.RequestId,
+				"sender_id":         userID,
+				"content":           incomingMsg.Content,
+				"message_type":      newMessage.MessageType,
+				"attachment":        attachment,
+				"latitude":          newMessage.Latitude,
+				"longitude":         newMessage.Longitude,
+				"client_message_id": newMessage.ClientMsgId,
+				"created_at":        newMessage.CreatedAt.Format(time.RFC3339),
+				"type":              "new_message",
 			}
 
 			jsonData, err := json.Marshal(messageData)
@@ -176,33 +364,34 @@ func WebSocketUpgrade(c *websocket.Conn) {
 				Content:    string(jsonData),
 			}
 
-			// Send push notification
-			log.Printf("Attempting FCM to user %d (online: %v)", toUserID, HubInstance.IsOnline(toUserID))
-			err = SendPushNotification(
-				toUserID,
-				"New Message",
-				incomingMsg.Content,
-				map[string]string{
-					"type":            "new_message",
-					"conversation_id": strconv.FormatUint(uint64(conversation.ConversationId), 10),
-					"sender_id":       strconv.FormatUint(uint64(userID), 10),
-					"message_content": incomingMsg.Content,
-				},
-			)
-			if err != nil {
-				log.Printf("FCM failed to %d: %v", toUserID, err)
-			} else {
-				log.Printf("FCM sent to %d", toUserID)
+			// Inbox entry plus push for offline recipients
+			if err := Notify(db, Notification{
+				Category: users.NotificationCategoryChat,
+				Type:     "new_message",
+				FromUser: userID,
+				ToUser:   toUserID,
+				Title:    "New Message",
+				Body:     preview,
+				Data:     chatNotificationData(newMessage, preview),
+			}); err != nil {
+				log.Printf("Failed to notify user %d: %v", toUserID, err)
 			}
 		}
 	}()
 
-	// Send messages to the WebSocket connection
+	// Send messages to the WebSocket connection. After a failed write the connection is closed,
+	// which ends the read loop, and Send is drained until the hub closes it so nothing queuing a
+	// frame is left waiting.
 	go func() {
+		failed := false
 		for msg := range client.Send {
+			if failed {
+				continue
+			}
 			if err := c.WriteMessage(websocket.TextMessage, msg); err != nil {
 				log.Printf("Error sending message to user_id %d: %v", userID, err)
-				break
+				failed = true
+				c.Close()
 			}
 		}
 	}()
@@ -215,5 +404,5 @@ func WebSocketUpgrade(c *websocket.Conn) {
 		log.Printf("Unregistered client with user_id: %d", userID)
 	}()
 
-	select {} // Keep goroutine alive
+	<-done // Keep the connection open until the reader stops
 }
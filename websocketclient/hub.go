@@ -9,7 +9,21 @@ import (
 type Client struct {
 	UserID uint
 	Conn   *websocket.Conn
-	Send   chan []byte
+	Send   chan []byte // Buffered, see sendBufferSize
+}
+
+// sendBufferSize is how many frames can wait for a connection's writer
+const sendBufferSize = 64
+
+// queue hands a frame to the connection's writer without blocking. It returns false when the
+// buffer is full because the writer has stopped or can't keep up.
+func (c *Client) queue(frame []byte) bool {
+	select {
+	case c.Send <- frame:
+		return true
+	default:
+		return false
+	}
 }
 
 type Hub struct {
@@ -43,19 +57,22 @@ func (h *Hub) Run() {
 
 		case client := <-h.unregister:
 			h.lock.Lock()
-			if _, ok := h.clients[client.UserID]; ok {
+			// A newer connection of the same user may have replaced this one already
+			if current, ok := h.clients[client.UserID]; ok && current == client {
 				delete(h.clients, client.UserID)
-				close(client.Send)
 			}
+			close(client.Send)
 			h.lock.Unlock()
 
 		case msg := <-h.broadcast:
-			h.lock.RLock()
-			if receiver, ok := h.clients[msg.ToUserID]; ok {
-				data := []byte(msg.Content)
-				receiver.Send <- data
+			// Never wait on a connection here: one stalled writer would hold up every chat.
+			// A client whose buffer is full is dropped; its read loop unregisters it.
+			h.lock.Lock()
+			if receiver, ok := h.clients[msg.ToUserID]; ok && !receiver.queue([]byte(msg.Content)) {
+				delete(h.clients, msg.ToUserID)
+				receiver.Conn.Close()
 			}
-			h.lock.RUnlock()
+			h.lock.Unlock()
 		}
 	}
 }
@@ -65,6 +82,35 @@ func (Message) TableName() string {
 }
 
 func (h *Hub) IsOnline(userID uint) bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
 	_, ok := h.clients[userID]
 	return ok
 }
+
+// SendToUser queues a frame for a connected user. It returns false if the user is offline.
+func (h *Hub) SendToUser(userID uint, data []byte) bool {
+	if !h.IsOnline(userID) {
+		return false
+	}
+	h.broadcast <- Message{
+		ToUserID: userID,
+		Content:  string(data),
+	}
+	return true
+}
+
+// Disconnect closes a user's live connection, if any. The user counts as offline right away, so
+// notifications sent afterwards go out as pushes instead of queueing on the closed socket. The read
+// loop then unregisters the client.
+func (h *Hub) Disconnect(userID uint) {
+	h.lock.Lock()
+	client, ok := h.clients[userID]
+	if ok {
+		delete(h.clients, userID)
+	}
+	h.lock.Unlock()
+	if ok {
+		client.Conn.Close()
+	}
+}
@@ -0,0 +1,221 @@
+package websocketclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"fixify_backend/controller"
+	"fixify_backend/mailer"
+	"fixify_backend/model/users"
+
+	"gorm.io/gorm"
+)
+
+// Notification is a single event to deliver to a client or repairman
+type Notification struct {
+	Category  string            // Preference key, one of users.NotificationCategory*
+	Type      string            // Stored on the inbox row, e.g. "Service Request"
+	RequestId int               // Related service request, if any
+	FromUser  uint              // Triggering user (0 for system events)
+	ToUser    uint              // Recipient
+	Title     string            // Push/email title
+	Body      string            // Push/email body and inbox description
+	Data      map[string]string // Extra FCM data payload
+
+	// Email template and data for users who get this category by email. Without a template
+	// the generic notification email with Title and Body is used.
+	EmailTemplate string
+	EmailData     interface{}
+}
+
+// Notify is the single entry point for user notifications. It writes the inbox row, then delivers
+// over the websocket if the recipient is online, falling back to FCM when offline. Email is sent
+// when the user enabled it for the category. Muted categories are only written to the inbox, and
+// pushes are held back during the user's quiet hours.
+//
+// Chat messages are already delivered live by the chat handler, so for the chat category an
+// online recipient gets no extra websocket frame.
+func Notify(db *gorm.DB, n Notification) error {
+	var err error
+	if n.Category == users.NotificationCategoryChat {
+		err = controller.CreateChatNotification(db, n.Type, int(n.FromUser), int(n.ToUser), n.Body)
+	} else {
+		err = controller.CreateUserNotification(db, n.Type, n.RequestId, int(n.FromUser), int(n.ToUser), n.Body)
+	}
+	if err != nil {
+		return err
+	}
+	if n.Category != users.NotificationCategoryChat {
+		PushUnreadCount(db, n.ToUser)
+	}
+
+	pref := loadNotificationPreference(db, n.ToUser, n.Category)
+	if pref.Muted {
+		return nil
+	}
+
+	var recipient users.User
+	if err := db.Select("user_id, email, quiet_hours_start, quiet_hours_end").
+		First(&recipient, "user_id = ?", n.ToUser).Error; err != nil {
+		log.Printf("Notify: recipient %d lookup failed: %v", n.ToUser, err)
+		return nil
+	}
+
+	delivered := false
+	if n.Category == users.NotificationCategoryChat {
+		delivered = HubInstance.IsOnline(n.ToUser)
+	} else {
+		delivered = sendNotificationFrame(n)
+	}
+	plan := planDelivery(pref, recipient, delivered, time.Now())
+
+	go func() {
+		if plan.push {
+			if err := SendPushNotification(n.ToUser, n.Title, n.Body, pushData(n)); err != nil {
+				log.Printf("Notify: push to %d failed: %v", n.ToUser, err)
+			}
+		}
+
+		if plan.email {
+			template, data := n.EmailTemplate, n.EmailData
+			if template == "" {
+				template, data = mailer.TemplateNotification, mailer.NotificationData{Title: n.Title, Body: n.Body}
+			}
+			if err := mailer.SendTemplate(db, recipient.Email, template, data); err != nil {
+				log.Printf("Notify: email to %d failed: %v", n.ToUser, err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// deliveryPlan is how a notification reaches the recipient besides the inbox
+type deliveryPlan struct {
+	push  bool
+	email bool
+}
+
+// planDelivery decides on push and email from the recipient's preference for the category.
+// delivered reports whether the websocket already showed the notification.
+func planDelivery(pref users.NotificationPreference, recipient users.User, delivered bool, now time.Time) deliveryPlan {
+	if pref.Muted {
+		return deliveryPlan{}
+	}
+	return deliveryPlan{
+		push:  !delivered && pref.PushEnabled && !inQuietHours(recipient, now),
+		email: pref.EmailEnabled && recipient.Email != "",
+	}
+}
+
+// pushData is the FCM data payload of a notification
+func pushData(n Notification) map[string]string {
+	data := map[string]string{
+		"type":     n.Type,
+		"category": n.Category,
+	}
+	if n.RequestId != 0 {
+		data["request_id"] = strconv.Itoa(n.RequestId)
+	}
+	for k, v := range n.Data {
+		data[k] = v
+	}
+	return data
+}
+
+// sendNotificationFrame pushes the notification to an online recipient over the websocket
+func sendNotificationFrame(n Notification) bool {
+	frame, err := json.Marshal(map[string]interface{}{
+		"type":              "notification",
+		"category":          n.Category,
+		"notification_type": n.Type,
+		"request_id":        n.RequestId,
+		"from_user":         n.FromUser,
+		"title":             n.Title,
+		"body":              n.Body,
+		"data":              n.Data,
+	})
+	if err != nil {
+		log.Printf("Error marshaling notification frame: %v", err)
+		return false
+	}
+	return HubInstance.SendToUser(n.ToUser, frame)
+}
+
+// PushUnreadCount sends the user's current unread badge count over the websocket, if they are online
+func PushUnreadCount(db *gorm.DB, userID uint) {
+	if !HubInstance.IsOnline(userID) {
+		return
+	}
+
+	count, err := controller.CountUnreadNotifications(db, userID)
+	if err != nil {
+		log.Printf("Failed to count unread notifications for %d: %v", userID, err)
+		return
+	}
+
+	frame, err := json.Marshal(map[string]interface{}{
+		"type":         "unread_count",
+		"unread_count": count,
+	})
+	if err != nil {
+		log.Printf("Error marshaling unread count: %v", err)
+		return
+	}
+	HubInstance.SendToUser(userID, frame)
+}
+
+// loadNotificationPreference returns the user's preference for a category, or the default
+func loadNotificationPreference(db *gorm.DB, userID uint, category string) users.NotificationPreference {
+	pref := users.DefaultNotificationPreference(userID, category)
+	err := db.Where("user_id = ? AND category = ?", userID, category).First(&pref).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Printf("Notify: preference lookup failed for %d: %v", userID, err)
+	}
+	return pref
+}
+
+// inQuietHours reports whether now falls inside the user's quiet hours, evaluated in the app
+// timezone (APP_TIMEZONE, Asia/Manila by default). Windows may wrap past midnight.
+func inQuietHours(user users.User, now time.Time) bool {
+	if user.QuietHoursStart == "" || user.QuietHoursEnd == "" {
+		return false
+	}
+
+	start, err1 := time.Parse("15:04", user.QuietHoursStart)
+	end, err2 := time.Parse("15:04", user.QuietHoursEnd)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	tz := os.Getenv("APP_TIMEZONE")
+	if tz == "" {
+		tz = "Asia/Manila"
+	}
+	if loc, err := time.LoadLocation(tz); err == nil {
+		now = now.In(loc)
+	}
+
+	minutes := now.Hour()*60 + now.Minute()
+	startMin := start.Hour()*60 + start.Minute()
+	endMin := end.Hour()*60 + end.Minute()
+
+	if startMin <= endMin {
+		return minutes >= startMin && minutes < endMin
+	}
+	return minutes >= startMin || minutes < endMin
+}
+
+// chatNotificationData is the FCM payload attached to new message notifications
+func chatNotificationData(msg users.ClientRepairmanMessage, preview string) map[string]string {
+	return map[string]string{
+		"conversation_id": fmt.Sprintf("%d", msg.ConversationId),
+		"sender_id":       fmt.Sprintf("%d", msg.SenderId),
+		"message_type":    msg.MessageType,
+		"message_content": preview,
+	}
+}
@@ -0,0 +1,84 @@
+package websocketclient
+
+import (
+	"testing"
+	"time"
+
+	"fixify_backend/model/users"
+)
+
+func TestPlanDelivery(t *testing.T) {
+	t.Setenv("APP_TIMEZONE", "UTC")
+	noon := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	night := time.Date(2025, 6, 1, 23, 30, 0, 0, time.UTC)
+
+	pref := users.DefaultNotificationPreference(7, users.NotificationCategoryPayment)
+	muted := pref
+	muted.Muted = true
+	noPush := pref
+	noPush.PushEnabled = false
+
+	recipient := users.User{UserId: 7, Email: "ana@example.com", QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}
+	noEmail := recipient
+	noEmail.Email = ""
+
+	tests := []struct {
+		name      string
+		pref      users.NotificationPreference
+		recipient users.User
+		delivered bool
+		now       time.Time
+		want      deliveryPlan
+	}{
+		{"offline", pref, recipient, false, noon, deliveryPlan{push: true, email: true}},
+		{"shown on the websocket", pref, recipient, true, noon, deliveryPlan{email: true}},
+		{"quiet hours across midnight", pref, recipient, false, night, deliveryPlan{email: true}},
+		{"push turned off", noPush, recipient, false, noon, deliveryPlan{email: true}},
+		{"no email address", pref, noEmail, false, noon, deliveryPlan{push: true}},
+		{"muted", muted, recipient, false, noon, deliveryPlan{}},
+	}
+	for _, tt := range tests {
+		if got := planDelivery(tt.pref, tt.recipient, tt.delivered, tt.now); got != tt.want {
+			t.Errorf("%s: plan = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Email for other categories is opt-in
+	chat := users.DefaultNotificationPreference(7, users.NotificationCategoryChat)
+	if got := planDelivery(chat, recipient, false, noon); got.email {
+		t.Error("chat notifications emailed by default")
+	}
+}
+
+func TestPushData(t *testing.T) {
+	data := pushData(Notification{
+		Category:  users.NotificationCategoryRequest,
+		Type:      "Service Request",
+		RequestId: 42,
+		Data:      map[string]string{"status": "accepted"},
+	})
+	want := map[string]string{
+		"type":       "Service Request",
+		"category":   users.NotificationCategoryRequest,
+		"request_id": "42",
+		"status":     "accepted",
+	}
+	if len(data) != len(want) {
+		t.Fatalf("data = %v, want %v", data, want)
+	}
+	for k, v := range want {
+		if data[k] != v {
+			t.Errorf("data[%q] = %q, want %q", k, data[k], v)
+		}
+	}
+
+	if _, ok := pushData(Notification{Category: users.NotificationCategoryPayment})["request_id"]; ok {
+		t.Error("request_id sent without a request")
+	}
+}
+
+func TestConfigurePushRejectsUnknownProvider(t *testing.T) {
+	if _, err := ConfigurePush(nil, "carrier-pigeon", nil); err == nil {
+		t.Fatal("unknown provider was accepted")
+	}
+}
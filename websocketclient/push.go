@@ -0,0 +1,125 @@
+package websocketclient
+
+import (
+	"context"
+	"sync"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// Push providers selectable with PUSH_PROVIDER
+const (
+	PushProviderFCM       = "fcm"
+	PushProviderNoop      = "noop"
+	PushProviderRecording = "recording"
+)
+
+// PushMessage is one notification addressed to a set of device tokens
+type PushMessage struct {
+	Tokens       []string
+	Title        string
+	Body         string
+	Data         map[string]string
+	HighPriority bool // Wake the device (Android high priority, APNs priority 10)
+}
+
+// PushResult is the outcome for a single token. Unregistered tokens are pruned by the caller.
+type PushResult struct {
+	Token        string
+	Err          error
+	Unregistered bool
+}
+
+// PushSender delivers push notifications. FCM is used in production; the no-op and recording
+// senders let the server run, and be tested end to end, without Firebase credentials.
+type PushSender interface {
+	Send(ctx context.Context, msg PushMessage) ([]PushResult, error)
+}
+
+// FCMSender sends through Firebase Cloud Messaging
+type FCMSender struct {
+	client *messaging.Client
+}
+
+func NewFCMSender(client *messaging.Client) *FCMSender {
+	return &FCMSender{client: client}
+}
+
+func (s *FCMSender) Send(ctx context.Context, msg PushMessage) ([]PushResult, error) {
+	message := &messaging.MulticastMessage{
+		Tokens: msg.Tokens,
+		Notification: &messaging.Notification{
+			Title: msg.Title,
+			Body:  msg.Body,
+		},
+		Data: msg.Data,
+	}
+	if msg.HighPriority {
+		message.Android = &messaging.AndroidConfig{
+			Priority: "high", // Important for wakeup
+		}
+		message.APNS = &messaging.APNSConfig{
+			Headers: map[string]string{
+				"apns-priority": "10", // iOS high priority
+			},
+		}
+	}
+
+	batch, err := s.client.SendEachForMulticast(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]PushResult, len(batch.Responses))
+	for i, resp := range batch.Responses {
+		results[i] = PushResult{Token: msg.Tokens[i]}
+		if !resp.Success {
+			results[i].Err = resp.Error
+			results[i].Unregistered = messaging.IsUnregistered(resp.Error)
+		}
+	}
+	return results, nil
+}
+
+// NoopSender drops every message. It is the default when Firebase is not configured.
+type NoopSender struct{}
+
+func (NoopSender) Send(ctx context.Context, msg PushMessage) ([]PushResult, error) {
+	results := make([]PushResult, len(msg.Tokens))
+	for i, token := range msg.Tokens {
+		results[i] = PushResult{Token: token}
+	}
+	return results, nil
+}
+
+// RecordingSender keeps every message in memory so tests can assert on what would have been pushed
+type RecordingSender struct {
+	mu       sync.Mutex
+	messages []PushMessage
+}
+
+func NewRecordingSender() *RecordingSender {
+	return &RecordingSender{}
+}
+
+func (s *RecordingSender) Send(ctx context.Context, msg PushMessage) ([]PushResult, error) {
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.mu.Unlock()
+
+	return NoopSender{}.Send(ctx, msg)
+}
+
+// Messages returns a copy of everything sent so far
+func (s *RecordingSender) Messages() []PushMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]PushMessage(nil), s.messages...)
+}
+
+// Reset forgets recorded messages
+func (s *RecordingSender) Reset() {
+	s.mu.Lock()
+	s.messages = nil
+	s.mu.Unlock()
+}